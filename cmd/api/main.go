@@ -2,11 +2,20 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"go-sample/internal/app"
 )
 
 func main() {
+	// Manage the schema instead of serving when invoked as `api migrate ...`
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := app.RunMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize and start the application
 	application, err := app.NewApp()
 	if err != nil {
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (c *cli) members(args []string) error {
+	if len(args) == 0 {
+		return usageError()
+	}
+	switch args[0] {
+	case "list":
+		teamID, err := parseID(args[1:], "team")
+		if err != nil {
+			return err
+		}
+		team, err := c.teamRepo.GetByID(teamID)
+		if err != nil {
+			return fmt.Errorf("team %d: %w", teamID, err)
+		}
+		return c.printUsers(team.Users)
+	case "add", "remove":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: teamctl members %s <team-id> <user-id>", args[0])
+		}
+		teamID, err := parseUint(args[1], "team")
+		if err != nil {
+			return err
+		}
+		userID, err := parseUint(args[2], "user")
+		if err != nil {
+			return err
+		}
+		if args[0] == "add" {
+			if err := c.teamRepo.AddUser(c.ctx, teamID, userID); err != nil {
+				return err
+			}
+			return c.printMessage("User %d added to team %d", userID, teamID)
+		}
+		return c.removeMember(teamID, userID)
+	default:
+		return usageError()
+	}
+}
+
+// removeMember rewrites the user's memberships without the team, the same
+// way PUT /api/users/{id} does.
+func (c *cli) removeMember(teamID, userID uint) error {
+	user, err := c.userRepo.GetWithTeams(userID)
+	if err != nil {
+		return fmt.Errorf("user %d: %w", userID, err)
+	}
+
+	teamIDs := make([]uint, 0, len(user.Teams))
+	for _, team := range user.Teams {
+		if team.ID != teamID {
+			teamIDs = append(teamIDs, team.ID)
+		}
+	}
+	if len(teamIDs) == len(user.Teams) {
+		return fmt.Errorf("user %d is not a member of team %d", userID, teamID)
+	}
+
+	user.Teams = nil
+	user.Version = 0
+	if err := c.userRepo.UpdateWithTeams(c.ctx, user, teamIDs); err != nil {
+		return err
+	}
+	return c.printMessage("User %d removed from team %d", userID, teamID)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// print writes v as indented JSON, or as a table built by table.
+func (c *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if c.output == "json" {
+		return printJSON(v)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) printUsers(users []models.User) error {
+	return c.print(users, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tEMAIL\tNAME\tVERSION\tCREATED\tDELETED")
+		for _, user := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", user.ID, user.Email, user.Name, user.Version,
+				formatTime(user.CreatedAt), formatDeleted(user.DeletedAt))
+		}
+	})
+}
+
+func (c *cli) printUser(user *models.User) error {
+	return c.print(user, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID:\t%d\n", user.ID)
+		fmt.Fprintf(w, "Email:\t%s\n", user.Email)
+		fmt.Fprintf(w, "Name:\t%s\n", user.Name)
+		fmt.Fprintf(w, "Version:\t%d\n", user.Version)
+		fmt.Fprintf(w, "Created:\t%s\n", formatTime(user.CreatedAt))
+		fmt.Fprintf(w, "Updated:\t%s\n", formatTime(user.UpdatedAt))
+		titles := make([]string, 0, len(user.Teams))
+		for _, team := range user.Teams {
+			titles = append(titles, fmt.Sprintf("%s (%d)", team.Title, team.ID))
+		}
+		fmt.Fprintf(w, "Teams:\t%s\n", strings.Join(titles, ", "))
+	})
+}
+
+func (c *cli) printTeams(teams []models.Team) error {
+	return c.print(teams, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tTITLE\tMEMBERS\tVERSION\tCREATED\tDELETED")
+		for _, team := range teams {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\n", team.ID, team.Title, len(team.Users), team.Version,
+				formatTime(team.CreatedAt), formatDeleted(team.DeletedAt))
+		}
+	})
+}
+
+func (c *cli) printTeam(team *models.Team) error {
+	return c.print(team, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID:\t%d\n", team.ID)
+		fmt.Fprintf(w, "Title:\t%s\n", team.Title)
+		fmt.Fprintf(w, "Description:\t%s\n", team.Description)
+		fmt.Fprintf(w, "Version:\t%d\n", team.Version)
+		fmt.Fprintf(w, "Created:\t%s\n", formatTime(team.CreatedAt))
+		fmt.Fprintf(w, "Updated:\t%s\n", formatTime(team.UpdatedAt))
+		fmt.Fprintf(w, "Members:\t%d\n", len(team.Users))
+	})
+}
+
+// printMessage reports the outcome of a command that has no data to show.
+func (c *cli) printMessage(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	return c.print(map[string]string{"message": message}, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, message)
+	})
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatDeleted(deletedAt gorm.DeletedAt) string {
+	if !deletedAt.Valid {
+		return "-"
+	}
+	return formatTime(deletedAt.Time)
+}
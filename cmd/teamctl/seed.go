@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"go-sample/internal/models"
+	"go-sample/internal/seed"
+)
+
+// seed generates a deterministic dataset and writes it through the
+// repositories, or to importer-ready CSV files with --csv-dir.
+func (c *cli) seed(args []string) error {
+	defaults := seed.DefaultOptions()
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	users := flags.Int("users", defaults.Users, "number of users")
+	teams := flags.Int("teams", defaults.Teams, "number of teams")
+	seedValue := flags.Int64("seed", defaults.Seed, "random seed; equal seeds give equal data")
+	medianTeamSize := flags.Int("median-team-size", defaults.MedianTeamSize, "typical number of members per team")
+	csvDir := flags.String("csv-dir", "", "write users.csv, teams.csv and memberships.csv here instead of the database")
+	flags.Parse(args)
+
+	if *users < 0 || *teams < 0 {
+		return fmt.Errorf("--users and --teams must not be negative")
+	}
+
+	dataset := seed.Generate(seed.Options{
+		Users:          *users,
+		Teams:          *teams,
+		Seed:           *seedValue,
+		MedianTeamSize: *medianTeamSize,
+	})
+
+	if *csvDir != "" {
+		return c.writeSeedCSV(dataset, *csvDir)
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	return c.writeSeedDatabase(dataset)
+}
+
+func (c *cli) writeSeedCSV(dataset seed.Dataset, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := map[string]func(io.Writer) error{
+		"users.csv":       dataset.WriteUsersCSV,
+		"teams.csv":       dataset.WriteTeamsCSV,
+		"memberships.csv": dataset.WriteMembershipsCSV,
+	}
+	for name, write := range files {
+		if err := writeFile(filepath.Join(dir, name), write); err != nil {
+			return err
+		}
+	}
+	return c.printMessage("Wrote %d users, %d teams and %d memberships to %s",
+		len(dataset.Users), len(dataset.Teams), dataset.MembershipCount(), dir)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeSeedDatabase creates the dataset one record at a time, so every row
+// gets the audit entries and events a real change would.
+func (c *cli) writeSeedDatabase(dataset seed.Dataset) error {
+	userIDs := make([]uint, len(dataset.Users))
+	for i, u := range dataset.Users {
+		user := models.User{Email: u.Email, Name: u.Name}
+		if err := c.userRepo.Create(c.ctx, &user); err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		userIDs[i] = user.ID
+		progress("users", i+1, len(dataset.Users))
+	}
+
+	memberships := 0
+	for i, t := range dataset.Teams {
+		team := models.Team{Title: t.Title, Description: t.Description}
+		if err := c.teamRepo.Create(c.ctx, &team); err != nil {
+			return fmt.Errorf("team %s: %w", t.Title, err)
+		}
+		for _, member := range t.Members {
+			if err := c.teamRepo.AddUser(c.ctx, team.ID, userIDs[member]); err != nil {
+				return fmt.Errorf("team %s: %w", t.Title, err)
+			}
+			memberships++
+		}
+		progress("teams", i+1, len(dataset.Teams))
+	}
+
+	return c.print(map[string]int{"users": len(userIDs), "teams": len(dataset.Teams), "memberships": memberships},
+		func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "Seeded %d users, %d teams and %d memberships\n", len(userIDs), len(dataset.Teams), memberships)
+		})
+}
+
+// progress reports every thousandth record on stderr.
+func progress(what string, done, total int) {
+	if done%1000 == 0 || done == total {
+		fmt.Fprintf(os.Stderr, "%s: %d/%d\n", what, done, total)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"go-sample/internal/handlers"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+)
+
+func (c *cli) teams(args []string) error {
+	if len(args) == 0 {
+		return usageError()
+	}
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("teams list", flag.ExitOnError)
+		includeDeleted := flags.Bool("include-deleted", false, "include soft-deleted teams")
+		flags.Parse(args[1:])
+
+		teams, err := c.teamRepo.List(repository.ListOptions{IncludeDeleted: *includeDeleted})
+		if err != nil {
+			return err
+		}
+		return c.printTeams(teams)
+	case "get":
+		id, err := parseID(args[1:], "team")
+		if err != nil {
+			return err
+		}
+		team, err := c.teamRepo.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("team %d: %w", id, err)
+		}
+		return c.printTeam(team)
+	case "create":
+		flags := flag.NewFlagSet("teams create", flag.ExitOnError)
+		title := flags.String("title", "", "team title")
+		description := flags.String("description", "", "team description")
+		flags.Parse(args[1:])
+
+		// Apply the same rules as POST /api/teams
+		req := handlers.TeamRequest{Title: *title, Description: *description}
+		if errs := validation.Struct(&req); len(errs) > 0 {
+			return fmt.Errorf("invalid team: %w", errs)
+		}
+
+		team := models.Team{Title: req.Title, Description: req.Description}
+		if err := c.teamRepo.Create(c.ctx, &team); err != nil {
+			return err
+		}
+		return c.printTeam(&team)
+	case "delete":
+		id, err := parseID(args[1:], "team")
+		if err != nil {
+			return err
+		}
+		if err := c.teamRepo.Delete(c.ctx, id, 0); err != nil {
+			return err
+		}
+		return c.printMessage("Team %d deleted", id)
+	case "restore":
+		id, err := parseID(args[1:], "team")
+		if err != nil {
+			return err
+		}
+		if err := c.teamRepo.Restore(c.ctx, id); err != nil {
+			return fmt.Errorf("team %d: %w", id, err)
+		}
+		return c.printMessage("Team %d restored", id)
+	default:
+		return usageError()
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"go-sample/internal/handlers"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+)
+
+// Import formats by file extension; other files are read as CSV
+var fileFormats = map[string]string{
+	".xlsx":   handlers.FormatXLSX,
+	".json":   handlers.FormatJSON,
+	".ndjson": handlers.FormatNDJSON,
+	".jsonl":  handlers.FormatNDJSON,
+}
+
+// importFile runs a local CSV, XLSX, JSON or NDJSON file through the same
+// import logic as POST /api/import. The format follows the file extension
+// unless given with --format. With --errors, rejected rows are written to a CSV with an
+// error column that can be fixed and imported again.
+func (c *cli) importFile(args []string) error {
+	if len(args) > 0 && args[0] == "resume" {
+		return c.resumeImport(args[1:])
+	}
+	if len(args) < 2 || (args[0] != "users" && args[0] != "teams") {
+		return fmt.Errorf("usage: teamctl import <users|teams> <file> [--format csv|xlsx|json|ndjson] [--sheet NAME] [--errors <errors.csv>] [--delimiter C] [--quoting standard|lazy|none] [--encoding NAME] [--no-header] [--column HEADER=COLUMN]...")
+	}
+
+	var dialect handlers.CSVDialect
+	columns := make(map[string]string)
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "file format: csv, xlsx, json or ndjson; defaults to the file extension")
+	sheet := flags.String("sheet", "", "XLSX sheet to import; defaults to the sheet named after the entity type")
+	errorsPath := flags.String("errors", "", "write rejected rows to this CSV file")
+	flags.StringVar(&dialect.Delimiter, "delimiter", "", "field delimiter; detected when empty")
+	flags.StringVar(&dialect.Quoting, "quoting", "", "quote handling: standard, lazy or none")
+	flags.StringVar(&dialect.Encoding, "encoding", "", "text encoding, e.g. windows-1252; defaults to utf-8")
+	flags.BoolVar(&dialect.NoHeader, "no-header", false, "the first row is data; map columns by position")
+	flags.Func("column", "map a header (or position with --no-header) to a column, as HEADER=COLUMN; repeatable", func(value string) error {
+		source, target, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected HEADER=COLUMN, got %q", value)
+		}
+		columns[source] = target
+		return nil
+	})
+	flags.Parse(args[2:])
+
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = fileFormats[strings.ToLower(filepath.Ext(args[1]))]
+	}
+
+	response := c.importer.Import(c.ctx, []handlers.ImportFileRequest{{
+		EntityType: args[0],
+		Data:       base64.StdEncoding.EncodeToString(data),
+		Format:     *format,
+		Sheet:      *sheet,
+		Dialect:    dialect,
+		Columns:    columns,
+	}})
+	return c.printImport(response, *errorsPath)
+}
+
+// resumeImport continues an import interrupted by a database failure from
+// each file's checkpoint.
+func (c *cli) resumeImport(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: teamctl import resume <id> [--errors <errors.csv>]")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid import ID: %s", args[0])
+	}
+
+	flags := flag.NewFlagSet("import resume", flag.ExitOnError)
+	errorsPath := flags.String("errors", "", "write rejected rows to this CSV file")
+	flags.Parse(args[1:])
+
+	response, err := c.importer.ResumeImport(c.ctx, uint(id))
+	if err != nil {
+		return err
+	}
+	return c.printImport(response, *errorsPath)
+}
+
+// printImport reports an import's results and, with errorsPath, writes the
+// rejected rows of the file that has any.
+func (c *cli) printImport(response handlers.ImportResponse, errorsPath string) error {
+	if err := c.print(response, func(w *tabwriter.Writer) {
+		for _, result := range response.Results {
+			fmt.Fprintf(w, "Imported %s: %d of %d lines succeeded, %d failed (%s)\n", result.EntityType,
+				result.SuccessCount, result.TotalLines, result.FailureCount, response.ProcessingTime)
+			if result.Interrupted {
+				fmt.Fprintf(w, "  Interrupted by a database failure; continue with: teamctl import resume %d\n", response.ImportID)
+			}
+			for _, importErr := range result.Errors {
+				if importErr.Line > 0 {
+					fmt.Fprintf(w, "  Line %d: %s [%s]\n", importErr.Line, importErr, importErr.Code)
+				} else {
+					fmt.Fprintf(w, "  %s [%s]\n", importErr, importErr.Code)
+				}
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	for _, result := range response.Results {
+		if result.FailureCount == 0 {
+			continue
+		}
+		if errorsPath != "" && result.ErrorCSV != nil {
+			if err := os.WriteFile(errorsPath, result.ErrorCSV, 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Rejected rows written to %s\n", errorsPath)
+		}
+		return fmt.Errorf("%d records failed to import", result.FailureCount)
+	}
+	if response.Status == models.ImportStatusFailed {
+		return fmt.Errorf("import %d was interrupted", response.ImportID)
+	}
+	return nil
+}
+
+// export writes users or teams to stdout as CSV that import accepts, or as
+// JSON.
+func (c *cli) export(args []string) error {
+	if len(args) == 0 || (args[0] != "users" && args[0] != "teams") {
+		return fmt.Errorf("usage: teamctl export <users|teams> [--format csv|json] [--include-deleted]")
+	}
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "export format: csv or json")
+	includeDeleted := flags.Bool("include-deleted", false, "include soft-deleted records")
+	flags.Parse(args[1:])
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown export format: %s", *format)
+	}
+
+	opts := repository.ListOptions{IncludeDeleted: *includeDeleted}
+	var header []string
+	var rows [][]string
+	var data interface{}
+	if args[0] == "users" {
+		users, err := c.userRepo.List(opts)
+		if err != nil {
+			return err
+		}
+		data = users
+		attributes := make([]models.Attributes, len(users))
+		for i, user := range users {
+			attributes[i] = user.Attributes
+		}
+		keys := attributeKeys(attributes)
+		header = append([]string{"id", "email", "name", "source", "external_id"}, keys...)
+		for _, user := range users {
+			row := []string{strconv.FormatUint(uint64(user.ID), 10), user.Email, user.Name, user.Source, user.ExternalID}
+			rows = append(rows, append(row, attributeCells(user.Attributes, keys)...))
+		}
+	} else {
+		teams, err := c.teamRepo.List(opts)
+		if err != nil {
+			return err
+		}
+		data = teams
+		attributes := make([]models.Attributes, len(teams))
+		for i, team := range teams {
+			attributes[i] = team.Attributes
+		}
+		keys := attributeKeys(attributes)
+		header = append([]string{"id", "title", "description", "source", "external_id"}, keys...)
+		for _, team := range teams {
+			row := []string{strconv.FormatUint(uint64(team.ID), 10), team.Title, team.Description, team.Source, team.ExternalID}
+			rows = append(rows, append(row, attributeCells(team.Attributes, keys)...))
+		}
+	}
+
+	if *format == "json" {
+		return printJSON(data)
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	return writer.Error()
+}
+
+// attributeKeys returns every attribute name in use, sorted, to export as
+// extra columns.
+func attributeKeys(all []models.Attributes) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, attributes := range all {
+		for key := range attributes {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attributeCells renders attributes in keys order: strings as they are,
+// anything else as JSON.
+func attributeCells(attributes models.Attributes, keys []string) []string {
+	cells := make([]string, len(keys))
+	for i, key := range keys {
+		switch value := attributes[key].(type) {
+		case nil:
+		case string:
+			cells[i] = value
+		default:
+			data, _ := json.Marshal(value)
+			cells[i] = string(data)
+		}
+	}
+	return cells
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"go-sample/internal/handlers"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+)
+
+func (c *cli) users(args []string) error {
+	if len(args) == 0 {
+		return usageError()
+	}
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("users list", flag.ExitOnError)
+		includeDeleted := flags.Bool("include-deleted", false, "include soft-deleted users")
+		flags.Parse(args[1:])
+
+		users, err := c.userRepo.List(repository.ListOptions{IncludeDeleted: *includeDeleted})
+		if err != nil {
+			return err
+		}
+		return c.printUsers(users)
+	case "get":
+		id, err := parseID(args[1:], "user")
+		if err != nil {
+			return err
+		}
+		user, err := c.userRepo.GetWithTeams(id)
+		if err != nil {
+			return fmt.Errorf("user %d: %w", id, err)
+		}
+		return c.printUser(user)
+	case "create":
+		flags := flag.NewFlagSet("users create", flag.ExitOnError)
+		email := flags.String("email", "", "email address")
+		name := flags.String("name", "", "display name")
+		flags.Parse(args[1:])
+
+		// Apply the same rules as POST /api/users
+		req := handlers.CreateUserRequest{Email: *email, Name: *name}
+		if errs := validation.Struct(&req); len(errs) > 0 {
+			return fmt.Errorf("invalid user: %w", errs)
+		}
+
+		user := models.User{Email: req.Email, Name: req.Name}
+		if err := c.userRepo.Create(c.ctx, &user); err != nil {
+			return err
+		}
+		return c.printUser(&user)
+	case "delete":
+		id, err := parseID(args[1:], "user")
+		if err != nil {
+			return err
+		}
+		if err := c.userRepo.Delete(c.ctx, id, 0); err != nil {
+			return err
+		}
+		return c.printMessage("User %d deleted", id)
+	case "restore":
+		id, err := parseID(args[1:], "user")
+		if err != nil {
+			return err
+		}
+		if err := c.userRepo.Restore(c.ctx, id); err != nil {
+			return fmt.Errorf("user %d: %w", id, err)
+		}
+		return c.printMessage("User %d restored", id)
+	default:
+		return usageError()
+	}
+}
+
+// parseID reads the single positional ID argument of a command.
+func parseID(args []string, what string) (uint, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected exactly one %s ID", what)
+	}
+	return parseUint(args[0], what)
+}
+
+func parseUint(arg, what string) (uint, error) {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid %s ID: %s", what, arg)
+	}
+	return uint(id), nil
+}
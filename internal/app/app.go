@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,17 +11,28 @@ import (
 	"go-sample/internal/cache"
 	"go-sample/internal/config"
 	"go-sample/internal/handlers"
+	"go-sample/internal/invitation"
 	"go-sample/internal/models"
+	"go-sample/internal/outbox"
+	"go-sample/internal/ratelimit"
 	"go-sample/internal/repository"
+	"go-sample/internal/retention"
 	"go-sample/internal/router"
+	"go-sample/internal/scim"
+	"go-sample/internal/stream"
+	"go-sample/internal/webhook"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type App struct {
-	config *config.Config
-	server *http.Server
+	config     *config.Config
+	server     *http.Server
+	dispatcher *webhook.Dispatcher
+	relay      *outbox.Relay
+	broker     *stream.Broker
+	purgeJob   *retention.Job
 }
 
 func NewApp() (*App, error) {
@@ -28,7 +41,7 @@ func NewApp() (*App, error) {
 	log.Printf("Starting application...")
 
 	// Initialize database with retry logic
-	db, err := initDatabase(cfg)
+	db, err := OpenDatabase(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("database initialization failed: %w", err)
 	}
@@ -37,17 +50,61 @@ func NewApp() (*App, error) {
 	log.Printf("Initializing cache...")
 	cacheService := cache.NewCache(cfg.RedisHost, cfg.RedisPort)
 
+	// Initialize webhook dispatcher
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookOptions := webhook.DefaultOptions()
+	webhookOptions.AllowPrivateHosts = cfg.WebhookAllowPrivateHosts
+	dispatcher := webhook.NewDispatcher(webhookRepo, nil, webhookOptions)
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db, cacheService)
 	teamRepo := repository.NewTeamRepository(db, cacheService)
+	auditRepo := repository.NewAuditRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	invitationRepo := repository.NewInvitationRepository(db, cacheService)
+	schemaRepo := repository.NewAttributeSchemaRepository(db)
+	batchRepo := repository.NewBatchRepository(db, cacheService)
+	importRepo := repository.NewImportRepository(db)
+
+	// Initialize outbox relay
+	sinks, err := outboxSinks(cfg, cacheService, dispatcher)
+	if err != nil {
+		return nil, err
+	}
+	relay := outbox.NewRelay(repository.NewOutboxRepository(db), sinks, outbox.DefaultOptions())
+
+	// Initialize retention job for soft-deleted rows
+	var purgeJob *retention.Job
+	if cfg.SoftDeleteRetention > 0 {
+		purgeJob = retention.NewJob(userRepo, teamRepo, cfg.SoftDeleteRetention, time.Hour)
+	}
+
+	// Initialize event stream broker
+	broker := stream.NewBroker(cacheService.Client(), cfg.OutboxRedisStream, int64(cfg.EventHistorySize))
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userRepo)
-	teamHandler := handlers.NewTeamHandler(teamRepo)
-	importHandler := handlers.NewImportHandler(userRepo, teamRepo)
+	userHandler := handlers.NewUserHandler(userRepo, cfg.RequireIfMatch)
+	teamHandler := handlers.NewTeamHandler(teamRepo, cfg.RequireIfMatch)
+	importHandler := handlers.NewImportHandler(userRepo, teamRepo, auditRepo, importRepo, schemaRepo, cfg.MaxConcurrentImports, cfg.ImportChunkSize, cfg.ImportStaleAfter)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo, dispatcher)
+	eventHandler := handlers.NewEventHandler(broker)
+	searchHandler := handlers.NewSearchHandler(searchRepo)
+	invitationHandler := handlers.NewInvitationHandler(invitationRepo, userRepo, invitation.NewSigner(invitationSecret(cfg)), cfg.InvitationTTL)
+	schemaHandler := handlers.NewAttributeSchemaHandler(schemaRepo)
+	batchHandler := handlers.NewBatchHandler(userRepo, teamRepo, batchRepo)
+	scimHandler := scim.NewHandler(userRepo, teamRepo)
+
+	// Initialize rate limiter
+	var limiter ratelimit.Limiter
+	if cfg.RateLimitRedis {
+		limiter = ratelimit.NewRedisLimiter(cacheService.Client(), cfg.RateLimitRPS, cfg.RateLimitBurst)
+	} else {
+		limiter = ratelimit.NewMemoryLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
 
 	// Setup router
-	r := router.SetupRouter(userHandler, teamHandler, importHandler)
+	r := router.SetupRouter(userHandler, teamHandler, importHandler, auditHandler, webhookHandler, eventHandler, searchHandler, invitationHandler, schemaHandler, batchHandler, scimHandler, limiter, cfg.APIKeys, cfg.AdminAPIKeys, cfg.TrustedProxies)
 
 	// Configure server
 	server := &http.Server{
@@ -58,17 +115,83 @@ func NewApp() (*App, error) {
 	}
 
 	return &App{
-		config: cfg,
-		server: server,
+		config:     cfg,
+		server:     server,
+		dispatcher: dispatcher,
+		relay:      relay,
+		broker:     broker,
+		purgeJob:   purgeJob,
 	}, nil
 }
 
 func (a *App) Start() error {
+	// Relay outbox events, deliver webhooks and tail the event stream in the background
+	go a.relay.Run(context.Background())
+	go a.dispatcher.Run(context.Background())
+	go a.broker.Run(context.Background())
+	if a.purgeJob != nil {
+		go a.purgeJob.Run(context.Background())
+	}
+
 	log.Printf("Server starting on http://0.0.0.0:8080")
 	return a.server.ListenAndServe()
 }
 
-func initDatabase(cfg *config.Config) (*gorm.DB, error) {
+func outboxSinks(cfg *config.Config, cacheService *cache.Cache, dispatcher *webhook.Dispatcher) ([]outbox.Sink, error) {
+	var sinks []outbox.Sink
+	for _, name := range cfg.OutboxSinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, outbox.LogSink{})
+		case "webhook":
+			sinks = append(sinks, outbox.NewWebhookSink(dispatcher))
+		case "redis":
+			sinks = append(sinks, outbox.NewRedisStreamSink(cacheService.Client(), cfg.OutboxRedisStream, int64(cfg.EventHistorySize)))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink: %s", name)
+		}
+	}
+	return sinks, nil
+}
+
+// invitationSecret returns the configured signing key or, without one, a random
+// key that only lives as long as the process.
+func invitationSecret(cfg *config.Config) []byte {
+	if cfg.InvitationSecret != "" {
+		return []byte(cfg.InvitationSecret)
+	}
+	log.Printf("Warning: INVITATION_SECRET is not set; invitation tokens will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("failed to generate invitation secret: %v", err)
+	}
+	return secret
+}
+
+// OpenDatabase connects to Postgres, registers the join models and handles
+// pending migrations according to cfg. The server and teamctl share it.
+func OpenDatabase(cfg *config.Config) (*gorm.DB, error) {
+	db, err := connectDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use TeamUser as the join model so memberships are soft-deleted too
+	if err := db.SetupJoinTable(&models.User{}, "Teams", &models.TeamUser{}); err != nil {
+		return nil, fmt.Errorf("failed to set up team_users join table: %w", err)
+	}
+	if err := db.SetupJoinTable(&models.Team{}, "Users", &models.TeamUser{}); err != nil {
+		return nil, fmt.Errorf("failed to set up team_users join table: %w", err)
+	}
+
+	if err := migrateOnStart(db, cfg.MigrationsOnStart); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func connectDatabase(cfg *config.Config) (*gorm.DB, error) {
 	var db *gorm.DB
 	var err error
 
@@ -90,12 +213,5 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 	}
 	log.Printf("Successfully connected to database")
 
-	// Auto migrate the schema
-	log.Printf("Running database migrations...")
-	if err := db.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamUser{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
-	}
-	log.Printf("Database migrations completed")
-
 	return db, nil
 }
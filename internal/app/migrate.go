@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"go-sample/internal/config"
+	"go-sample/internal/migrate"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Values of MIGRATIONS_ON_START
+const (
+	migrationsApply  = "apply"
+	migrationsCheck  = "check"
+	migrationsIgnore = "ignore"
+)
+
+// migrateOnStart applies pending migrations, or with "check" refuses to start
+// while any are pending, or with "ignore" only logs them. Applied migrations
+// whose script was since edited or removed stop "apply" and "check" too,
+// since the schema may not be what the code expects.
+func migrateOnStart(db *gorm.DB, mode string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	ctx := context.Background()
+	switch mode {
+	case migrationsApply, migrationsCheck, migrationsIgnore:
+	default:
+		return fmt.Errorf("unknown MIGRATIONS_ON_START value: %s", mode)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if err := checkApplied(statuses); err != nil {
+		if mode != migrationsIgnore {
+			return err
+		}
+		log.Printf("Warning: %v", err)
+	}
+
+	switch mode {
+	case migrationsApply:
+		log.Printf("Running database migrations...")
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %w", explainMigrationError(err))
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		log.Printf("Database migrations completed")
+	case migrationsCheck, migrationsIgnore:
+		var pending []migrate.Status
+		for _, s := range statuses {
+			if s.AppliedAt == nil {
+				pending = append(pending, s)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if mode == migrationsCheck {
+			return fmt.Errorf("%d pending migrations, first %04d_%s; run `migrate up`",
+				len(pending), pending[0].Version, pending[0].Name)
+		}
+		log.Printf("Warning: %d pending migrations, first %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// checkApplied reports applied migrations whose script differs from the one
+// they were applied from, or is gone.
+func checkApplied(statuses []migrate.Status) error {
+	var drifted []string
+	for _, s := range statuses {
+		switch {
+		case s.Modified:
+			drifted = append(drifted, fmt.Sprintf("%04d_%s (modified)", s.Version, s.Name))
+		case s.Missing:
+			drifted = append(drifted, fmt.Sprintf("%04d_%s (missing)", s.Version, s.Name))
+		}
+	}
+	if len(drifted) == 0 {
+		return nil
+	}
+	return fmt.Errorf("applied migrations no longer match their scripts: %s; see `migrate status`",
+		strings.Join(drifted, ", "))
+}
+
+// pgInsufficientPrivilege is the SQLSTATE of permission denied errors
+const pgInsufficientPrivilege = "42501"
+
+// explainMigrationError adds what to do when a migration creates an extension
+// (0002_search needs pg_trgm) that the migrating role may not create.
+func explainMigrationError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgInsufficientPrivilege ||
+		!strings.Contains(pgErr.Message, "extension") {
+		return err
+	}
+	return fmt.Errorf("%w; have a superuser run CREATE EXTENSION IF NOT EXISTS pg_trgm in this database, "+
+		"or grant the migrating role CREATE on it, then migrate again", err)
+}
+
+// RunMigrate runs the `migrate` subcommand: up, down [steps], status or
+// create <name>.
+func RunMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status|create <name>")
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New("usage: migrate create <name>")
+		}
+		up, down, err := migrate.Create(migrate.SourceDir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return nil
+	}
+
+	switch args[0] {
+	case "up", "down", "status":
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+
+	db, err := connectDatabase(config.NewConfig())
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return explainMigrationError(err)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.AppliedAt != nil {
+				state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state = "applied (modified)"
+			}
+			if s.Missing {
+				state = "applied (missing)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,6 +13,13 @@ import (
 type Cache struct {
 	redisClient *redis.Client
 	memoryCache *cache.Cache
+	// Keys deleted through a tracking cache, see Tracking
+	deleted *deletedKeys
+}
+
+type deletedKeys struct {
+	mu   sync.Mutex
+	keys []string
 }
 
 func NewCache(redisHost, redisPort string) *Cache {
@@ -77,6 +85,12 @@ func (c *Cache) Get(key string, value interface{}) error {
 }
 
 func (c *Cache) Delete(key string) error {
+	if c.deleted != nil {
+		c.deleted.mu.Lock()
+		c.deleted.keys = append(c.deleted.keys, key)
+		c.deleted.mu.Unlock()
+	}
+
 	// Delete from Redis
 	err := c.redisClient.Del(context.Background(), key).Err()
 	if err != nil {
@@ -87,3 +101,40 @@ func (c *Cache) Delete(key string) error {
 	c.memoryCache.Delete(key)
 	return nil
 }
+
+// Tracking returns a cache sharing c's stores that remembers the keys it
+// deletes. Invalidations made inside a transaction can be repeated with
+// Replay once it ends, dropping entries read before the commit.
+func (c *Cache) Tracking() *Cache {
+	return &Cache{
+		redisClient: c.redisClient,
+		memoryCache: c.memoryCache,
+		deleted:     &deletedKeys{},
+	}
+}
+
+// Replay deletes every key deleted so far through this tracking cache again.
+func (c *Cache) Replay() {
+	if c.deleted == nil {
+		return
+	}
+	c.deleted.mu.Lock()
+	keys := c.deleted.keys
+	c.deleted.keys = nil
+	c.deleted.mu.Unlock()
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			c.redisClient.Del(context.Background(), key)
+			c.memoryCache.Delete(key)
+		}
+	}
+}
+
+// Client exposes the underlying Redis client for features that need
+// shared state across replicas (e.g. rate limiting).
+func (c *Cache) Client() *redis.Client {
+	return c.redisClient
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +19,46 @@ type Config struct {
 	PostgresDB       string
 	RedisHost        string
 	RedisPort        string
+
+	// API keys clients identify with; requests with other keys are refused
+	// and requests without one are anonymous
+	APIKeys []string
+	// API keys allowed to use admin-only features
+	AdminAPIKeys []string
+	// Proxies whose X-Forwarded-For is trusted to name the client
+	TrustedProxies []*net.IPNet
+
+	// What to do with pending migrations on start: apply, check or ignore
+	MigrationsOnStart string
+
+	// Reject mutations of users and teams that do not send If-Match
+	RequireIfMatch bool
+
+	// Key for signing invitation tokens and how long invitations stay open
+	InvitationSecret string
+	InvitationTTL    time.Duration
+
+	// Soft-deleted rows older than this are purged; zero disables purging
+	SoftDeleteRetention time.Duration
+
+	// Rate limiting
+	RateLimitRPS         float64
+	RateLimitBurst       int
+	RateLimitRedis       bool
+	MaxConcurrentImports int
+
+	// Rows written per import transaction
+	ImportChunkSize int
+	// A running import not touched for this long can be resumed
+	ImportStaleAfter time.Duration
+
+	// Allow webhooks to loopback, private and link-local addresses
+	WebhookAllowPrivateHosts bool
+
+	// Outbox relay
+	OutboxSinks       []string
+	OutboxRedisStream string
+	EventHistorySize  int
 }
 
 func NewConfig() *Config {
@@ -31,6 +75,29 @@ func NewConfig() *Config {
 		PostgresDB:       os.Getenv("POSTGRES_DB"),
 		RedisHost:        os.Getenv("REDIS_HOST"),
 		RedisPort:        os.Getenv("REDIS_PORT"),
+
+		APIKeys:             getEnvList("API_KEYS", nil),
+		AdminAPIKeys:        getEnvList("ADMIN_API_KEYS", nil),
+		TrustedProxies:      getEnvCIDRs("TRUSTED_PROXIES"),
+		MigrationsOnStart:   getEnvString("MIGRATIONS_ON_START", "apply"),
+		RequireIfMatch:      getEnvBool("REQUIRE_IF_MATCH", false),
+		InvitationSecret:    os.Getenv("INVITATION_SECRET"),
+		InvitationTTL:       getEnvDuration("INVITATION_TTL", 7*24*time.Hour),
+		SoftDeleteRetention: getEnvDuration("SOFT_DELETE_RETENTION", 0),
+
+		RateLimitRPS:         getEnvFloat("RATE_LIMIT_RPS", 10),
+		RateLimitBurst:       getEnvInt("RATE_LIMIT_BURST", 20),
+		RateLimitRedis:       getEnvBool("RATE_LIMIT_REDIS", false),
+		MaxConcurrentImports: getEnvInt("MAX_CONCURRENT_IMPORTS", 2),
+
+		ImportChunkSize:  getEnvInt("IMPORT_CHUNK_SIZE", 500),
+		ImportStaleAfter: getEnvDuration("IMPORT_STALE_AFTER", 2*time.Minute),
+
+		WebhookAllowPrivateHosts: getEnvBool("WEBHOOK_ALLOW_PRIVATE_HOSTS", false),
+
+		OutboxSinks:       getEnvList("OUTBOX_SINKS", []string{"webhook", "redis"}),
+		OutboxRedisStream: getEnvString("OUTBOX_REDIS_STREAM", "events"),
+		EventHistorySize:  getEnvInt("EVENT_HISTORY_SIZE", 10000),
 	}
 
 	// Validate required environment variables
@@ -55,6 +122,106 @@ func NewConfig() *Config {
 	if config.RedisPort == "" {
 		log.Fatal("REDIS_PORT environment variable is required")
 	}
+	if config.RateLimitRPS <= 0 {
+		log.Fatal("RATE_LIMIT_RPS must be positive")
+	}
+	if config.RateLimitBurst < 1 {
+		log.Fatal("RATE_LIMIT_BURST must be at least 1")
+	}
+	if config.MaxConcurrentImports < 1 {
+		log.Fatal("MAX_CONCURRENT_IMPORTS must be at least 1")
+	}
 
 	return config
 }
+
+func getEnvString(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvCIDRs reads a comma-separated list of CIDR ranges; a bare IP stands
+// for itself.
+func getEnvCIDRs(key string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, item := range getEnvList(key, nil) {
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Fatalf("%s must list IPs or CIDR ranges: %v", key, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("%s must be a number: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s must be a boolean: %v", key, err)
+	}
+	return parsed
+}
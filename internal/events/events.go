@@ -0,0 +1,75 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Event types published on user, team and membership changes.
+const (
+	UserCreated  = "user.created"
+	UserUpdated  = "user.updated"
+	UserDeleted  = "user.deleted"
+	UserRestored = "user.restored"
+
+	TeamCreated  = "team.created"
+	TeamUpdated  = "team.updated"
+	TeamDeleted  = "team.deleted"
+	TeamRestored = "team.restored"
+
+	MembershipAdded   = "membership.added"
+	MembershipRemoved = "membership.removed"
+
+	InvitationCreated  = "invitation.created"
+	InvitationAccepted = "invitation.accepted"
+	InvitationDeclined = "invitation.declined"
+	InvitationRevoked  = "invitation.revoked"
+)
+
+// Types lists every event type a subscriber may ask for.
+var Types = []string{
+	UserCreated, UserUpdated, UserDeleted, UserRestored,
+	TeamCreated, TeamUpdated, TeamDeleted, TeamRestored,
+	MembershipAdded, MembershipRemoved,
+	InvitationCreated, InvitationAccepted, InvitationDeclined, InvitationRevoked,
+}
+
+// Wildcard subscribes to every event type.
+const Wildcard = "*"
+
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+func New(eventType string, data interface{}) Event {
+	return Event{
+		ID:         NewID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// NewID returns a random 128-bit hex identifier.
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// IsKnownType reports whether eventType is a published type or the wildcard.
+func IsKnownType(eventType string) bool {
+	if eventType == Wildcard {
+		return true
+	}
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+	"go-sample/internal/requestctx"
+
+	"github.com/gorilla/mux"
+)
+
+type AttributeSchemaHandler struct {
+	schemaRepo repository.AttributeSchemaRepository
+}
+
+func NewAttributeSchemaHandler(schemaRepo repository.AttributeSchemaRepository) *AttributeSchemaHandler {
+	return &AttributeSchemaHandler{schemaRepo: schemaRepo}
+}
+
+func (h *AttributeSchemaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	entityType, ok := schemaEntityType(w, r)
+	if !ok {
+		return
+	}
+
+	schema, err := h.schemaRepo.Get(entityType)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Attribute schema")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, schema)
+}
+
+// Put registers the request body as the JSON Schema for the entity type's
+// attributes. Only admins may change schemas.
+func (h *AttributeSchemaHandler) Put(w http.ResponseWriter, r *http.Request) {
+	entityType, ok := schemaEntityType(w, r)
+	if !ok {
+		return
+	}
+	if !requestctx.IsAdmin(r.Context()) {
+		ErrorResponse(w, http.StatusForbidden, "Changing attribute schemas requires an admin API key")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil || !json.Valid(body) {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	schema := models.AttributeSchema{EntityType: entityType, Schema: models.JSON(body)}
+	if err := h.schemaRepo.Put(r.Context(), &schema); err != nil {
+		RepositoryErrorResponse(w, r, err, "Attribute schema")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, schema)
+}
+
+// Delete stops validating the entity type's attributes.
+func (h *AttributeSchemaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	entityType, ok := schemaEntityType(w, r)
+	if !ok {
+		return
+	}
+	if !requestctx.IsAdmin(r.Context()) {
+		ErrorResponse(w, http.StatusForbidden, "Changing attribute schemas requires an admin API key")
+		return
+	}
+
+	if err := h.schemaRepo.Delete(r.Context(), entityType); err != nil {
+		RepositoryErrorResponse(w, r, err, "Attribute schema")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, map[string]string{"message": "Attribute schema deleted successfully"})
+}
+
+func schemaEntityType(w http.ResponseWriter, r *http.Request) (string, bool) {
+	entityType := mux.Vars(r)["entity"]
+	if entityType != repository.AttributeEntityUsers && entityType != repository.AttributeEntityTeams {
+		ErrorResponse(w, http.StatusNotFound, "Attribute schemas exist for users and teams only")
+		return "", false
+	}
+	return entityType, true
+}
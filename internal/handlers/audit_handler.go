@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-sample/internal/repository"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		auditRepo: auditRepo,
+	}
+}
+
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := repository.AuditFilter{
+		EntityType: query.Get("entity_type"),
+		Actor:      query.Get("actor"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if value := query.Get("entity_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid entity_id")
+			return
+		}
+		filter.EntityID = uint(id)
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid from, expected RFC3339 timestamp")
+			return
+		}
+		filter.From = from
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid to, expected RFC3339 timestamp")
+			return
+		}
+		filter.To = to
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			ErrorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.auditRepo.List(filter)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Audit log")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, entries)
+}
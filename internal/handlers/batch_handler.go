@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+)
+
+const maxBatchOperations = 1000
+
+// Batch operations
+const (
+	BatchOpCreateUser   = "create_user"
+	BatchOpUpdateUser   = "update_user"
+	BatchOpDeleteUser   = "delete_user"
+	BatchOpCreateTeam   = "create_team"
+	BatchOpAddMember    = "add_member"
+	BatchOpRemoveMember = "remove_member"
+)
+
+// Statuses of a batch operation
+const (
+	BatchStatusSucceeded = "succeeded"
+	BatchStatusFailed    = "failed"
+	// Succeeded, then undone because a later operation of an atomic batch failed
+	BatchStatusRolledBack = "rolled_back"
+	// Not attempted because an earlier operation of an atomic batch failed
+	BatchStatusSkipped = "skipped"
+)
+
+// errBatchAborted rolls back an atomic batch after a failed operation.
+var errBatchAborted = errors.New("batch aborted")
+
+type BatchHandler struct {
+	userRepo  repository.UserRepository
+	teamRepo  repository.TeamRepository
+	batchRepo repository.BatchRepository
+}
+
+func NewBatchHandler(userRepo repository.UserRepository, teamRepo repository.TeamRepository, batchRepo repository.BatchRepository) *BatchHandler {
+	return &BatchHandler{
+		userRepo:  userRepo,
+		teamRepo:  teamRepo,
+		batchRepo: batchRepo,
+	}
+}
+
+// BatchRequest lists operations to run in order. Atomic batches run in one
+// transaction and stop at the first failure; others run every operation
+// independently.
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required"`
+}
+
+func (r BatchRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if len(r.Operations) > maxBatchOperations {
+		errs.Add("operations", fmt.Sprintf("must have at most %d items", maxBatchOperations))
+	}
+	return errs
+}
+
+// BatchOperation mirrors a single API request: ID is the path ID of the user
+// or team (the team for add_member and remove_member), Version the If-Match
+// version (zero skips the check) and Data the request body.
+type BatchOperation struct {
+	Op      string          `json:"op"`
+	ID      uint            `json:"id"`
+	Version uint            `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	// The created or updated record
+	Data  interface{} `json:"data,omitempty"`
+	Error *Problem    `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Atomic       bool          `json:"atomic"`
+	Committed    bool          `json:"committed"`
+	Total        int           `json:"total"`
+	SuccessCount int           `json:"success_count"`
+	FailureCount int           `json:"failure_count"`
+	Results      []BatchResult `json:"results"`
+}
+
+// Run executes a batch. Best-effort batches always answer 200 with a result
+// per operation. An atomic batch that fails is rolled back and answered with
+// the failed operation's status.
+func (h *BatchHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	results := make([]BatchResult, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = BatchResult{Index: i, Op: op.Op, Status: BatchStatusSkipped}
+	}
+
+	var failed *Problem
+	if req.Atomic {
+		err := h.batchRepo.Atomic(r.Context(), func(users repository.UserRepository, teams repository.TeamRepository) error {
+			for i, op := range req.Operations {
+				if failed = h.execute(r, users, teams, op, &results[i]); failed != nil {
+					return errBatchAborted
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchAborted) {
+			RepositoryErrorResponse(w, r, err, "Batch")
+			return
+		}
+		if failed != nil {
+			for i := range results {
+				if results[i].Status == BatchStatusSucceeded {
+					results[i].Status = BatchStatusRolledBack
+					results[i].Data = nil
+				}
+			}
+		}
+	} else {
+		for i, op := range req.Operations {
+			h.execute(r, h.userRepo, h.teamRepo, op, &results[i])
+		}
+	}
+
+	response := BatchResponse{
+		Atomic:    req.Atomic,
+		Committed: failed == nil,
+		Total:     len(results),
+		Results:   results,
+	}
+	for _, result := range results {
+		switch result.Status {
+		case BatchStatusSucceeded:
+			response.SuccessCount++
+		case BatchStatusFailed:
+			response.FailureCount++
+		}
+	}
+
+	status := http.StatusOK
+	if req.Atomic && failed != nil {
+		status = failed.Status
+	}
+	JSONResponse(w, status, response)
+}
+
+// execute runs one operation and records its outcome in result, returning
+// the problem if it failed.
+func (h *BatchHandler) execute(r *http.Request, users repository.UserRepository, teams repository.TeamRepository, op BatchOperation, result *BatchResult) *Problem {
+	data, problem := h.dispatch(r, users, teams, op)
+	if problem != nil {
+		p := problem.withDefaults()
+		result.Status = BatchStatusFailed
+		result.Error = &p
+		return &p
+	}
+	result.Status = BatchStatusSucceeded
+	result.Data = data
+	return nil
+}
+
+func (h *BatchHandler) dispatch(r *http.Request, users repository.UserRepository, teams repository.TeamRepository, op BatchOperation) (interface{}, *Problem) {
+	ctx := r.Context()
+	switch op.Op {
+	case BatchOpCreateUser:
+		var req CreateUserRequest
+		if problem := decodeOperation(op, &req); problem != nil {
+			return nil, problem
+		}
+		user := req.user()
+		if err := users.Create(ctx, &user); err != nil {
+			return nil, operationProblem(r, err, "User")
+		}
+		return newUserResponse(&user), nil
+
+	case BatchOpUpdateUser:
+		var req UpdateUserRequest
+		if problem := requireID(op, "user"); problem != nil {
+			return nil, problem
+		}
+		if problem := decodeOperation(op, &req); problem != nil {
+			return nil, problem
+		}
+		user, err := users.GetByID(op.ID)
+		if err != nil {
+			return nil, operationProblem(r, err, "User")
+		}
+		req.apply(user)
+		user.Version = op.Version
+		user.Teams = nil
+		if err := users.UpdateWithTeams(ctx, user, req.TeamIDs); err != nil {
+			return nil, operationProblem(r, err, "User")
+		}
+		updated, err := users.GetWithTeams(user.ID)
+		if err != nil {
+			return nil, operationProblem(r, err, "User")
+		}
+		return newUserResponse(updated), nil
+
+	case BatchOpDeleteUser:
+		if problem := requireID(op, "user"); problem != nil {
+			return nil, problem
+		}
+		if err := users.Delete(ctx, op.ID, op.Version); err != nil {
+			return nil, operationProblem(r, err, "User")
+		}
+		return nil, nil
+
+	case BatchOpCreateTeam:
+		var req TeamRequest
+		if problem := decodeOperation(op, &req); problem != nil {
+			return nil, problem
+		}
+		team := req.team()
+		if err := teams.Create(ctx, &team); err != nil {
+			return nil, operationProblem(r, err, "Team")
+		}
+		return newTeamResponse(&team), nil
+
+	case BatchOpAddMember, BatchOpRemoveMember:
+		var req AddTeamUserRequest
+		if problem := requireID(op, "team"); problem != nil {
+			return nil, problem
+		}
+		if problem := decodeOperation(op, &req); problem != nil {
+			return nil, problem
+		}
+		return nil, h.changeMembership(ctx, r, teams, op, req.UserID)
+	}
+
+	return nil, &Problem{
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("Unknown operation %q", op.Op),
+	}
+}
+
+func (h *BatchHandler) changeMembership(ctx context.Context, r *http.Request, teams repository.TeamRepository, op BatchOperation, userID uint) *Problem {
+	var err error
+	if op.Op == BatchOpAddMember {
+		err = teams.AddUser(ctx, op.ID, userID)
+	} else {
+		err = teams.RemoveUser(ctx, op.ID, userID)
+	}
+	if err != nil {
+		return operationProblem(r, err, "Team membership")
+	}
+	return nil
+}
+
+func requireID(op BatchOperation, subject string) *Problem {
+	if op.ID == 0 {
+		return &Problem{
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("%s requires the %s id", op.Op, subject),
+		}
+	}
+	return nil
+}
+
+// decodeOperation decodes and validates the operation's data like a request
+// body.
+func decodeOperation(op BatchOperation, dst interface{}) *Problem {
+	errs, err := decodeJSON(op.Data, dst)
+	if err != nil {
+		return &Problem{Status: http.StatusBadRequest, Detail: "Invalid operation data"}
+	}
+	if len(errs) > 0 {
+		return &Problem{
+			Status: http.StatusUnprocessableEntity,
+			Detail: "Validation failed",
+			Code:   CodeValidationFailed,
+			Errors: errs,
+		}
+	}
+	return nil
+}
+
+func operationProblem(r *http.Request, err error, subject string) *Problem {
+	problem := repositoryProblem(r, err, subject)
+	// The request ID identifies the batch, not the operation
+	problem.Instance = ""
+	problem.RequestID = ""
+	return &problem
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+)
+
+// fakeBatchRepository runs atomic batches against the fake repositories,
+// restoring what they held before when the batch fails.
+type fakeBatchRepository struct {
+	users *fakeUserRepository
+	teams *fakeTeamRepository
+}
+
+func (r *fakeBatchRepository) Atomic(ctx context.Context, fn func(users repository.UserRepository, teams repository.TeamRepository) error) error {
+	users, userID := make(map[uint]*models.User), r.users.nextID
+	for id, user := range r.users.users {
+		users[id] = user
+	}
+	teams, members, teamID := make(map[uint]*models.Team), make(map[uint][]uint), r.teams.nextID
+	for id, team := range r.teams.teams {
+		teams[id] = team
+	}
+	for id, userIDs := range r.teams.members {
+		members[id] = append([]uint(nil), userIDs...)
+	}
+
+	if err := fn(r.users, r.teams); err != nil {
+		r.users.users, r.users.nextID = users, userID
+		r.teams.teams, r.teams.members, r.teams.nextID = teams, members, teamID
+		return err
+	}
+	return nil
+}
+
+// batchBody creates a user and a team, adds the user to it, then creates a
+// user with an email that is taken, then another user.
+const batchBody = `{"atomic": %t, "operations": [
+	{"op": "create_user", "data": {"email": "ada@example.com", "name": "Ada"}},
+	{"op": "create_team", "data": {"title": "Platform"}},
+	{"op": "add_member", "id": 1, "data": {"user_id": 2}},
+	{"op": "create_user", "data": {"email": "taken@example.com", "name": "Taken again"}},
+	{"op": "create_user", "data": {"email": "grace@example.com", "name": "Grace"}}
+]}`
+
+func runBatch(t *testing.T, atomic bool) (*httptest.ResponseRecorder, BatchResponse, *fakeUserRepository, *fakeTeamRepository) {
+	t.Helper()
+	users := newFakeUserRepository(models.User{ID: 1, Email: "taken@example.com", Name: "Taken", Version: 1})
+	teams := newFakeTeamRepository(users)
+	h := NewBatchHandler(users, teams, &fakeBatchRepository{users: users, teams: teams})
+
+	rec := serve(h.Run, newRequest(http.MethodPost, "/api/batch", fmt.Sprintf(batchBody, atomic)), nil)
+	var resp BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body, err)
+	}
+	return rec, resp, users, teams
+}
+
+func batchStatuses(resp BatchResponse) []string {
+	statuses := make([]string, len(resp.Results))
+	for i, result := range resp.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestAtomicBatchRollsBack(t *testing.T) {
+	rec, resp, users, teams := runBatch(t, true)
+
+	// The batch answers with the failed operation's status
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+	want := []string{BatchStatusRolledBack, BatchStatusRolledBack, BatchStatusRolledBack, BatchStatusFailed, BatchStatusSkipped}
+	if got := batchStatuses(resp); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses %v, want %v", got, want)
+	}
+	if resp.Committed || resp.SuccessCount != 0 || resp.FailureCount != 1 {
+		t.Errorf("committed %v with %d succeeded and %d failed, want uncommitted with 0 and 1",
+			resp.Committed, resp.SuccessCount, resp.FailureCount)
+	}
+	for _, result := range resp.Results[:3] {
+		if result.Data != nil {
+			t.Errorf("rolled back operation %d returned %v", result.Index, result.Data)
+		}
+	}
+	if failed := resp.Results[3].Error; failed == nil || failed.Code != CodeDuplicateEmail {
+		t.Errorf("error %+v, want %s", failed, CodeDuplicateEmail)
+	}
+
+	if len(users.users) != 1 || len(teams.teams) != 0 || len(teams.members) != 0 {
+		t.Errorf("%d users, %d teams and %d memberships kept, want only the existing user",
+			len(users.users), len(teams.teams), len(teams.members))
+	}
+}
+
+func TestAtomicBatchCommits(t *testing.T) {
+	users := newFakeUserRepository()
+	teams := newFakeTeamRepository(users)
+	h := NewBatchHandler(users, teams, &fakeBatchRepository{users: users, teams: teams})
+	body := `{"atomic": true, "operations": [
+		{"op": "create_user", "data": {"email": "ada@example.com", "name": "Ada"}},
+		{"op": "create_team", "data": {"title": "Platform"}},
+		{"op": "add_member", "id": 1, "data": {"user_id": 1}}
+	]}`
+
+	rec := serve(h.Run, newRequest(http.MethodPost, "/api/batch", body), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body, err)
+	}
+	if !resp.Committed || resp.SuccessCount != 3 {
+		t.Errorf("committed %v with %d succeeded, want committed with 3", resp.Committed, resp.SuccessCount)
+	}
+	if len(users.users) != 1 || len(teams.teams) != 1 || len(teams.members[1]) != 1 {
+		t.Errorf("%d users, %d teams and %d members, want 1 each", len(users.users), len(teams.teams), len(teams.members[1]))
+	}
+}
+
+func TestBestEffortBatchKeepsSuccesses(t *testing.T) {
+	rec, resp, users, teams := runBatch(t, false)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	want := []string{BatchStatusSucceeded, BatchStatusSucceeded, BatchStatusSucceeded, BatchStatusFailed, BatchStatusSucceeded}
+	if got := batchStatuses(resp); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses %v, want %v", got, want)
+	}
+	if resp.SuccessCount != 4 || resp.FailureCount != 1 {
+		t.Errorf("%d succeeded and %d failed, want 4 and 1", resp.SuccessCount, resp.FailureCount)
+	}
+	if len(users.users) != 3 || len(teams.teams) != 1 || len(teams.members[1]) != 1 {
+		t.Errorf("%d users, %d teams and %d members, want 3, 1 and 1", len(users.users), len(teams.teams), len(teams.members[1]))
+	}
+}
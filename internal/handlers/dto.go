@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go-sample/internal/models"
+	"go-sample/internal/validation"
+)
+
+const maxRequestBodySize = 1 << 20
+
+type CreateUserRequest struct {
+	Email      string            `json:"email" validate:"required,email,max=255"`
+	Name       string            `json:"name" validate:"required,max=255"`
+	Source     string            `json:"source" validate:"max=100"`
+	ExternalID string            `json:"external_id" validate:"max=255"`
+	Attributes models.Attributes `json:"attributes"`
+}
+
+func (r CreateUserRequest) Validate() validation.Errors {
+	return validateExternalID(r.Source, r.ExternalID)
+}
+
+func (r CreateUserRequest) user() models.User {
+	return models.User{
+		Email:      r.Email,
+		Name:       r.Name,
+		Source:     strings.TrimSpace(r.Source),
+		ExternalID: strings.TrimSpace(r.ExternalID),
+		Attributes: r.Attributes,
+	}
+}
+
+// UpdateUserRequest replaces a user. Omitted team_ids, source, external_id and
+// attributes are left unchanged.
+type UpdateUserRequest struct {
+	Email      string            `json:"email" validate:"required,email,max=255"`
+	Name       string            `json:"name" validate:"required,max=255"`
+	TeamIDs    []uint            `json:"team_ids"`
+	Source     *string           `json:"source" validate:"max=100"`
+	ExternalID *string           `json:"external_id" validate:"max=255"`
+	Attributes models.Attributes `json:"attributes"`
+}
+
+func (r UpdateUserRequest) Validate() validation.Errors {
+	if r.Source == nil && r.ExternalID == nil {
+		return nil
+	}
+	return validateExternalID(stringValue(r.Source), stringValue(r.ExternalID))
+}
+
+// apply copies the request onto user, keeping omitted fields.
+func (r UpdateUserRequest) apply(user *models.User) {
+	user.Email = r.Email
+	user.Name = r.Name
+	if r.Source != nil || r.ExternalID != nil {
+		user.Source = strings.TrimSpace(stringValue(r.Source))
+		user.ExternalID = strings.TrimSpace(stringValue(r.ExternalID))
+	}
+	if r.Attributes != nil {
+		user.Attributes = r.Attributes
+	}
+}
+
+// TeamRequest is the body of team create and update requests. Omitted
+// source, external_id and attributes are left unchanged on update.
+type TeamRequest struct {
+	Title       string            `json:"title" validate:"required,max=255"`
+	Description string            `json:"description" validate:"max=2000"`
+	ParentID    *uint             `json:"parent_id"`
+	Source      *string           `json:"source" validate:"max=100"`
+	ExternalID  *string           `json:"external_id" validate:"max=255"`
+	Attributes  models.Attributes `json:"attributes"`
+}
+
+func (r TeamRequest) Validate() validation.Errors {
+	if r.Source == nil && r.ExternalID == nil {
+		return nil
+	}
+	return validateExternalID(stringValue(r.Source), stringValue(r.ExternalID))
+}
+
+func (r TeamRequest) team() models.Team {
+	return models.Team{
+		Title:       r.Title,
+		Description: r.Description,
+		ParentID:    r.ParentID,
+		Source:      strings.TrimSpace(stringValue(r.Source)),
+		ExternalID:  strings.TrimSpace(stringValue(r.ExternalID)),
+		Attributes:  r.Attributes,
+	}
+}
+
+// validateExternalID requires source and external_id to be set together.
+func validateExternalID(source, externalID string) validation.Errors {
+	var errs validation.Errors
+	if strings.TrimSpace(source) == "" && strings.TrimSpace(externalID) != "" {
+		errs.Add("source", "is required with external_id")
+	}
+	if strings.TrimSpace(externalID) == "" && strings.TrimSpace(source) != "" {
+		errs.Add("external_id", "is required with source")
+	}
+	return errs
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+type AddTeamUserRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+type InvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// AcceptInvitationRequest answers an invitation. Name is only used when no
+// user has the invited email yet.
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+	Name  string `json:"name" validate:"max=255"`
+}
+
+type DeclineInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type UserResponse struct {
+	ID         uint              `json:"id"`
+	Email      string            `json:"email"`
+	Name       string            `json:"name"`
+	Source     string            `json:"source,omitempty"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Attributes models.Attributes `json:"attributes"`
+	Version    uint              `json:"version"`
+	Teams      []TeamSummary     `json:"teams"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	DeletedAt  *time.Time        `json:"deleted_at"`
+}
+
+type TeamResponse struct {
+	ID          uint              `json:"id"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	ParentID    *uint             `json:"parent_id"`
+	Source      string            `json:"source,omitempty"`
+	ExternalID  string            `json:"external_id,omitempty"`
+	Attributes  models.Attributes `json:"attributes"`
+	Version     uint              `json:"version"`
+	Users       []UserSummary     `json:"users"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	DeletedAt   *time.Time        `json:"deleted_at"`
+}
+
+// TeamSummary is a team as listed inside a user.
+type TeamSummary struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+// TeamTreeNode is a team with its sub-teams nested below it.
+type TeamTreeNode struct {
+	ID          uint           `json:"id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	ParentID    *uint          `json:"parent_id"`
+	Children    []TeamTreeNode `json:"children"`
+}
+
+// UserSummary is a user as listed inside a team.
+type UserSummary struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// InvitationResponse carries the token only in the response to its creation.
+type InvitationResponse struct {
+	ID          uint       `json:"id"`
+	TeamID      uint       `json:"team_id"`
+	Email       string     `json:"email"`
+	Status      string     `json:"status"`
+	InvitedBy   string     `json:"invited_by"`
+	UserID      *uint      `json:"user_id"`
+	Token       string     `json:"token,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type AcceptInvitationResponse struct {
+	Invitation InvitationResponse `json:"invitation"`
+	User       UserSummary        `json:"user"`
+}
+
+func newUserResponse(user *models.User) UserResponse {
+	resp := UserResponse{
+		ID:         user.ID,
+		Email:      user.Email,
+		Name:       user.Name,
+		Source:     user.Source,
+		ExternalID: user.ExternalID,
+		Attributes: attributesOrEmpty(user.Attributes),
+		Version:    user.Version,
+		Teams:      make([]TeamSummary, 0, len(user.Teams)),
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+	}
+	for _, team := range user.Teams {
+		resp.Teams = append(resp.Teams, TeamSummary{ID: team.ID, Title: team.Title})
+	}
+	if user.DeletedAt.Valid {
+		resp.DeletedAt = &user.DeletedAt.Time
+	}
+	return resp
+}
+
+func newUserResponses(users []models.User) []UserResponse {
+	resp := make([]UserResponse, len(users))
+	for i := range users {
+		resp[i] = newUserResponse(&users[i])
+	}
+	return resp
+}
+
+func newTeamResponse(team *models.Team) TeamResponse {
+	resp := TeamResponse{
+		ID:          team.ID,
+		Title:       team.Title,
+		Description: team.Description,
+		ParentID:    team.ParentID,
+		Source:      team.Source,
+		ExternalID:  team.ExternalID,
+		Attributes:  attributesOrEmpty(team.Attributes),
+		Version:     team.Version,
+		Users:       make([]UserSummary, 0, len(team.Users)),
+		CreatedAt:   team.CreatedAt,
+		UpdatedAt:   team.UpdatedAt,
+	}
+	for _, user := range team.Users {
+		resp.Users = append(resp.Users, UserSummary{ID: user.ID, Email: user.Email, Name: user.Name})
+	}
+	if team.DeletedAt.Valid {
+		resp.DeletedAt = &team.DeletedAt.Time
+	}
+	return resp
+}
+
+func newTeamResponses(teams []models.Team) []TeamResponse {
+	resp := make([]TeamResponse, len(teams))
+	for i := range teams {
+		resp[i] = newTeamResponse(&teams[i])
+	}
+	return resp
+}
+
+// newTeamTree nests teams under the root by parent_id. Teams are expected in
+// the form returned by TeamRepository.Subtree.
+func newTeamTree(rootID uint, teams []models.Team) TeamTreeNode {
+	children := make(map[uint][]models.Team)
+	var root models.Team
+	for _, team := range teams {
+		if team.ID == rootID {
+			root = team
+		} else if team.ParentID != nil {
+			children[*team.ParentID] = append(children[*team.ParentID], team)
+		}
+	}
+
+	var build func(team models.Team) TeamTreeNode
+	build = func(team models.Team) TeamTreeNode {
+		node := TeamTreeNode{
+			ID:          team.ID,
+			Title:       team.Title,
+			Description: team.Description,
+			ParentID:    team.ParentID,
+			Children:    make([]TeamTreeNode, 0, len(children[team.ID])),
+		}
+		for _, child := range children[team.ID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+	return build(root)
+}
+
+func newInvitationResponse(invitation *models.Invitation) InvitationResponse {
+	return InvitationResponse{
+		ID:          invitation.ID,
+		TeamID:      invitation.TeamID,
+		Email:       invitation.Email,
+		Status:      invitation.Status,
+		InvitedBy:   invitation.InvitedBy,
+		UserID:      invitation.UserID,
+		ExpiresAt:   invitation.ExpiresAt,
+		RespondedAt: invitation.RespondedAt,
+		CreatedAt:   invitation.CreatedAt,
+	}
+}
+
+func newInvitationResponses(invitations []models.Invitation) []InvitationResponse {
+	responses := make([]InvitationResponse, len(invitations))
+	for i := range invitations {
+		responses[i] = newInvitationResponse(&invitations[i])
+	}
+	return responses
+}
+
+// attributesOrEmpty makes missing attributes render as {} rather than null.
+func attributesOrEmpty(attributes models.Attributes) models.Attributes {
+	if attributes == nil {
+		return models.Attributes{}
+	}
+	return attributes
+}
+
+func newUserSummaries(users []models.User) []UserSummary {
+	summaries := make([]UserSummary, len(users))
+	for i, user := range users {
+		summaries[i] = UserSummary{ID: user.ID, Email: user.Email, Name: user.Name}
+	}
+	return summaries
+}
+
+// decodeRequest decodes the JSON body into dst and validates it. Malformed
+// JSON responds 400; unknown fields, mistyped fields and rule violations are
+// all reported together with 422. It returns false once a response is written.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return false
+	}
+
+	errs, err := decodeJSON(body, dst)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return false
+	}
+	if len(errs) > 0 {
+		ValidationErrorResponse(w, errs)
+		return false
+	}
+	return true
+}
+
+// decodeJSON decodes a JSON object into dst and validates the result, also
+// reporting keys dst has no field for. The error is only set for bodies that
+// are not a JSON object.
+func decodeJSON(body []byte, dst interface{}) (validation.Errors, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || raw == nil {
+		return nil, errors.New("request body must be a JSON object")
+	}
+
+	known := make(map[string]string)
+	dstType := reflect.Indirect(reflect.ValueOf(dst)).Type()
+	for i := 0; i < dstType.NumField(); i++ {
+		name := validation.FieldName(dstType.Field(i))
+		known[strings.ToLower(name)] = name
+	}
+
+	var errs validation.Errors
+	mistyped := false
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// encoding/json matches keys case-insensitively, so must we
+		name, ok := known[strings.ToLower(key)]
+		if !ok {
+			errs.Add(key, "is not a recognized field")
+			continue
+		}
+		field := reflect.New(fieldType(dstType, name))
+		if err := json.Unmarshal(raw[key], field.Interface()); err != nil {
+			errs.Add(name, "has the wrong type")
+			mistyped = true
+		}
+	}
+	if mistyped {
+		return errs, nil
+	}
+
+	// Unknown keys are already reported; validate the fields that are known
+	if err := json.Unmarshal(body, dst); err != nil {
+		return nil, err
+	}
+	return append(errs, validation.Struct(dst)...), nil
+}
+
+func fieldType(structType reflect.Type, jsonName string) reflect.Type {
+	for i := 0; i < structType.NumField(); i++ {
+		if validation.FieldName(structType.Field(i)) == jsonName {
+			return structType.Field(i).Type
+		}
+	}
+	return nil
+}
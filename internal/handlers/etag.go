@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// entityETag is the strong ETag of a versioned user or team.
+func entityETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// contentETag is a weak ETag derived from the response data, for collections
+// that have no single version.
+func contentETag(data interface{}) string {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagResponse writes data with an ETag header, or 304 Not Modified when the
+// request's If-None-Match already names that ETag.
+func ETagResponse(w http.ResponseWriter, r *http.Request, etag string, data interface{}) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+		if ifNoneMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	SuccessResponse(w, http.StatusOK, data)
+}
+
+// ifNoneMatch reports whether header matches etag using weak comparison.
+func ifNoneMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion reads If-Match and returns the version a mutation must see,
+// zero meaning unconditional. When required and the header is missing it
+// responds 428. It writes the error response itself and returns false on
+// failure.
+func expectedVersion(w http.ResponseWriter, r *http.Request, required bool) (uint, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if required {
+			ErrorResponse(w, http.StatusPreconditionRequired, "If-Match header is required")
+			return 0, false
+		}
+		return 0, true
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	// If-Match uses strong comparison, so weak tags never match
+	if strings.Contains(header, ",") || strings.HasPrefix(header, "W/") {
+		ErrorResponse(w, http.StatusPreconditionFailed, "If-Match must be a single strong ETag")
+		return 0, false
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 32)
+	if err != nil || version == 0 {
+		ProblemResponse(w, Problem{
+			Status: http.StatusPreconditionFailed,
+			Detail: "If-Match does not match the current version",
+			Code:   CodeVersionConflict,
+		})
+		return 0, false
+	}
+	return uint(version), true
+}
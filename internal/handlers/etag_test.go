@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"go-sample/internal/models"
+)
+
+func TestGetUserETag(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"no condition", "", http.StatusOK},
+		{"current version", `"3"`, http.StatusNotModified},
+		{"weak current version", `W/"3"`, http.StatusNotModified},
+		{"one of several", `"1", "3"`, http.StatusNotModified},
+		{"any", `*`, http.StatusNotModified},
+		{"stale version", `"2"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository(models.User{ID: 1, Email: "ada@example.com", Name: "Ada", Version: 3})
+			h := NewUserHandler(repo, false)
+			req := newRequest(http.MethodGet, "/api/users/1", "")
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			rec := serve(h.GetByID, req, map[string]string{"id": "1"})
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if etag := rec.Header().Get("ETag"); etag != `"3"` {
+				t.Errorf("ETag %q, want %q", etag, `"3"`)
+			}
+			if tt.status == http.StatusNotModified && rec.Body.Len() > 0 {
+				t.Errorf("304 with a body: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		requireIfMatch bool
+		ifMatch        string
+		status         int
+		code           string
+		// Version stored after the request
+		version uint
+	}{
+		{"required and missing", true, "", http.StatusPreconditionRequired, "precondition_required", 3},
+		{"optional and missing", false, "", http.StatusOK, "", 4},
+		{"current version", true, `"3"`, http.StatusOK, "", 4},
+		{"any version", true, `*`, http.StatusOK, "", 4},
+		{"stale version", true, `"2"`, http.StatusPreconditionFailed, CodeVersionConflict, 3},
+		{"weak tag", true, `W/"3"`, http.StatusPreconditionFailed, "precondition_failed", 3},
+		{"several tags", true, `"3", "4"`, http.StatusPreconditionFailed, "precondition_failed", 3},
+		{"not a version", true, `"abc"`, http.StatusPreconditionFailed, CodeVersionConflict, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository(models.User{ID: 1, Email: "ada@example.com", Name: "Ada", Version: 3})
+			h := NewUserHandler(repo, tt.requireIfMatch)
+			req := newRequest(http.MethodPut, "/api/users/1", `{"email": "ada@example.org", "name": "Ada"}`)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			rec := serve(h.Update, req, map[string]string{"id": "1"})
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code != "" {
+				if problem := decodeProblem(t, rec); problem.Code != tt.code {
+					t.Errorf("code %q, want %q", problem.Code, tt.code)
+				}
+			} else if etag := rec.Header().Get("ETag"); etag != entityETag(tt.version) {
+				t.Errorf("ETag %q, want %q", etag, entityETag(tt.version))
+			}
+			if version := repo.users[1].Version; version != tt.version {
+				t.Errorf("stored version %d, want %d", version, tt.version)
+			}
+		})
+	}
+}
+
+func TestDeleteUserIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+		deleted bool
+	}{
+		{"missing", "", http.StatusPreconditionRequired, false},
+		{"stale version", `"2"`, http.StatusPreconditionFailed, false},
+		{"current version", `"3"`, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository(models.User{ID: 1, Email: "ada@example.com", Name: "Ada", Version: 3})
+			h := NewUserHandler(repo, true)
+			req := newRequest(http.MethodDelete, "/api/users/1", "")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			rec := serve(h.Delete, req, map[string]string{"id": "1"})
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if _, ok := repo.users[1]; ok == tt.deleted {
+				t.Errorf("deleted = %v, want %v", !ok, tt.deleted)
+			}
+		})
+	}
+}
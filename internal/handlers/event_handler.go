@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-sample/internal/stream"
+)
+
+var eventEntityTypes = map[string]bool{"user": true, "team": true, "membership": true}
+
+type EventHandler struct {
+	broker *stream.Broker
+}
+
+// eventFilter narrows the stream to some entity types and/or one team.
+type eventFilter struct {
+	entityTypes map[string]bool
+	teamID      uint
+}
+
+func NewEventHandler(broker *stream.Broker) *EventHandler {
+	return &EventHandler{
+		broker: broker,
+	}
+}
+
+// Stream serves change events as Server-Sent Events. Clients resume after a
+// disconnect by sending the last received id in the Last-Event-ID header.
+func (h *EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" && !stream.ValidID(lastID) {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ErrorResponse(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// The server write timeout would otherwise cut the stream short
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Subscribe before replaying so nothing falls between history and live
+	sub := h.broker.Subscribe()
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if lastID != "" {
+		history, err := h.broker.History(r.Context(), lastID)
+		if err != nil {
+			return
+		}
+		for _, event := range history {
+			if filter.matches(event) {
+				writeEvent(w, event)
+			}
+			lastID = event.ID
+		}
+		flusher.Flush()
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			// Already sent during replay
+			if lastID != "" && stream.CompareIDs(event.ID, lastID) <= 0 {
+				continue
+			}
+			if filter.matches(event) {
+				writeEvent(w, event)
+				flusher.Flush()
+			}
+			lastID = event.ID
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event stream.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func parseEventFilter(r *http.Request) (eventFilter, error) {
+	query := r.URL.Query()
+	filter := eventFilter{}
+
+	if value := query.Get("types"); value != "" {
+		filter.entityTypes = make(map[string]bool)
+		for _, t := range strings.Split(value, ",") {
+			t = strings.TrimSpace(t)
+			if !eventEntityTypes[t] {
+				return filter, fmt.Errorf("invalid type: %s", t)
+			}
+			filter.entityTypes[t] = true
+		}
+	}
+
+	if value := query.Get("team_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid team_id")
+		}
+		filter.teamID = uint(id)
+	}
+
+	return filter, nil
+}
+
+func (f eventFilter) matches(event stream.Event) bool {
+	entityType, _, _ := strings.Cut(event.Type, ".")
+	if f.entityTypes != nil && !f.entityTypes[entityType] {
+		return false
+	}
+	if f.teamID == 0 {
+		return true
+	}
+
+	var data struct {
+		ID      uint   `json:"id"`
+		TeamID  uint   `json:"team_id"`
+		TeamIDs []uint `json:"team_ids"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return false
+	}
+
+	switch entityType {
+	case "team":
+		return data.ID == f.teamID
+	case "membership":
+		return data.TeamID == f.teamID
+	case "user":
+		for _, id := range data.TeamIDs {
+			if id == f.teamID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"go-sample/internal/stream"
+)
+
+func TestEventFilterMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		event stream.Event
+		want  bool
+	}{
+		{"no filter", "", stream.Event{Type: "user.created", Data: []byte(`{"id": 1, "team_ids": []}`)}, true},
+		{"type", "types=user", stream.Event{Type: "user.updated", Data: []byte(`{"id": 1}`)}, true},
+		{"other type", "types=team,membership", stream.Event{Type: "user.updated", Data: []byte(`{"id": 1}`)}, false},
+		{"team", "team_id=7", stream.Event{Type: "team.updated", Data: []byte(`{"id": 7, "user_ids": [1]}`)}, true},
+		{"other team", "team_id=7", stream.Event{Type: "team.updated", Data: []byte(`{"id": 8, "user_ids": [1]}`)}, false},
+		{"membership", "team_id=7", stream.Event{Type: "membership.added", Data: []byte(`{"team_id": 7, "user_id": 1}`)}, true},
+		{"other membership", "team_id=7", stream.Event{Type: "membership.removed", Data: []byte(`{"team_id": 8, "user_id": 1}`)}, false},
+		{"created user", "team_id=7", stream.Event{Type: "user.created", Data: []byte(`{"id": 1, "team_ids": []}`)}, false},
+		{"member updated", "team_id=7", stream.Event{Type: "user.updated", Data: []byte(`{"id": 1, "team_ids": [3, 7]}`)}, true},
+		{"non-member updated", "team_id=7", stream.Event{Type: "user.updated", Data: []byte(`{"id": 1, "team_ids": [3]}`)}, false},
+		{"member deleted", "team_id=7", stream.Event{Type: "user.deleted", Data: []byte(`{"id": 1, "team_ids": [7]}`)}, true},
+		{"member restored", "types=user&team_id=7", stream.Event{Type: "user.restored", Data: []byte(`{"id": 1, "team_ids": [7]}`)}, true},
+		{"member of another type", "types=team&team_id=7", stream.Event{Type: "user.updated", Data: []byte(`{"id": 1, "team_ids": [7]}`)}, false},
+		{"invalid data", "team_id=7", stream.Event{Type: "team.updated", Data: []byte(`[]`)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseEventFilter(newRequest(http.MethodGet, "/api/events?"+tt.query, ""))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := filter.matches(tt.event); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEventFilterInvalid(t *testing.T) {
+	for _, query := range []string{"types=user,widget", "team_id=abc", "team_id=-1"} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := parseEventFilter(newRequest(http.MethodGet, "/api/events?"+query, "")); err == nil {
+				t.Errorf("expected %s to be rejected", query)
+			}
+		})
+	}
+}
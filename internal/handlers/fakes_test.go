@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeUserRepository keeps users in memory. Methods the tests do not use
+// panic through the nil embedded interface.
+type fakeUserRepository struct {
+	repository.UserRepository
+	users  map[uint]*models.User
+	nextID uint
+}
+
+func newFakeUserRepository(users ...models.User) *fakeUserRepository {
+	repo := &fakeUserRepository{users: make(map[uint]*models.User), nextID: 1}
+	for i := range users {
+		user := users[i]
+		repo.users[user.ID] = &user
+		if user.ID >= repo.nextID {
+			repo.nextID = user.ID + 1
+		}
+	}
+	return repo
+}
+
+func (r *fakeUserRepository) Create(ctx context.Context, user *models.User) error {
+	for _, existing := range r.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return repository.ErrDuplicateEmail
+		}
+	}
+	user.ID = r.nextID
+	user.Version = 1
+	r.nextID++
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepository) GetByID(id uint) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) GetWithTeams(id uint) (*models.User, error) {
+	return r.GetByID(id)
+}
+
+func (r *fakeUserRepository) UpdateWithTeams(ctx context.Context, user *models.User, teamIDs []uint) error {
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if user.Version != 0 && user.Version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	user.Version = stored.Version + 1
+	updated := *user
+	r.users[user.ID] = &updated
+	return nil
+}
+
+func (r *fakeUserRepository) Delete(ctx context.Context, id uint, version uint) error {
+	stored, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	if version != 0 && version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *fakeUserRepository) CreateBatch(ctx context.Context, users []*models.User) error {
+	for i, user := range users {
+		for _, other := range users[:i] {
+			if strings.EqualFold(other.Email, user.Email) {
+				return repository.ErrDuplicateEmail
+			}
+		}
+		for _, existing := range r.users {
+			if strings.EqualFold(existing.Email, user.Email) {
+				return repository.ErrDuplicateEmail
+			}
+		}
+	}
+	for _, user := range users {
+		r.Create(ctx, user)
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) FindByEmails(emails []string) ([]models.User, error) {
+	users := []models.User{}
+	for _, user := range r.users {
+		for _, email := range emails {
+			if strings.EqualFold(user.Email, email) {
+				users = append(users, *user)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) FindByExternalIDs(source string, externalIDs []string) ([]models.User, error) {
+	users := []models.User{}
+	for _, user := range r.users {
+		for _, externalID := range externalIDs {
+			if user.Source == source && user.ExternalID == externalID {
+				users = append(users, *user)
+			}
+		}
+	}
+	return users, nil
+}
+
+// fakeImportRepository keeps imports in memory.
+type fakeImportRepository struct {
+	repository.ImportRepository
+	imports map[uint]*models.Import
+}
+
+func (r *fakeImportRepository) Claim(ctx context.Context, id uint, staleBefore time.Time) (*models.Import, error) {
+	imp, ok := r.imports[id]
+	if !ok || imp.Status != models.ImportStatusFailed {
+		return nil, repository.ErrImportNotResumable
+	}
+	imp.Status = models.ImportStatusRunning
+	claimed := *imp
+	return &claimed, nil
+}
+
+func (r *fakeImportRepository) Heartbeat(ctx context.Context, id uint) error {
+	return nil
+}
+
+func (r *fakeImportRepository) SaveProgress(ctx context.Context, file *models.ImportFile) error {
+	files := r.imports[file.ImportID].Files
+	saved := &files[file.FileIndex]
+	saved.Checkpoint, saved.Progress, saved.Completed = file.Checkpoint, file.Progress, file.Completed
+	return nil
+}
+
+func (r *fakeImportRepository) Finish(ctx context.Context, id uint, status string) error {
+	r.imports[id].Status = status
+	return nil
+}
+
+type fakeAuditRepository struct {
+	repository.AuditRepository
+}
+
+func (fakeAuditRepository) Record(ctx context.Context, action, entityType string, entityID uint, before, after interface{}) error {
+	return nil
+}
+
+// fakeSchemaRepository has no attribute schemas.
+type fakeSchemaRepository struct {
+	repository.AttributeSchemaRepository
+}
+
+func (fakeSchemaRepository) Get(entityType string) (*models.AttributeSchema, error) {
+	return nil, repository.ErrNotFound
+}
+
+// serve runs handler for a request with the mux variables vars set.
+func serve(handler http.HandlerFunc, req *http.Request, vars map[string]string) *httptest.ResponseRecorder {
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func newRequest(method, target, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// decodeProblem decodes a problem details response.
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Fatalf("Content-Type %q, want %q; body %s", ct, ProblemContentType, rec.Body)
+	}
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid problem %s: %v", rec.Body, err)
+	}
+	return problem
+}
+
+// fakeWebhookRepository keeps subscriptions in memory.
+type fakeWebhookRepository struct {
+	repository.WebhookRepository
+	subs   map[uint]*models.WebhookSubscription
+	nextID uint
+}
+
+func newFakeWebhookRepository() *fakeWebhookRepository {
+	return &fakeWebhookRepository{subs: make(map[uint]*models.WebhookSubscription), nextID: 1}
+}
+
+func (r *fakeWebhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	sub.ID = r.nextID
+	r.nextID++
+	stored := *sub
+	r.subs[sub.ID] = &stored
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	stored := *sub
+	r.subs[sub.ID] = &stored
+	return nil
+}
+
+// fakeTeamRepository keeps teams and their members in memory. Members must
+// be users of users.
+type fakeTeamRepository struct {
+	repository.TeamRepository
+	users   *fakeUserRepository
+	teams   map[uint]*models.Team
+	members map[uint][]uint
+	nextID  uint
+}
+
+func newFakeTeamRepository(users *fakeUserRepository, teams ...models.Team) *fakeTeamRepository {
+	repo := &fakeTeamRepository{users: users, teams: make(map[uint]*models.Team), members: make(map[uint][]uint), nextID: 1}
+	for i := range teams {
+		team := teams[i]
+		repo.teams[team.ID] = &team
+		if team.ID >= repo.nextID {
+			repo.nextID = team.ID + 1
+		}
+	}
+	return repo
+}
+
+func (r *fakeTeamRepository) Create(ctx context.Context, team *models.Team) error {
+	if team.ParentID != nil {
+		if _, ok := r.teams[*team.ParentID]; !ok {
+			return repository.ErrInvalidReference
+		}
+	}
+	team.ID = r.nextID
+	team.Version = 1
+	r.nextID++
+	stored := *team
+	r.teams[team.ID] = &stored
+	return nil
+}
+
+func (r *fakeTeamRepository) AddUser(ctx context.Context, teamID, userID uint) error {
+	if _, ok := r.teams[teamID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.users.users[userID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	for _, member := range r.members[teamID] {
+		if member == userID {
+			return repository.ErrAlreadyMember
+		}
+	}
+	r.members[teamID] = append(r.members[teamID], userID)
+	return nil
+}
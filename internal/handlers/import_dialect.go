@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Quote handling of a CSV dialect
+const (
+	// Fields may be quoted as in RFC 4180; stray quotes are errors
+	QuotingStandard = "standard"
+	// Quotes may also appear inside unquoted fields and unescaped inside
+	// quoted ones
+	QuotingLazy = "lazy"
+	// Quotes are ordinary characters and fields cannot contain delimiters
+	// or line breaks
+	QuotingNone = "none"
+)
+
+// ImportCodeInvalidDialect rejects a file whose dialect or column mapping
+// cannot be used.
+const ImportCodeInvalidDialect = "invalid_dialect"
+
+// Delimiters tried, in order of preference, when none is given
+var detectedDelimiters = []rune{',', ';', '\t', '|'}
+
+// CSVDialect describes how a file is written. The zero value reads UTF-8
+// with a header row and detects the delimiter; a byte order mark always
+// takes precedence over the encoding.
+type CSVDialect struct {
+	// A single character; empty detects one of , ; tab or | from the first
+	// line
+	Delimiter string `json:"delimiter,omitempty"`
+	// "standard" (default), "lazy" or "none"
+	Quoting string `json:"quoting,omitempty"`
+	// A WHATWG encoding label such as "utf-8" (default), "windows-1252" or
+	// "utf-16le"
+	Encoding string `json:"encoding,omitempty"`
+	// The first row is data; columns are then named by the column mapping,
+	// keyed by 1-based position
+	NoHeader bool `json:"no_header,omitempty"`
+}
+
+// csvFormat reads delimited text in the file's dialect.
+type csvFormat struct{}
+
+func (csvFormat) read(data []byte, file ImportFileRequest) ([]string, [][]string, error) {
+	text, err := decodeText(data, file.Dialect.Encoding)
+	if err != nil {
+		return nil, nil, invalidDialect(err)
+	}
+	records, err := readCSV(text, file.Dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return headerRow(records, file.Dialect.NoHeader, file.Columns)
+}
+
+// readCSV splits the text into records. Records may have fewer or more
+// fields than the header; rows are checked later.
+func readCSV(text string, dialect CSVDialect) ([][]string, error) {
+	delimiter, err := csvDelimiter(dialect.Delimiter, text)
+	if err != nil {
+		return nil, invalidDialect(err)
+	}
+
+	var records [][]string
+	switch dialect.Quoting {
+	case "", QuotingStandard, QuotingLazy:
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.Comma = delimiter
+		reader.LazyQuotes = dialect.Quoting == QuotingLazy
+		reader.FieldsPerRecord = -1
+		records, err = reader.ReadAll()
+	case QuotingNone:
+		records = splitUnquoted(text, delimiter)
+	default:
+		return nil, invalidDialect(fmt.Errorf("unknown quoting %q", dialect.Quoting))
+	}
+	if err != nil {
+		return nil, &fileFailure{code: ImportCodeInvalidCSV, message: fmt.Sprintf("Error reading CSV: %v", err)}
+	}
+	return records, nil
+}
+
+// headerRow splits off the first record as the header, or names the columns
+// by position when there is no header row, and maps the header.
+func headerRow(records [][]string, noHeader bool, columns map[string]string) ([]string, [][]string, error) {
+	if noHeader {
+		header, err := positionalHeader(records, columns)
+		if err != nil {
+			return nil, nil, invalidDialect(err)
+		}
+		return header, records, nil
+	}
+	if len(records) == 0 {
+		return nil, nil, &fileFailure{code: ImportCodeInvalidCSV, message: "Invalid CSV format"}
+	}
+	return mapHeader(records[0], columns), records[1:], nil
+}
+
+func invalidDialect(err error) error {
+	return &fileFailure{code: ImportCodeInvalidDialect, message: fmt.Sprintf("Invalid dialect: %v", err)}
+}
+
+// decodeText converts the file to UTF-8, dropping any byte order mark.
+func decodeText(data []byte, label string) (string, error) {
+	var enc encoding.Encoding = unicode.UTF8
+	if label != "" {
+		var err error
+		if enc, err = htmlindex.Get(label); err != nil {
+			return "", fmt.Errorf("unknown encoding %q", label)
+		}
+	}
+	text, _, err := transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), data)
+	if err != nil {
+		return "", fmt.Errorf("undecodable text: %w", err)
+	}
+	return string(text), nil
+}
+
+func csvDelimiter(delimiter, text string) (rune, error) {
+	if delimiter == "" {
+		return detectDelimiter(text), nil
+	}
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q", delimiter)
+	}
+	return r, nil
+}
+
+// detectDelimiter picks the candidate occurring most often outside quotes in
+// the first line, or a comma if none occurs.
+func detectDelimiter(text string) rune {
+	counts := make(map[rune]int)
+	quoted := false
+	for _, r := range text {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted && (r == '\n' || r == '\r') {
+			break
+		}
+		if !quoted {
+			counts[r]++
+		}
+	}
+
+	best := ','
+	for _, candidate := range detectedDelimiters {
+		if counts[candidate] > counts[best] {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// splitUnquoted splits lines on the delimiter, skipping empty lines like
+// encoding/csv does.
+func splitUnquoted(text string, delimiter rune) [][]string {
+	var records [][]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		records = append(records, strings.Split(line, string(delimiter)))
+	}
+	return records
+}
+
+// mapHeader renames the file's headers to the importer's columns. Headers
+// match the mapping ignoring case and surrounding space; mapping a header to
+// "" ignores its column.
+func mapHeader(header []string, columns map[string]string) []string {
+	if len(columns) == 0 {
+		return header
+	}
+	mapped := make([]string, len(header))
+	for i, name := range header {
+		mapped[i] = name
+		for source, target := range columns {
+			if strings.EqualFold(strings.TrimSpace(source), strings.TrimSpace(name)) {
+				mapped[i] = target
+				break
+			}
+		}
+	}
+	return mapped
+}
+
+// positionalHeader names the columns of a file without a header row from a
+// mapping keyed by 1-based position. Unmapped columns are ignored.
+func positionalHeader(records [][]string, columns map[string]string) ([]string, error) {
+	width := 0
+	for _, record := range records {
+		if len(record) > width {
+			width = len(record)
+		}
+	}
+
+	header := make([]string, width)
+	for position, name := range columns {
+		n, err := strconv.Atoi(strings.TrimSpace(position))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("without a header row columns are mapped by position, got %q", position)
+		}
+		if n <= width {
+			header[n-1] = name
+		}
+	}
+	return header, nil
+}
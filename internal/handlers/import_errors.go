@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// Codes of import errors. Rows rejected by the repositories carry the API
+// error codes instead, e.g. duplicate_email.
+const (
+	ImportCodeInvalidEntityType = "invalid_entity_type"
+	ImportCodeInvalidBase64     = "invalid_base64"
+	ImportCodeInvalidCSV        = "invalid_csv"
+	ImportCodeInvalidHeader     = "invalid_header"
+	ImportCodeSchemaUnavailable = "schema_unavailable"
+	ImportCodeInvalidRow        = "invalid_row"
+	ImportCodeParentNotFound    = "parent_not_found"
+	ImportCodeAmbiguousParent   = "ambiguous_parent"
+)
+
+// errorColumn is the column appended to the rows of an error CSV.
+const errorColumn = "error"
+
+// ImportError explains why a file or one of its rows was rejected.
+type ImportError struct {
+	// Line numbers rows from 1 below the header; it is omitted for errors
+	// about the whole file
+	Line    int    `json:"line,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Row is the rejected record as read from the file
+	Row []string `json:"row,omitempty"`
+}
+
+func (e ImportError) String() string {
+	if e.Column != "" {
+		return e.Column + ": " + e.Message
+	}
+	return e.Message
+}
+
+// problemColumns names the column at fault for repository errors.
+var problemColumns = map[string]string{
+	CodeDuplicateEmail:    "email",
+	CodeDuplicateExternal: "external_id",
+	CodeInvalidReference:  "parent_title",
+	CodeHierarchyCycle:    "parent_title",
+}
+
+// saveErrors describes a failure to save a row. subject names the record as
+// in API errors, e.g. "User".
+func saveErrors(err error, subject string) []ImportError {
+	var attrErr *repository.AttributeError
+	if errors.As(err, &attrErr) {
+		return fieldErrors(attrErr.Errors)
+	}
+	problem, ok := knownProblem(err, subject)
+	if !ok {
+		return []ImportError{{
+			Code:    CodeInternal,
+			Message: fmt.Sprintf("Failed to save %s: %v", strings.ToLower(subject), err),
+		}}
+	}
+	return []ImportError{{Column: problemColumns[problem.Code], Code: problem.Code, Message: problem.Detail}}
+}
+
+// fieldErrors reports validation errors against the columns of the same
+// name.
+func fieldErrors(errs validation.Errors) []ImportError {
+	rowErrs := make([]ImportError, len(errs))
+	for i, fieldErr := range errs {
+		rowErrs[i] = ImportError{Column: fieldErr.Field, Code: CodeValidationFailed, Message: fieldErr.Message}
+	}
+	return rowErrs
+}
+
+// fileError fails a whole file.
+func fileError(code, message string) FileImportResult {
+	return FileImportResult{
+		FailureCount:  1,
+		Errors:        []ImportError{{Code: code, Message: message}},
+		FailedRecords: []string{message},
+	}
+}
+
+// fileFailed fails a whole file with a *fileFailure's code.
+func fileFailed(err error) FileImportResult {
+	var failure *fileFailure
+	if errors.As(err, &failure) {
+		return fileError(failure.code, failure.message)
+	}
+	return fileError(CodeInternal, err.Error())
+}
+
+// errorCSVURL is where the rejected rows of a file can be downloaded.
+func errorCSVURL(importID uint, fileIndex int) string {
+	return fmt.Sprintf("/api/imports/%d/files/%d/errors.csv", importID, fileIndex)
+}
+
+// DownloadErrors serves the rejected rows of an imported file as CSV with an
+// error column. It is written as comma-separated UTF-8 below the mapped
+// header, so once fixed it can be imported as it is, without a dialect or
+// column mapping; the importer ignores the error column.
+func (h *ImportHandler) DownloadErrors(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	importID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+	fileIndex, err := strconv.Atoi(vars["index"])
+	if err != nil || fileIndex < 0 {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid file index")
+		return
+	}
+
+	file, err := h.importRepo.GetFile(uint(importID), fileIndex)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Import file")
+		return
+	}
+	if file.ErrorCSV == nil {
+		ErrorResponse(w, http.StatusNotFound, "Import file has no rejected rows")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="import-%d-%d-%s-errors.csv"`, importID, fileIndex, file.EntityType))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.ErrorCSV)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"go-sample/internal/xlsx"
+)
+
+// Import file formats
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Codes of files rejected before any row is read
+const (
+	ImportCodeInvalidFormat = "invalid_format"
+	ImportCodeInvalidJSON   = "invalid_json"
+	ImportCodeInvalidXLSX   = "invalid_xlsx"
+	ImportCodeSheetNotFound = "sheet_not_found"
+)
+
+// importFormat turns a file into a header and records, so every format goes
+// through the same validation and upsert. Headers are already mapped to the
+// importer's column names.
+type importFormat interface {
+	read(data []byte, file ImportFileRequest) ([]string, [][]string, error)
+}
+
+var importFormats = map[string]importFormat{
+	FormatCSV:    csvFormat{},
+	FormatXLSX:   xlsxFormat{},
+	FormatJSON:   jsonFormat{},
+	FormatNDJSON: jsonFormat{lines: true},
+}
+
+// Formats of the content types a file may be labelled with
+var formatContentTypes = map[string]string{
+	"text/csv":                  FormatCSV,
+	"text/plain":                FormatCSV,
+	"application/csv":           FormatCSV,
+	"text/tab-separated-values": FormatCSV,
+	"application/json":          FormatJSON,
+	"application/x-ndjson":      FormatNDJSON,
+	"application/ndjson":        FormatNDJSON,
+	"application/jsonl":         FormatNDJSON,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": FormatXLSX,
+}
+
+// fileFailure rejects a whole file.
+type fileFailure struct {
+	code    string
+	message string
+}
+
+func (f *fileFailure) Error() string {
+	return f.message
+}
+
+// selectFormat picks the format named by the file, else the one of its
+// content type, else CSV.
+func selectFormat(file ImportFileRequest) (importFormat, error) {
+	name := strings.ToLower(file.Format)
+	if name == "" && file.ContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(file.ContentType)
+		if err != nil {
+			return nil, &fileFailure{code: ImportCodeInvalidFormat, message: fmt.Sprintf("Invalid content type %q", file.ContentType)}
+		}
+		if name = formatContentTypes[mediaType]; name == "" {
+			return nil, &fileFailure{code: ImportCodeInvalidFormat, message: fmt.Sprintf("Unsupported content type %q", mediaType)}
+		}
+	}
+	if name == "" {
+		name = FormatCSV
+	}
+	format, ok := importFormats[name]
+	if !ok {
+		return nil, &fileFailure{code: ImportCodeInvalidFormat, message: fmt.Sprintf("Unknown format %q", file.Format)}
+	}
+	return format, nil
+}
+
+// xlsxFormat reads one sheet of a workbook: the sheet named by the request,
+// else the one named after the entity type, else the only sheet. The dialect
+// applies only in whether the sheet has a header row.
+type xlsxFormat struct{}
+
+func (xlsxFormat) read(data []byte, file ImportFileRequest) ([]string, [][]string, error) {
+	workbook, err := xlsx.Open(data)
+	if err != nil {
+		return nil, nil, &fileFailure{code: ImportCodeInvalidXLSX, message: fmt.Sprintf("Invalid XLSX file: %v", err)}
+	}
+
+	name := file.Sheet
+	if name == "" {
+		name = file.EntityType
+		if names := workbook.SheetNames(); len(names) == 1 {
+			name = names[0]
+		}
+	}
+	rows, err := workbook.Rows(name)
+	if errors.Is(err, xlsx.ErrSheetNotFound) {
+		return nil, nil, &fileFailure{
+			code:    ImportCodeSheetNotFound,
+			message: fmt.Sprintf("Workbook has no sheet %q; sheets: %s", name, strings.Join(workbook.SheetNames(), ", ")),
+		}
+	}
+	if err != nil {
+		return nil, nil, &fileFailure{code: ImportCodeInvalidXLSX, message: fmt.Sprintf("Invalid XLSX file: %v", err)}
+	}
+	if len(rows) == 0 {
+		return nil, nil, &fileFailure{code: ImportCodeInvalidXLSX, message: fmt.Sprintf("Sheet %q is empty", name)}
+	}
+	header, records, err := headerRow(rows, file.Dialect.NoHeader, file.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Sheets leave out trailing empty cells; padding a wide header's rows
+	// must not outgrow what the sheet could hold
+	if len(header)*len(records) > xlsx.MaxCells {
+		return nil, nil, &fileFailure{
+			code:    ImportCodeInvalidXLSX,
+			message: fmt.Sprintf("Sheet %q has more than %d cells once padded to its %d columns", name, xlsx.MaxCells, len(header)),
+		}
+	}
+	for i, record := range records {
+		for len(record) < len(header) {
+			record = append(record, "")
+		}
+		records[i] = record
+	}
+	return header, records, nil
+}
+
+// jsonFormat reads an array of objects, or with lines one object per line.
+// The header lists the keys in order of first appearance; strings are taken
+// as they are, null as empty and other values as their JSON text.
+type jsonFormat struct {
+	lines bool
+}
+
+func (f jsonFormat) read(data []byte, file ImportFileRequest) ([]string, [][]string, error) {
+	// JSON is UTF-8, but tolerate a byte order mark
+	text, err := decodeText(data, "")
+	if err != nil {
+		return nil, nil, invalidJSON(err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+
+	if !f.lines {
+		if err := expectDelim(decoder, '['); err != nil {
+			return nil, nil, invalidJSON(err)
+		}
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	var objects []map[string]string
+	for decoder.More() {
+		object, objectKeys, err := readObject(decoder)
+		if err != nil {
+			return nil, nil, invalidJSON(fmt.Errorf("record %d: %w", len(objects)+1, err))
+		}
+		for _, key := range objectKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		objects = append(objects, object)
+	}
+	if !f.lines {
+		if err := expectDelim(decoder, ']'); err != nil {
+			return nil, nil, invalidJSON(err)
+		}
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, nil, invalidJSON(errors.New("unexpected data after the records"))
+	}
+
+	records := make([][]string, len(objects))
+	for i, object := range objects {
+		records[i] = make([]string, len(keys))
+		for j, key := range keys {
+			records[i][j] = object[key]
+		}
+	}
+	return mapHeader(keys, file.Columns), records, nil
+}
+
+func invalidJSON(err error) error {
+	return &fileFailure{code: ImportCodeInvalidJSON, message: fmt.Sprintf("Invalid JSON: %v", err)}
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %q", delim)
+	}
+	return nil
+}
+
+// readObject reads an object's values as cells, with its keys in order.
+func readObject(decoder *json.Decoder) (map[string]string, []string, error) {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, nil, errors.New("expected an object")
+	}
+	object := make(map[string]string)
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		cell, err := jsonCell(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := object[key]; !ok {
+			keys = append(keys, key)
+		}
+		object[key] = cell
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, nil, err
+	}
+	return object, keys, nil
+}
+
+func jsonCell(value json.RawMessage) (string, error) {
+	switch value[0] {
+	case '"':
+		var s string
+		err := json.Unmarshal(value, &s)
+		return s, err
+	case 'n':
+		return "", nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
@@ -1,31 +1,59 @@
 package handlers
 
 import (
-	"encoding/base64"
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"go-sample/internal/jsonschema"
 	"go-sample/internal/models"
 	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+
+	"github.com/gorilla/mux"
 )
 
 type ImportHandler struct {
-	userRepo repository.UserRepository
-	teamRepo repository.TeamRepository
+	userRepo  repository.UserRepository
+	teamRepo  repository.TeamRepository
+	auditRepo repository.AuditRepository
+	// Runs with their checkpoints and rejected rows
+	importRepo repository.ImportRepository
+	// Schemas used to type extra CSV columns stored as attributes
+	schemaRepo repository.AttributeSchemaRepository
 	// Maximum number of concurrent file processing goroutines
 	maxFileWorkers int
-	// Maximum number of concurrent line processing goroutines per file
-	maxLineWorkers int
+	// Maximum number of chunks of one file written concurrently
+	maxChunkWorkers int
+	// Rows written per transaction
+	chunkSize int
+	// A running import not heard of for this long is taken as abandoned
+	// and can be resumed
+	staleAfter time.Duration
+	// Global slots for concurrently running import requests
+	importSlots chan struct{}
 }
 
 type ImportFileRequest struct {
 	EntityType string `json:"entity_type"` // "users" or "teams"
-	Data       string `json:"data"`        // base64 encoded CSV
+	Data       string `json:"data"`        // base64 encoded file
+	// "csv" (default), "xlsx", "json" or "ndjson"; when empty the format
+	// follows ContentType
+	Format      string `json:"format,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// XLSX sheet to import; defaults to the sheet named after the entity type
+	Sheet   string     `json:"sheet,omitempty"`
+	Dialect CSVDialect `json:"dialect"`
+	// Columns maps headers of the file to the importer's column names,
+	// e.g. {"E-mail Address": "email"}; unmapped headers are kept
+	Columns map[string]string `json:"columns,omitempty"`
 }
 
 type ImportRequest struct {
@@ -33,33 +61,74 @@ type ImportRequest struct {
 }
 
 type FileImportResult struct {
-	EntityType    string   `json:"entity_type"`
-	TotalLines    int      `json:"total_lines"`
-	SuccessCount  int      `json:"success_count"`
-	FailureCount  int      `json:"failure_count"`
+	// Position of the file in the request
+	FileIndex    int    `json:"file_index"`
+	EntityType   string `json:"entity_type"`
+	TotalLines   int    `json:"total_lines"`
+	SuccessCount int    `json:"success_count"`
+	// Rejected lines, or 1 when the whole file was rejected
+	FailureCount int `json:"failure_count"`
+	// Rows matched to an existing record by source and external_id; they
+	// are included in SuccessCount
+	UpdatedCount int `json:"updated_count"`
+	// Errors ordered by line; a line can have several
+	Errors []ImportError `json:"errors,omitempty"`
+	// One summary per rejected line, kept for older clients
 	FailedRecords []string `json:"failed_records,omitempty"`
+	// The database became unavailable before every line was processed;
+	// resuming the import continues from the file's checkpoint
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Download of the rejected rows, set when there are any and the import
+	// was saved
+	ErrorCSVURL string `json:"error_csv_url,omitempty"`
+	ErrorCSV    []byte `json:"-"`
 }
 
 type ImportResponse struct {
+	// ID of the saved import; zero if saving it failed
+	ImportID uint `json:"import_id,omitempty"`
+	// "completed", or "failed" when a file was interrupted
+	Status         string             `json:"status"`
+	Resumed        bool               `json:"resumed,omitempty"`
 	TotalFiles     int                `json:"total_files"`
 	Results        []FileImportResult `json:"results"`
 	ProcessingTime string             `json:"processing_time"`
 }
 
-func NewImportHandler(userRepo repository.UserRepository, teamRepo repository.TeamRepository) *ImportHandler {
+func NewImportHandler(userRepo repository.UserRepository, teamRepo repository.TeamRepository, auditRepo repository.AuditRepository, importRepo repository.ImportRepository, schemaRepo repository.AttributeSchemaRepository, maxConcurrentImports, chunkSize int, staleAfter time.Duration) *ImportHandler {
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	if maxConcurrentImports < 1 {
+		maxConcurrentImports = 1
+	}
+	if staleAfter <= 0 {
+		staleAfter = 2 * time.Minute
+	}
 	return &ImportHandler{
-		userRepo:       userRepo,
-		teamRepo:       teamRepo,
-		maxFileWorkers: 5,  // Process up to 5 files concurrently
-		maxLineWorkers: 20, // Process up to 20 lines concurrently per file
+		userRepo:        userRepo,
+		teamRepo:        teamRepo,
+		auditRepo:       auditRepo,
+		importRepo:      importRepo,
+		schemaRepo:      schemaRepo,
+		maxFileWorkers:  5, // Process up to 5 files concurrently
+		maxChunkWorkers: 4, // Write up to 4 chunks concurrently per file
+		chunkSize:       chunkSize,
+		staleAfter:      staleAfter,
+		importSlots:     make(chan struct{}, maxConcurrentImports),
 	}
 }
 
 func (h *ImportHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
+	if !h.acquireSlot(w) {
+		return
+	}
+	defer h.releaseSlot()
 
 	var req ImportRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
 		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
@@ -69,26 +138,111 @@ func (h *ImportHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep writing rows if the client disconnects mid-import
+	ctx := context.WithoutCancel(r.Context())
+
+	response := h.Import(ctx, req.Files)
+
+	SuccessResponse(w, http.StatusOK, response)
+}
+
+// Get returns an import's status and the checkpoint of each file.
+func (h *ImportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	imp, err := h.importRepo.Get(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Import")
+		return
+	}
+	SuccessResponse(w, http.StatusOK, imp)
+}
+
+// Resume continues an interrupted import from each file's checkpoint.
+func (h *ImportHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+
+	if !h.acquireSlot(w) {
+		return
+	}
+	defer h.releaseSlot()
+
+	// Keep writing rows if the client disconnects mid-import
+	ctx := context.WithoutCancel(r.Context())
+
+	response, err := h.ResumeImport(ctx, uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Import")
+		return
+	}
+	SuccessResponse(w, http.StatusOK, response)
+}
+
+// acquireSlot takes an import slot, rejecting the request instead of
+// queueing it when all are taken.
+func (h *ImportHandler) acquireSlot(w http.ResponseWriter) bool {
+	select {
+	case h.importSlots <- struct{}{}:
+		return true
+	default:
+		w.Header().Set("Retry-After", "5")
+		ErrorResponse(w, http.StatusTooManyRequests, "Too many concurrent imports")
+		return false
+	}
+}
+
+func (h *ImportHandler) releaseSlot() {
+	<-h.importSlots
+}
+
+// Import saves the files as a job, processes them concurrently, records the
+// run in the audit log and returns the per-file results in request order. It
+// backs both POST /api/import and the teamctl import command.
+func (h *ImportHandler) Import(ctx context.Context, files []ImportFileRequest) ImportResponse {
+	return h.run(ctx, h.newJob(ctx, files))
+}
+
+// ResumeImport continues a failed import, or a running one whose process
+// stopped touching it, from the checkpoint of each file it did not
+// complete. It fails with repository.ErrImportNotResumable otherwise.
+func (h *ImportHandler) ResumeImport(ctx context.Context, id uint) (ImportResponse, error) {
+	imp, err := h.importRepo.Claim(ctx, id, time.Now().Add(-h.staleAfter))
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	job, err := resumedJob(imp)
+	if err != nil {
+		if finishErr := h.importRepo.Finish(ctx, id, models.ImportStatusFailed); finishErr != nil {
+			log.Printf("Failed to mark import %d failed: %v", id, finishErr)
+		}
+		return ImportResponse{}, err
+	}
+	return h.run(ctx, job), nil
+}
+
+func (h *ImportHandler) run(ctx context.Context, job *importJob) ImportResponse {
+	startTime := time.Now()
+	stopHeartbeat := h.heartbeat(ctx, job.id)
+
 	// Create a channel to limit concurrent file processing
 	fileWorkerCh := make(chan struct{}, h.maxFileWorkers)
 	var wg sync.WaitGroup
 
-	// Create a slice to store results
-	var mu sync.Mutex
-	results := make([]FileImportResult, 0, len(req.Files))
-
-	// Process each file
-	for i, fileReq := range req.Files {
-		// Validate entity type
-		if fileReq.EntityType != "users" && fileReq.EntityType != "teams" {
-			result := FileImportResult{
-				EntityType:    fileReq.EntityType,
-				FailedRecords: []string{fmt.Sprintf("Invalid entity type: %s", fileReq.EntityType)},
-				FailureCount:  1,
-			}
-			mu.Lock()
-			results = append(results, result)
-			mu.Unlock()
+	// Each file fills the result at its own index
+	results := make([]FileImportResult, len(job.files))
+
+	// Process each file not done already
+	for i := range job.files {
+		if job.files[i].result != nil {
+			results[i] = *job.files[i].result
 			continue
 		}
 
@@ -98,235 +252,820 @@ func (h *ImportHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		fileWorkerCh <- struct{}{}
 
 		// Process file in a goroutine
-		go func(idx int, fileRequest ImportFileRequest) {
+		go func(idx int) {
 			defer wg.Done()
 			defer func() { <-fileWorkerCh }() // Release worker slot when done
 
-			// Process the file and get result
-			result := h.processFile(fileRequest.EntityType, fileRequest.Data)
-			result.EntityType = fileRequest.EntityType
-
-			// Add result to results slice
-			mu.Lock()
-			results = append(results, result)
-			mu.Unlock()
-		}(i, fileReq)
+			results[idx] = h.processFile(ctx, job, idx)
+		}(i)
 	}
 
 	// Wait for all file processing to complete
 	wg.Wait()
 	close(fileWorkerCh)
+	stopHeartbeat()
+
+	status := models.ImportStatusCompleted
+	for i := range results {
+		results[i].FileIndex = i
+		results[i].EntityType = job.files[i].request.EntityType
+		if results[i].Interrupted {
+			status = models.ImportStatusFailed
+		} else if job.id != 0 && results[i].ErrorCSV != nil {
+			results[i].ErrorCSVURL = errorCSVURL(job.id, i)
+		}
+	}
+	if job.id != 0 {
+		if err := h.importRepo.Finish(ctx, job.id, status); err != nil {
+			log.Printf("Failed to finish import %d: %v", job.id, err)
+		}
+	}
 
 	// Create response
 	response := ImportResponse{
-		TotalFiles:     len(req.Files),
+		ImportID:       job.id,
+		Status:         status,
+		Resumed:        job.resumed,
+		TotalFiles:     len(job.files),
 		Results:        results,
 		ProcessingTime: time.Since(startTime).String(),
 	}
 
-	SuccessResponse(w, http.StatusOK, response)
+	// Record the import run itself; individual rows are audited by the repositories
+	if err := h.auditRepo.Record(ctx, repository.AuditActionImport, repository.AuditEntityImport, job.id, nil, response); err != nil {
+		log.Printf("Failed to record import audit entry: %v", err)
+	}
+
+	return response
 }
 
-func (h *ImportHandler) processFile(entityType, data string) FileImportResult {
-	result := FileImportResult{
-		FailedRecords: []string{},
+// heartbeat touches the saved job until the returned function is called, so
+// it is not taken for abandoned and resumed elsewhere meanwhile.
+func (h *ImportHandler) heartbeat(ctx context.Context, id uint) func() {
+	if id == 0 {
+		return func() {}
 	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(h.staleAfter / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := h.importRepo.Heartbeat(ctx, id); err != nil {
+					log.Printf("Failed to touch import %d: %v", id, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
 
-	// Decode base64 data
-	decodedData, err := base64.StdEncoding.DecodeString(data)
+func (h *ImportHandler) processFile(ctx context.Context, job *importJob, idx int) FileImportResult {
+	file := &job.files[idx]
+	tally := h.newTally(ctx, job, idx)
+
+	// Read the rows in the file's format; the header comes back mapped to
+	// the importer's column names
+	format, err := selectFormat(file.request)
 	if err != nil {
-		result.FailedRecords = append(result.FailedRecords, "Invalid base64 data")
-		result.FailureCount++
-		return result
+		return tally.fail(fileFailed(err))
+	}
+	header, records, err := format.read(file.data, file.request)
+	if err != nil {
+		return tally.fail(fileFailed(err))
 	}
 
-	// Parse CSV
-	reader := csv.NewReader(strings.NewReader(string(decodedData)))
-
-	// Read header
-	header, err := reader.Read()
+	// Columns other than the entity's own fields become attributes
+	schema, err := h.attributeSchema(file.request.EntityType)
+	if repository.IsTransient(err) {
+		tally.interrupt(err)
+		return tally.finish(header)
+	}
 	if err != nil {
-		result.FailedRecords = append(result.FailedRecords, "Invalid CSV format")
-		result.FailureCount++
-		return result
+		return tally.fail(fileError(ImportCodeSchemaUnavailable, err.Error()))
 	}
 
+	tally.start(len(records))
+
 	// Process CSV based on entity type
-	if entityType == "users" {
-		return h.processUserCSV(reader, header)
+	if file.request.EntityType == "users" {
+		h.processUserCSV(ctx, header, records, schema, tally)
 	} else {
-		return h.processTeamCSV(reader, header)
+		h.processTeamCSV(ctx, header, records, schema, tally)
 	}
+	return tally.finish(header)
 }
 
-func (h *ImportHandler) processUserCSV(reader *csv.Reader, header []string) FileImportResult {
-	result := FileImportResult{
-		FailedRecords: []string{},
-	}
+// userRow is a validated user line waiting to be written.
+type userRow struct {
+	line   int
+	record []string
+	user   *models.User
+}
+
+// teamRow is a validated team line; its parent is resolved when its level
+// is written.
+type teamRow struct {
+	line        int
+	record      []string
+	parentTitle string
+}
 
+func (h *ImportHandler) processUserCSV(ctx context.Context, header []string, records [][]string, schema *jsonschema.Schema, tally *importTally) {
 	// Validate header
 	requiredFields := []string{"email", "name"}
 	if !validateHeader(header, requiredFields) {
-		result.FailedRecords = append(result.FailedRecords, "Invalid header. Required fields: email, name")
-		result.FailureCount++
-		return result
+		tally.fail(fileError(ImportCodeInvalidHeader, "Invalid header. Required fields: email, name"))
+		return
 	}
 
 	// Find column indexes
 	emailIdx := findColumnIndex(header, "email")
 	nameIdx := findColumnIndex(header, "name")
+	sourceIdx := findColumnIndex(header, "source")
+	externalIdx := findColumnIndex(header, "external_id")
+	attributeIdx := attributeColumns(header, "id", "email", "name", "source", "external_id", errorColumn)
 
-	// Create a channel to limit concurrent line processing
-	lineWorkerCh := make(chan struct{}, h.maxLineWorkers)
-	var wg sync.WaitGroup
-	var mu sync.Mutex // For thread-safe updates to result
+	// Validate every line before writing any; lines before the checkpoint
+	// of a resumed file are done
+	rows := make([]userRow, 0, len(records))
+	for lineNum := tally.resumeFrom; lineNum < len(records); lineNum++ {
+		record := records[lineNum]
+		if len(record) < len(header) {
+			tally.reject(lineNum, record, ImportError{Code: ImportCodeInvalidRow, Message: "Invalid number of fields"})
+			continue
+		}
 
-	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		result.FailedRecords = append(result.FailedRecords, fmt.Sprintf("Error reading CSV: %v", err))
-		result.FailureCount++
-		return result
+		// Apply the same rules as the JSON API
+		req := CreateUserRequest{
+			Email:      strings.TrimSpace(record[emailIdx]),
+			Name:       strings.TrimSpace(record[nameIdx]),
+			Source:     cell(record, sourceIdx),
+			ExternalID: cell(record, externalIdx),
+		}
+		if errs := validation.Struct(&req); len(errs) > 0 {
+			tally.reject(lineNum, record, fieldErrors(errs)...)
+			continue
+		}
+
+		rows = append(rows, userRow{line: lineNum, record: record, user: &models.User{
+			Email:      req.Email,
+			Name:       req.Name,
+			Source:     req.Source,
+			ExternalID: req.ExternalID,
+			Attributes: rowAttributes(record, attributeIdx, schema),
+		}})
 	}
 
-	result.TotalLines = len(records)
+	hasAttributes := len(attributeIdx) > 0
+	h.writeChunks(len(rows), tally, func(start, end int) {
+		h.writeUserChunk(ctx, rows[start:end], hasAttributes, tally)
+	})
+}
 
-	// Process each line
-	for lineNum, record := range records {
-		wg.Add(1)
+// writeUserChunk updates the rows matching an existing user by source and
+// external ID and creates the rest with one batch insert. If the batch fails
+// its rows are retried one by one, so only the offending rows fail. When the
+// database is unavailable the file stops instead, leaving the rows to be
+// retried on resume.
+func (h *ImportHandler) writeUserChunk(ctx context.Context, rows []userRow, hasAttributes bool, tally *importTally) {
+	existing, err := h.existingUsers(rows)
+	if repository.IsTransient(err) {
+		tally.interrupt(err)
+		return
+	}
+	if err != nil {
+		log.Printf("Import: looking up external IDs failed, writing rows one by one: %v", err)
+		h.saveUserRows(ctx, rows, hasAttributes, tally)
+		return
+	}
 
-		// Acquire a worker slot
-		lineWorkerCh <- struct{}{}
+	// A resumed file may have written some of these rows already
+	written, err := h.writtenUsers(rows, tally)
+	if err != nil {
+		tally.interrupt(err)
+		return
+	}
 
-		// Process each line in a separate goroutine
-		go func(record []string, lineNum int) {
-			defer wg.Done()
-			defer func() { <-lineWorkerCh }() // Release worker slot when done
-
-			// Validate record
-			if len(record) < len(header) {
-				mu.Lock()
-				result.FailedRecords = append(result.FailedRecords,
-					fmt.Sprintf("Line %d: Invalid number of fields", lineNum+1))
-				result.FailureCount++
-				mu.Unlock()
-				return
+	var created []userRow
+	var users []*models.User
+	for _, row := range rows {
+		if match, ok := existing[externalKey(row.user.Source, row.user.ExternalID)]; ok {
+			if tally.createdByJob(match.ID) {
+				*row.user = *match
+				tally.succeed(row.line, false)
+				continue
 			}
-
-			// Create user
-			user := &models.User{
-				Email:     record[emailIdx],
-				Name:      record[nameIdx],
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
+			if err := h.updateUser(ctx, match, row.user, hasAttributes); err != nil {
+				if !rowFailed(tally, row.line, row.record, err, "User") {
+					return
+				}
+				continue
 			}
+			tally.succeed(row.line, true)
+			continue
+		}
+		if match, ok := written[strings.ToLower(row.user.Email)]; ok && match.Name == row.user.Name && row.user.ExternalID == "" {
+			*row.user = *match
+			tally.succeed(row.line, false)
+			continue
+		}
+		created = append(created, row)
+		users = append(users, row.user)
+	}
+
+	if err := h.userRepo.CreateBatch(ctx, users); err != nil {
+		if repository.IsTransient(err) {
+			tally.interrupt(err)
+			return
+		}
+		// Drop IDs assigned by the rolled back insert
+		for _, user := range users {
+			user.ID = 0
+		}
+		h.saveUserRows(ctx, created, hasAttributes, tally)
+		return
+	}
+	for _, row := range created {
+		tally.succeed(row.line, false)
+	}
+}
 
-			// Save user
-			if err := h.userRepo.Create(user); err != nil {
-				mu.Lock()
-				result.FailedRecords = append(result.FailedRecords,
-					fmt.Sprintf("Line %d: Failed to create user: %v", lineNum+1, err))
-				result.FailureCount++
-				mu.Unlock()
+func (h *ImportHandler) saveUserRows(ctx context.Context, rows []userRow, hasAttributes bool, tally *importTally) {
+	for _, row := range rows {
+		updated, err := h.saveUser(ctx, row.user, hasAttributes)
+		if err != nil {
+			if !rowFailed(tally, row.line, row.record, err, "User") {
 				return
 			}
-
-			mu.Lock()
-			result.SuccessCount++
-			mu.Unlock()
-		}(record, lineNum)
+			continue
+		}
+		tally.succeed(row.line, updated)
 	}
+}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
-	close(lineWorkerCh)
+// existingUsers finds the users the rows' external IDs refer to, keyed by
+// externalKey.
+func (h *ImportHandler) existingUsers(rows []userRow) (map[string]*models.User, error) {
+	bySource := make(map[string][]string)
+	for _, row := range rows {
+		if row.user.ExternalID != "" {
+			bySource[row.user.Source] = append(bySource[row.user.Source], row.user.ExternalID)
+		}
+	}
 
-	return result
+	existing := make(map[string]*models.User)
+	for source, externalIDs := range bySource {
+		users, err := h.userRepo.FindByExternalIDs(source, externalIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range users {
+			existing[externalKey(users[i].Source, users[i].ExternalID)] = &users[i]
+		}
+	}
+	return existing, nil
 }
 
-func (h *ImportHandler) processTeamCSV(reader *csv.Reader, header []string) FileImportResult {
-	result := FileImportResult{
-		FailedRecords: []string{},
+// writtenUsers finds, when resuming, the users created since the job
+// started with the email of a row without an external ID, keyed by
+// lowercased email.
+func (h *ImportHandler) writtenUsers(rows []userRow, tally *importTally) (map[string]*models.User, error) {
+	written := make(map[string]*models.User)
+	if !tally.resumed {
+		return written, nil
 	}
 
+	var emails []string
+	for _, row := range rows {
+		if row.user.ExternalID == "" {
+			emails = append(emails, row.user.Email)
+		}
+	}
+	users, err := h.userRepo.FindByEmails(emails)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if tally.createdByJob(users[i].ID) {
+			written[strings.ToLower(users[i].Email)] = &users[i]
+		}
+	}
+	return written, nil
+}
+
+func (h *ImportHandler) processTeamCSV(ctx context.Context, header []string, records [][]string, schema *jsonschema.Schema, tally *importTally) {
 	// Validate header
 	requiredFields := []string{"title", "description"}
 	if !validateHeader(header, requiredFields) {
-		result.FailedRecords = append(result.FailedRecords, "Invalid header. Required fields: title, description")
-		result.FailureCount++
-		return result
+		tally.fail(fileError(ImportCodeInvalidHeader, "Invalid header. Required fields: title, description"))
+		return
 	}
 
-	// Find column indexes
+	// Find column indexes; parent_title is optional
 	titleIdx := findColumnIndex(header, "title")
 	descIdx := findColumnIndex(header, "description")
+	parentIdx := findColumnIndex(header, "parent_title")
+	sourceIdx := findColumnIndex(header, "source")
+	externalIdx := findColumnIndex(header, "external_id")
+	attributeIdx := attributeColumns(header, "id", "title", "description", "parent_title", "source", "external_id", errorColumn)
 
-	// Create a channel to limit concurrent line processing
-	lineWorkerCh := make(chan struct{}, h.maxLineWorkers)
-	var wg sync.WaitGroup
-	var mu sync.Mutex // For thread-safe updates to result
+	// IDs of the teams created from this file, by title
+	var createdMu sync.Mutex
+	created := make(map[string]uint)
 
-	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		result.FailedRecords = append(result.FailedRecords, fmt.Sprintf("Error reading CSV: %v", err))
-		result.FailureCount++
-		return result
+	// newTeam validates a line the same way as the JSON API
+	newTeam := func(row teamRow, parentID *uint) *models.Team {
+		source, externalID := cell(row.record, sourceIdx), cell(row.record, externalIdx)
+		req := TeamRequest{
+			Title:       strings.TrimSpace(row.record[titleIdx]),
+			Description: strings.TrimSpace(row.record[descIdx]),
+			ParentID:    parentID,
+			Source:      &source,
+			ExternalID:  &externalID,
+		}
+		if errs := validation.Struct(&req); len(errs) > 0 {
+			tally.reject(row.line, row.record, fieldErrors(errs)...)
+			return nil
+		}
+		return &models.Team{
+			Title:       req.Title,
+			Description: req.Description,
+			ParentID:    req.ParentID,
+			Source:      source,
+			ExternalID:  externalID,
+			Attributes:  rowAttributes(row.record, attributeIdx, schema),
+		}
 	}
 
-	result.TotalLines = len(records)
+	hasAttributes := len(attributeIdx) > 0
+	writeLevel := func(level []teamRow, teams []*models.Team) {
+		h.writeChunks(len(level), tally, func(start, end int) {
+			saved := h.writeTeamChunk(ctx, level[start:end], teams[start:end], hasAttributes, tally)
+			createdMu.Lock()
+			for _, team := range saved {
+				created[team.Title] = team.ID
+			}
+			createdMu.Unlock()
+		})
+	}
 
-	// Process each line
-	for lineNum, record := range records {
-		wg.Add(1)
+	// Titles defined by the lines still to process; rows below them wait
+	// for their parent. Teams from lines before a resumed file's checkpoint
+	// exist already and are found like any other parent.
+	fileTitles := make(map[string]bool)
+	for lineNum := tally.resumeFrom; lineNum < len(records); lineNum++ {
+		record := records[lineNum]
+		if len(record) >= len(header) && strings.TrimSpace(record[titleIdx]) != "" {
+			fileTitles[strings.TrimSpace(record[titleIdx])] = true
+		}
+	}
 
-		// Acquire a worker slot
-		lineWorkerCh <- struct{}{}
+	// The first level holds the rows whose parent, if any, already exists
+	var deferred []teamRow
+	var level []teamRow
+	var teams []*models.Team
+	parents := make(map[string]uint)
+	for lineNum := tally.resumeFrom; lineNum < len(records); lineNum++ {
+		record := records[lineNum]
+		if len(record) < len(header) {
+			tally.reject(lineNum, record, ImportError{Code: ImportCodeInvalidRow, Message: "Invalid number of fields"})
+			continue
+		}
+		row := teamRow{line: lineNum, record: record, parentTitle: cell(record, parentIdx)}
+		if fileTitles[row.parentTitle] {
+			deferred = append(deferred, row)
+			continue
+		}
 
-		// Process each line in a separate goroutine
-		go func(record []string, lineNum int) {
-			defer wg.Done()
-			defer func() { <-lineWorkerCh }() // Release worker slot when done
-
-			// Validate record
-			if len(record) < len(header) {
-				mu.Lock()
-				result.FailedRecords = append(result.FailedRecords,
-					fmt.Sprintf("Line %d: Invalid number of fields", lineNum+1))
-				result.FailureCount++
-				mu.Unlock()
-				return
+		var parentID *uint
+		if row.parentTitle != "" {
+			id, ok := parents[row.parentTitle]
+			if !ok {
+				var parentErr *ImportError
+				var err error
+				id, parentErr, err = h.resolveParent(row.parentTitle)
+				if repository.IsTransient(err) {
+					tally.interrupt(err)
+					return
+				}
+				if parentErr != nil {
+					tally.reject(lineNum, record, *parentErr)
+					continue
+				}
+				parents[row.parentTitle] = id
 			}
+			parentID = &id
+		}
+		if team := newTeam(row, parentID); team != nil {
+			level = append(level, row)
+			teams = append(teams, team)
+		}
+	}
+	writeLevel(level, teams)
 
-			// Create team
-			team := &models.Team{
-				Title:       record[titleIdx],
-				Description: record[descIdx],
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
+	// Create teams below teams from this file, a level at a time, until no
+	// row's parent can be found any more
+	for len(deferred) > 0 && !tally.stopped() {
+		var waiting []teamRow
+		level, teams = nil, nil
+		for _, row := range deferred {
+			id, ok := created[row.parentTitle]
+			if !ok {
+				waiting = append(waiting, row)
+				continue
+			}
+			if team := newTeam(row, &id); team != nil {
+				level = append(level, row)
+				teams = append(teams, team)
+			}
+		}
+		if len(waiting) == len(deferred) {
+			for _, row := range waiting {
+				tally.reject(row.line, row.record, ImportError{
+					Column:  "parent_title",
+					Code:    ImportCodeParentNotFound,
+					Message: fmt.Sprintf("Parent team %q was not imported", row.parentTitle),
+				})
 			}
+			break
+		}
+		writeLevel(level, teams)
+		deferred = waiting
+	}
+}
 
-			// Save team
-			if err := h.teamRepo.Create(team); err != nil {
-				mu.Lock()
-				result.FailedRecords = append(result.FailedRecords,
-					fmt.Sprintf("Line %d: Failed to create team: %v", lineNum+1, err))
-				result.FailureCount++
-				mu.Unlock()
-				return
+// writeTeamChunk is writeUserChunk for teams. It returns the teams saved.
+func (h *ImportHandler) writeTeamChunk(ctx context.Context, rows []teamRow, teams []*models.Team, hasAttributes bool, tally *importTally) []*models.Team {
+	existing, err := h.existingTeams(teams)
+	if repository.IsTransient(err) {
+		tally.interrupt(err)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Import: looking up external IDs failed, writing rows one by one: %v", err)
+		return h.saveTeamRows(ctx, rows, teams, hasAttributes, tally)
+	}
+
+	// A resumed file may have written some of these rows already
+	written, err := h.writtenTeams(teams, tally)
+	if err != nil {
+		tally.interrupt(err)
+		return nil
+	}
+
+	var saved []*models.Team
+	var createdRows []teamRow
+	var created []*models.Team
+	for i, team := range teams {
+		if match, ok := existing[externalKey(team.Source, team.ExternalID)]; ok {
+			if tally.createdByJob(match.ID) {
+				*team = *match
+				tally.succeed(rows[i].line, false)
+				saved = append(saved, team)
+				continue
+			}
+			if err := h.updateTeam(ctx, match, team, hasAttributes); err != nil {
+				if !rowFailed(tally, rows[i].line, rows[i].record, err, "Team") {
+					return saved
+				}
+				continue
+			}
+			tally.succeed(rows[i].line, true)
+			saved = append(saved, team)
+			continue
+		}
+		if match := written[i]; match != nil {
+			*team = *match
+			tally.succeed(rows[i].line, false)
+			saved = append(saved, team)
+			continue
+		}
+		createdRows = append(createdRows, rows[i])
+		created = append(created, team)
+	}
+
+	if err := h.teamRepo.CreateBatch(ctx, created); err != nil {
+		if repository.IsTransient(err) {
+			tally.interrupt(err)
+			return saved
+		}
+		// Drop IDs assigned by the rolled back insert
+		for _, team := range created {
+			team.ID = 0
+		}
+		return append(saved, h.saveTeamRows(ctx, createdRows, created, hasAttributes, tally)...)
+	}
+	for _, row := range createdRows {
+		tally.succeed(row.line, false)
+	}
+	return append(saved, created...)
+}
+
+func (h *ImportHandler) saveTeamRows(ctx context.Context, rows []teamRow, teams []*models.Team, hasAttributes bool, tally *importTally) []*models.Team {
+	var saved []*models.Team
+	for i, team := range teams {
+		updated, err := h.saveTeam(ctx, team, hasAttributes)
+		if err != nil {
+			if !rowFailed(tally, rows[i].line, rows[i].record, err, "Team") {
+				return saved
 			}
+			continue
+		}
+		tally.succeed(rows[i].line, updated)
+		saved = append(saved, team)
+	}
+	return saved
+}
+
+// existingTeams is existingUsers for teams.
+func (h *ImportHandler) existingTeams(teams []*models.Team) (map[string]*models.Team, error) {
+	bySource := make(map[string][]string)
+	for _, team := range teams {
+		if team.ExternalID != "" {
+			bySource[team.Source] = append(bySource[team.Source], team.ExternalID)
+		}
+	}
+
+	existing := make(map[string]*models.Team)
+	for source, externalIDs := range bySource {
+		found, err := h.teamRepo.FindByExternalIDs(source, externalIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range found {
+			existing[externalKey(found[i].Source, found[i].ExternalID)] = &found[i]
+		}
+	}
+	return existing, nil
+}
 
-			mu.Lock()
-			result.SuccessCount++
-			mu.Unlock()
-		}(record, lineNum)
+// writtenTeams finds, when resuming, the team each row without an external
+// ID may have created since the job started: one with the same title,
+// description and parent. The result is aligned with teams and nil where no
+// such team exists; a team is matched to one row only.
+func (h *ImportHandler) writtenTeams(teams []*models.Team, tally *importTally) ([]*models.Team, error) {
+	written := make([]*models.Team, len(teams))
+	if !tally.resumed {
+		return written, nil
+	}
+
+	var titles []string
+	for _, team := range teams {
+		if team.ExternalID == "" {
+			titles = append(titles, team.Title)
+		}
+	}
+	found, err := h.teamRepo.FindByTitles(titles)
+	if err != nil {
+		return nil, err
 	}
 
-	// Wait for all goroutines to finish
+	matched := make(map[uint]bool)
+	for i, team := range teams {
+		if team.ExternalID != "" {
+			continue
+		}
+		for j := range found {
+			candidate := &found[j]
+			if matched[candidate.ID] || !tally.createdByJob(candidate.ID) || candidate.Title != team.Title ||
+				candidate.Description != team.Description || !sameParent(candidate.ParentID, team.ParentID) {
+				continue
+			}
+			matched[candidate.ID] = true
+			written[i] = candidate
+			break
+		}
+	}
+	return written, nil
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// writeChunks calls write for consecutive chunks of n rows, running up to
+// maxChunkWorkers of them at a time, and checkpoints the file after each.
+// No further chunks start once the file is interrupted.
+func (h *ImportHandler) writeChunks(n int, tally *importTally, write func(start, end int)) {
+	workers := make(chan struct{}, h.maxChunkWorkers)
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += h.chunkSize {
+		end := start + h.chunkSize
+		if end > n {
+			end = n
+		}
+
+		workers <- struct{}{}
+		if tally.stopped() {
+			<-workers
+			break
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			write(start, end)
+			tally.saveCheckpoint()
+		}(start, end)
+	}
 	wg.Wait()
-	close(lineWorkerCh)
+}
+
+func externalKey(source, externalID string) string {
+	return source + "\x00" + externalID
+}
+
+// resolveParent finds the ID of the existing team with the given title. It
+// returns the import error a missing or ambiguous parent rejects the row
+// with, or the error of the lookup itself.
+func (h *ImportHandler) resolveParent(title string) (uint, *ImportError, error) {
+	teams, err := h.teamRepo.FindByTitle(title)
+	if err != nil {
+		return 0, &ImportError{
+			Column:  "parent_title",
+			Code:    CodeInternal,
+			Message: fmt.Sprintf("Failed to look up parent team %q: %v", title, err),
+		}, err
+	}
+	switch len(teams) {
+	case 0:
+		return 0, &ImportError{
+			Column:  "parent_title",
+			Code:    ImportCodeParentNotFound,
+			Message: fmt.Sprintf("Parent team %q not found", title),
+		}, nil
+	case 1:
+		return teams[0].ID, nil, nil
+	default:
+		return 0, &ImportError{
+			Column:  "parent_title",
+			Code:    ImportCodeAmbiguousParent,
+			Message: fmt.Sprintf("Parent team %q is ambiguous, %d teams have that title", title, len(teams)),
+		}, nil
+	}
+}
 
-	return result
+// saveUser creates the user unless a user with the same source and external
+// ID exists, in which case that user's fields are overwritten and true is
+// returned. Attributes are only overwritten when the file has attribute
+// columns.
+func (h *ImportHandler) saveUser(ctx context.Context, user *models.User, hasAttributes bool) (bool, error) {
+	if user.ExternalID == "" {
+		return false, h.userRepo.Create(ctx, user)
+	}
+	existing, err := h.userRepo.GetByExternalID(user.Source, user.ExternalID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, h.userRepo.Create(ctx, user)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, h.updateUser(ctx, existing, user, hasAttributes)
+}
+
+// updateUser overwrites existing with the imported user and copies the
+// result back into user.
+func (h *ImportHandler) updateUser(ctx context.Context, existing, user *models.User, hasAttributes bool) error {
+	existing.Email = user.Email
+	existing.Name = user.Name
+	if hasAttributes {
+		existing.Attributes = user.Attributes
+	}
+	// Memberships are not part of the file; keep Save from touching them
+	existing.Teams = nil
+	existing.Version = 0
+	if err := h.userRepo.Update(ctx, existing); err != nil {
+		return err
+	}
+	*user = *existing
+	return nil
+}
+
+// saveTeam is saveUser for teams.
+func (h *ImportHandler) saveTeam(ctx context.Context, team *models.Team, hasAttributes bool) (bool, error) {
+	if team.ExternalID == "" {
+		return false, h.teamRepo.Create(ctx, team)
+	}
+	existing, err := h.teamRepo.GetByExternalID(team.Source, team.ExternalID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, h.teamRepo.Create(ctx, team)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, h.updateTeam(ctx, existing, team, hasAttributes)
+}
+
+// updateTeam is updateUser for teams.
+func (h *ImportHandler) updateTeam(ctx context.Context, existing, team *models.Team, hasAttributes bool) error {
+	existing.Title = team.Title
+	existing.Description = team.Description
+	existing.ParentID = team.ParentID
+	if hasAttributes {
+		existing.Attributes = team.Attributes
+	}
+	// Members are not part of the file; keep Save from touching them
+	existing.Users = nil
+	existing.Version = 0
+	if err := h.teamRepo.Update(ctx, existing); err != nil {
+		return err
+	}
+	*team = *existing
+	return nil
+}
+
+// cell returns the trimmed value of an optional column, or "" if the header
+// has no such column.
+func cell(record []string, idx int) string {
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+// attributeSchema returns the compiled attribute schema for the entity type,
+// or nil when none is registered.
+func (h *ImportHandler) attributeSchema(entityType string) (*jsonschema.Schema, error) {
+	stored, err := h.schemaRepo.Get(entityType)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attribute schema: %w", err)
+	}
+	schema, err := jsonschema.Compile(stored.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute schema: %v", err)
+	}
+	return schema, nil
+}
+
+// attributeColumns maps the index of every column not in fields to its
+// attribute name.
+func attributeColumns(header []string, fields ...string) map[int]string {
+	columns := make(map[int]string)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || containsFold(fields, name) {
+			continue
+		}
+		columns[i] = name
+	}
+	return columns
+}
+
+// rowAttributes collects the non-empty attribute cells of a record. Cells are
+// converted to the number or boolean the schema declares for the attribute
+// and otherwise kept as strings.
+func rowAttributes(record []string, columns map[int]string, schema *jsonschema.Schema) models.Attributes {
+	attributes := models.Attributes{}
+	for idx, name := range columns {
+		value := strings.TrimSpace(record[idx])
+		if value == "" {
+			continue
+		}
+		var types []string
+		if schema != nil {
+			types = schema.PropertyTypes(name)
+		}
+		attributes[name] = attributeValue(value, types)
+	}
+	return attributes
+}
+
+func attributeValue(value string, types []string) interface{} {
+	if containsFold(types, "string") {
+		return value
+	}
+	for _, t := range types {
+		switch t {
+		case "integer", "number":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				return n
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(value); err == nil {
+				return b
+			}
+		}
+	}
+	return value
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
 }
 
 // Helper functions
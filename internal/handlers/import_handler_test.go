@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-sample/internal/models"
+)
+
+// resumableImport is a failed import of users whose first line was
+// checkpointed. User 1 existed before it started; user 2 was written by the
+// run that failed, after the checkpoint.
+func resumableImport(t *testing.T) (*ImportHandler, *fakeUserRepository, *fakeImportRepository) {
+	t.Helper()
+	users := newFakeUserRepository(
+		models.User{ID: 1, Email: "old@example.com", Name: "Old", Version: 1},
+		models.User{ID: 2, Email: "Ada@Example.com", Name: "Ada", Version: 1},
+	)
+	options, _ := json.Marshal(ImportFileRequest{EntityType: "users"})
+	progress, _ := json.Marshal(FileImportResult{SuccessCount: 1})
+	imports := &fakeImportRepository{imports: map[uint]*models.Import{
+		7: {ID: 7, Status: models.ImportStatusFailed, LastUserID: 1, Files: []models.ImportFile{{
+			ImportID:   7,
+			EntityType: "users",
+			Options:    models.JSON(options),
+			Data: []byte("email,name\n" +
+				"first@example.com,First\n" +
+				"ada@example.com,Ada\n" +
+				"old@example.com,Old\n" +
+				"new@example.com,New\n"),
+			Checkpoint: 1,
+			Progress:   models.JSON(progress),
+		}}},
+	}}
+	h := NewImportHandler(users, nil, fakeAuditRepository{}, imports, fakeSchemaRepository{}, 1, 100, time.Minute)
+	return h, users, imports
+}
+
+func TestResumeImport(t *testing.T) {
+	h, users, imports := resumableImport(t)
+
+	rec := serve(h.Resume, newRequest(http.MethodPost, "/api/imports/7/resume", ""), map[string]string{"id": "7"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body struct {
+		Data ImportResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %s: %v", rec.Body, err)
+	}
+	resp := body.Data
+	if !resp.Resumed || resp.Status != models.ImportStatusCompleted || len(resp.Results) != 1 {
+		t.Fatalf("response %+v, want one completed resumed file", resp)
+	}
+
+	// The checkpointed line counts from the saved progress, Ada's line
+	// matches the user the failed run wrote despite the email's case, and
+	// old@example.com predates the import so it is a duplicate
+	result := resp.Results[0]
+	if result.SuccessCount != 3 || result.FailureCount != 1 {
+		t.Errorf("%d succeeded and %d failed, want 3 and 1", result.SuccessCount, result.FailureCount)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 3 {
+		t.Errorf("errors %+v, want one on line 3", result.Errors)
+	}
+	if len(users.users) != 3 {
+		t.Errorf("%d users, want 3: the first line must not be written again nor Ada twice", len(users.users))
+	}
+	if found, _ := users.FindByEmails([]string{"first@example.com"}); len(found) != 0 {
+		t.Errorf("checkpointed line written again")
+	}
+	if found, _ := users.FindByEmails([]string{"new@example.com"}); len(found) != 1 {
+		t.Errorf("new@example.com not created")
+	}
+	if status := imports.imports[7].Status; status != models.ImportStatusCompleted {
+		t.Errorf("import status %q, want %q", status, models.ImportStatusCompleted)
+	}
+}
+
+func TestResumeImportNotResumable(t *testing.T) {
+	h, _, imports := resumableImport(t)
+	imports.imports[7].Status = models.ImportStatusCompleted
+
+	rec := serve(h.Resume, newRequest(http.MethodPost, "/api/imports/7/resume", ""), map[string]string{"id": "7"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+}
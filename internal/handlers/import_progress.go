@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+)
+
+// importJob is an import being run, new or resumed.
+type importJob struct {
+	// Zero when the import could not be saved; nothing is checkpointed then
+	id      uint
+	resumed bool
+	// The highest user and team IDs when the import started
+	lastUserID uint
+	lastTeamID uint
+	files      []jobFile
+}
+
+type jobFile struct {
+	// The file's request without its data
+	request ImportFileRequest
+	data    []byte
+	// Set for files done before the job ran, e.g. with an invalid entity type
+	result *FileImportResult
+	// Lines saved before the import was interrupted, and their outcome
+	checkpoint int
+	progress   *FileImportResult
+}
+
+// newJob decodes the files and saves them as a running import, so it can be
+// resumed if the database fails midway.
+func (h *ImportHandler) newJob(ctx context.Context, files []ImportFileRequest) *importJob {
+	job := &importJob{files: make([]jobFile, len(files))}
+	imp := models.Import{Status: models.ImportStatusRunning, Files: make([]models.ImportFile, len(files))}
+	for i, fileReq := range files {
+		file := &job.files[i]
+		file.request = fileReq
+		file.request.Data = ""
+
+		// Validate entity type
+		if fileReq.EntityType != "users" && fileReq.EntityType != "teams" {
+			result := fileError(ImportCodeInvalidEntityType, fmt.Sprintf("Invalid entity type: %s", fileReq.EntityType))
+			file.result = &result
+		} else if data, err := base64.StdEncoding.DecodeString(fileReq.Data); err != nil {
+			result := fileError(ImportCodeInvalidBase64, "Invalid base64 data")
+			file.result = &result
+		} else {
+			file.data = data
+		}
+
+		options, _ := json.Marshal(file.request)
+		imp.Files[i] = models.ImportFile{
+			FileIndex:  i,
+			EntityType: fileReq.EntityType,
+			Options:    models.JSON(options),
+			Data:       file.data,
+		}
+		if file.result != nil {
+			progress, _ := json.Marshal(file.result)
+			imp.Files[i].Progress = models.JSON(progress)
+			imp.Files[i].Completed = true
+		}
+	}
+
+	if err := h.importRepo.Create(ctx, &imp); err != nil {
+		log.Printf("Failed to save import, it cannot be resumed: %v", err)
+		return job
+	}
+	job.id = imp.ID
+	return job
+}
+
+// resumedJob rebuilds a claimed import's job: completed files keep their
+// results and the others continue from their checkpoint.
+func resumedJob(imp *models.Import) (*importJob, error) {
+	job := &importJob{id: imp.ID, resumed: true, lastUserID: imp.LastUserID, lastTeamID: imp.LastTeamID, files: make([]jobFile, len(imp.Files))}
+	for i, saved := range imp.Files {
+		file := &job.files[i]
+		if err := json.Unmarshal(saved.Options, &file.request); err != nil {
+			return nil, fmt.Errorf("import %d file %d has invalid options: %w", imp.ID, saved.FileIndex, err)
+		}
+		var progress FileImportResult
+		if len(saved.Progress) > 0 {
+			if err := json.Unmarshal(saved.Progress, &progress); err != nil {
+				return nil, fmt.Errorf("import %d file %d has invalid progress: %w", imp.ID, saved.FileIndex, err)
+			}
+		}
+
+		if saved.Completed {
+			progress.ErrorCSV = saved.ErrorCSV
+			file.result = &progress
+			continue
+		}
+		file.data = saved.Data
+		file.checkpoint = saved.Checkpoint
+		file.progress = &progress
+	}
+	return job, nil
+}
+
+// Outcome of a line
+type lineOutcome int
+
+const (
+	linePending lineOutcome = iota
+	lineCreated
+	lineUpdated
+	lineRejected
+	// Counted in the progress of an earlier run
+	lineSaved
+)
+
+// importTally collects the outcome of rows written concurrently and
+// checkpoints the file: the leading lines that all have an outcome are
+// saved, so a resumed import starts below them.
+type importTally struct {
+	mu sync.Mutex
+	// Outcome of each line, numbered from 0 below the header
+	outcomes []lineOutcome
+	rejected map[int]rejectedRow
+	// First line to process; lines above it were done by an earlier run
+	resumeFrom int
+	// Lines below checkpoint are counted in progress
+	checkpoint int
+	progress   FileImportResult
+	// Set once the database failed; the remaining lines are left to resume
+	interrupted error
+	// Set for files rejected as a whole
+	failed *FileImportResult
+	// Set when resuming: records with IDs above lastID may come from lines
+	// written by an earlier run
+	resumed bool
+	lastID  uint
+
+	// Serializes saves so checkpoints are stored in order
+	saveMu sync.Mutex
+	// Stores the file's progress; nil when the import was not saved
+	save func(file models.ImportFile)
+}
+
+type rejectedRow struct {
+	line   int
+	record []string
+	errs   []ImportError
+}
+
+// newTally starts the tally of a job's file from its checkpoint, if any.
+func (h *ImportHandler) newTally(ctx context.Context, job *importJob, idx int) *importTally {
+	file := &job.files[idx]
+	t := &importTally{rejected: make(map[int]rejectedRow)}
+	if job.id != 0 {
+		t.save = func(saved models.ImportFile) {
+			saved.ImportID = job.id
+			saved.FileIndex = idx
+			if err := h.importRepo.SaveProgress(ctx, &saved); err != nil {
+				log.Printf("Failed to save progress of import %d file %d: %v", job.id, idx, err)
+			}
+		}
+	}
+	if job.resumed {
+		t.resumed = true
+		t.lastID = job.lastUserID
+		if file.request.EntityType == "teams" {
+			t.lastID = job.lastTeamID
+		}
+	}
+	if file.progress != nil {
+		t.resumeFrom = file.checkpoint
+		t.checkpoint = file.checkpoint
+		t.progress = *file.progress
+		// Rejected rows above the checkpoint still go in the error CSV
+		for _, rowErr := range t.progress.Errors {
+			row := t.rejected[rowErr.Line-1]
+			row.line = rowErr.Line - 1
+			row.record = rowErr.Row
+			row.errs = append(row.errs, rowErr)
+			t.rejected[row.line] = row
+		}
+	}
+	return t
+}
+
+// start sizes the tally for the file's lines.
+func (t *importTally) start(lines int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes = make([]lineOutcome, lines)
+	for i := 0; i < t.resumeFrom && i < lines; i++ {
+		t.outcomes[i] = lineSaved
+	}
+}
+
+func (t *importTally) succeed(lineNum int, updated bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes[lineNum] = lineCreated
+	if updated {
+		t.outcomes[lineNum] = lineUpdated
+	}
+}
+
+// reject records a failed line, numbered from 0 below the header.
+func (t *importTally) reject(lineNum int, record []string, errs ...ImportError) {
+	for i := range errs {
+		errs[i].Line = lineNum + 1
+		errs[i].Row = record
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rejected[lineNum] = rejectedRow{line: lineNum, record: record, errs: errs}
+	t.outcomes[lineNum] = lineRejected
+}
+
+// interrupt stops the file after a database failure. Lines without an
+// outcome are left to a resumed import.
+func (t *importTally) interrupt(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interrupted == nil {
+		log.Printf("Import interrupted: %v", err)
+		t.interrupted = err
+	}
+}
+
+func (t *importTally) stopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interrupted != nil
+}
+
+// createdByJob tells whether the record with the given ID may come from a
+// line written by an earlier run of a resumed import.
+func (t *importTally) createdByJob(id uint) bool {
+	return t.resumed && id > t.lastID
+}
+
+// rowFailed records a failure to save a row. It interrupts the file instead
+// when the database is unavailable, and then returns false.
+func rowFailed(t *importTally, lineNum int, record []string, err error, subject string) bool {
+	if repository.IsTransient(err) {
+		t.interrupt(err)
+		return false
+	}
+	t.reject(lineNum, record, saveErrors(err, subject)...)
+	return true
+}
+
+// saveCheckpoint moves the checkpoint below the leading lines that have an
+// outcome and saves it.
+func (t *importTally) saveCheckpoint() {
+	if t.save == nil {
+		return
+	}
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	start := t.checkpoint
+	for t.checkpoint < len(t.outcomes) && t.outcomes[t.checkpoint] != linePending {
+		switch t.outcomes[t.checkpoint] {
+		case lineCreated:
+			t.progress.SuccessCount++
+		case lineUpdated:
+			t.progress.SuccessCount++
+			t.progress.UpdatedCount++
+		case lineRejected:
+			t.progress.FailureCount++
+			t.progress.Errors = append(t.progress.Errors, t.rejected[t.checkpoint].errs...)
+		}
+		t.checkpoint++
+	}
+	if t.checkpoint == start {
+		t.mu.Unlock()
+		return
+	}
+	file := models.ImportFile{Checkpoint: t.checkpoint}
+	file.Progress, _ = json.Marshal(t.progress)
+	t.mu.Unlock()
+
+	t.save(file)
+}
+
+// fail rejects the whole file and completes it.
+func (t *importTally) fail(result FileImportResult) FileImportResult {
+	t.mu.Lock()
+	t.failed = &result
+	t.mu.Unlock()
+
+	t.complete(result)
+	return result
+}
+
+// finish reports the file's outcome. An interrupted file is checkpointed;
+// otherwise the file is completed and its rejected rows are reported in file
+// order, however the writes interleaved, and rendered as an error CSV below
+// the file's header.
+func (t *importTally) finish(header []string) FileImportResult {
+	t.mu.Lock()
+	if t.failed != nil {
+		t.mu.Unlock()
+		return *t.failed
+	}
+	result := t.result(header)
+	interrupted := t.interrupted != nil
+	t.mu.Unlock()
+
+	if interrupted {
+		t.saveCheckpoint()
+		result.Interrupted = true
+		result.ErrorCSV = nil
+		return result
+	}
+	t.complete(result)
+	return result
+}
+
+func (t *importTally) complete(result FileImportResult) {
+	if t.save == nil {
+		return
+	}
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	progress, _ := json.Marshal(result)
+	t.save(models.ImportFile{
+		Checkpoint: result.TotalLines,
+		Progress:   models.JSON(progress),
+		Completed:  true,
+		ErrorCSV:   result.ErrorCSV,
+	})
+}
+
+// result counts the lines with an outcome and renders the rejected ones.
+func (t *importTally) result(header []string) FileImportResult {
+	result := FileImportResult{
+		TotalLines:   len(t.outcomes),
+		SuccessCount: t.progress.SuccessCount,
+		FailureCount: t.progress.FailureCount,
+		UpdatedCount: t.progress.UpdatedCount,
+	}
+	for _, outcome := range t.outcomes[min(t.checkpoint, len(t.outcomes)):] {
+		switch outcome {
+		case lineCreated:
+			result.SuccessCount++
+		case lineUpdated:
+			result.SuccessCount++
+			result.UpdatedCount++
+		case lineRejected:
+			result.FailureCount++
+		}
+	}
+
+	rejected := make([]rejectedRow, 0, len(t.rejected))
+	for _, row := range t.rejected {
+		rejected = append(rejected, row)
+	}
+	sort.Slice(rejected, func(i, j int) bool {
+		return rejected[i].line < rejected[j].line
+	})
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(append(append([]string{}, header...), errorColumn))
+	for _, row := range rejected {
+		messages := make([]string, len(row.errs))
+		for i, rowErr := range row.errs {
+			messages[i] = rowErr.String()
+		}
+		message := strings.Join(messages, "; ")
+
+		result.Errors = append(result.Errors, row.errs...)
+		result.FailedRecords = append(result.FailedRecords, fmt.Sprintf("Line %d: %s", row.line+1, message))
+
+		// Align the record with the header so the error lands in its column
+		record := make([]string, len(header), len(header)+1)
+		copy(record, row.record)
+		writer.Write(append(record, message))
+	}
+	writer.Flush()
+
+	if len(rejected) > 0 {
+		result.ErrorCSV = buf.Bytes()
+	}
+	return result
+}
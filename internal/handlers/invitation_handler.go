@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-sample/internal/invitation"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type InvitationHandler struct {
+	invitationRepo repository.InvitationRepository
+	userRepo       repository.UserRepository
+	signer         *invitation.Signer
+	// How long an invitation can be answered
+	ttl time.Duration
+}
+
+func NewInvitationHandler(invitationRepo repository.InvitationRepository, userRepo repository.UserRepository, signer *invitation.Signer, ttl time.Duration) *InvitationHandler {
+	return &InvitationHandler{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		signer:         signer,
+		ttl:            ttl,
+	}
+}
+
+// Create invites an email address to the team. The token in the response is
+// the only copy; it is not stored and cannot be fetched again.
+func (h *InvitationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	var req InvitationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	nonce, nonceHash, err := invitation.NewNonce()
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Invitation")
+		return
+	}
+	inv := models.Invitation{
+		TeamID:    uint(teamID),
+		Email:     strings.TrimSpace(req.Email),
+		NonceHash: nonceHash,
+		ExpiresAt: time.Now().Add(h.ttl),
+	}
+	if err := h.invitationRepo.Create(r.Context(), &inv); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	resp := newInvitationResponse(&inv)
+	resp.Token = h.signer.Token(inv.ID, nonce)
+	SuccessResponse(w, http.StatusCreated, resp)
+}
+
+// ListByTeam lists the team's pending invitations.
+func (h *InvitationHandler) ListByTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	invitations, err := h.invitationRepo.ListPendingByTeam(uint(teamID))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newInvitationResponses(invitations))
+}
+
+// ListByUser lists the pending invitations sent to the user's email.
+func (h *InvitationHandler) ListByUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(uint(userID))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	invitations, err := h.invitationRepo.ListPendingByEmail(user.Email)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newInvitationResponses(invitations))
+}
+
+// Accept adds the invited user to the team, creating the user first if no
+// user has the invited email.
+func (h *InvitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	id, nonceHash, err := h.signer.Parse(req.Token)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	inv, user, err := h.invitationRepo.Accept(r.Context(), id, nonceHash, req.Name)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Invitation")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, AcceptInvitationResponse{
+		Invitation: newInvitationResponse(inv),
+		User:       UserSummary{ID: user.ID, Email: user.Email, Name: user.Name},
+	})
+}
+
+func (h *InvitationHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	var req DeclineInvitationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	id, nonceHash, err := h.signer.Parse(req.Token)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	inv, err := h.invitationRepo.Decline(r.Context(), id, nonceHash)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Invitation")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newInvitationResponse(inv))
+}
+
+// Revoke withdraws a pending invitation.
+func (h *InvitationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	inv, err := h.invitationRepo.Revoke(r.Context(), uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Invitation")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newInvitationResponse(inv))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-sample/internal/requestctx"
+)
+
+// parseIncludeDeleted reads the include_deleted query flag, which only admins
+// may set.
+func parseIncludeDeleted(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil || !include {
+		return false, nil
+	}
+	if !requestctx.IsAdmin(r.Context()) {
+		return false, errors.New("include_deleted requires an admin API key")
+	}
+	return true, nil
+}
+
+// parseAttributeFilters collects attr.<key>=<value> query parameters, e.g.
+// attr.cost_center=42.
+func parseAttributeFilters(r *http.Request) map[string]string {
+	filters := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		key := strings.TrimPrefix(name, "attr.")
+		if key == name || key == "" || len(values) == 0 {
+			continue
+		}
+		filters[key] = values[0]
+	}
+	return filters
+}
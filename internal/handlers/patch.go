@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-sample/internal/models"
+	"go-sample/internal/patch"
+	"go-sample/internal/validation"
+)
+
+// UserPatchDocument is the view of a user that PATCH requests operate on.
+type UserPatchDocument struct {
+	Email      string            `json:"email" validate:"required,email,max=255"`
+	Name       string            `json:"name" validate:"required,max=255"`
+	TeamIDs    []uint            `json:"team_ids"`
+	Source     string            `json:"source" validate:"max=100"`
+	ExternalID string            `json:"external_id" validate:"max=255"`
+	Attributes models.Attributes `json:"attributes"`
+}
+
+func (d UserPatchDocument) Validate() validation.Errors {
+	return validateExternalID(d.Source, d.ExternalID)
+}
+
+// TeamPatchDocument is the view of a team that PATCH requests operate on.
+type TeamPatchDocument struct {
+	Title       string            `json:"title" validate:"required,max=255"`
+	Description string            `json:"description" validate:"max=2000"`
+	ParentID    *uint             `json:"parent_id"`
+	Source      string            `json:"source" validate:"max=100"`
+	ExternalID  string            `json:"external_id" validate:"max=255"`
+	Attributes  models.Attributes `json:"attributes"`
+}
+
+func (d TeamPatchDocument) Validate() validation.Errors {
+	return validateExternalID(d.Source, d.ExternalID)
+}
+
+// applyPatch applies the request body to current and validates the result in
+// patched the same way as a full update. It returns false once a response is
+// written.
+func applyPatch(w http.ResponseWriter, r *http.Request, current interface{}, patched interface{}) bool {
+	result, status, err := patchDocument(r, current)
+	if err != nil {
+		ErrorResponse(w, status, err.Error())
+		return false
+	}
+
+	// Only fields of the patch document may be touched
+	errs, err := decodeJSON(result, patched)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid patch result: patched document must be a JSON object")
+		return false
+	}
+	if len(errs) > 0 {
+		ValidationErrorResponse(w, errs)
+		return false
+	}
+	return true
+}
+
+// patchDocument applies the request body to current, as a JSON Merge Patch or
+// a JSON Patch depending on Content-Type. On failure it returns the HTTP
+// status to respond with.
+func patchDocument(r *http.Request, current interface{}) ([]byte, int, error) {
+	contentType := patch.MergePatchContentType
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return nil, http.StatusUnsupportedMediaType, errors.New("Invalid Content-Type")
+		}
+		contentType = mediaType
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("Invalid request payload")
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var result []byte
+	switch contentType {
+	case patch.MergePatchContentType, "application/json":
+		result, err = patch.MergePatch(doc, body)
+	case patch.JSONPatchContentType:
+		result, err = patch.JSONPatch(doc, body)
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("Unsupported Content-Type %s, use %s or %s",
+			contentType, patch.MergePatchContentType, patch.JSONPatchContentType)
+	}
+	if errors.Is(err, patch.ErrTestFailed) {
+		return nil, http.StatusConflict, err
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return result, 0, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"go-sample/internal/models"
+	"go-sample/internal/requestctx"
+)
+
+// problemFields returns the fields of a problem's errors, sorted.
+func problemFields(problem Problem) []string {
+	fields := make([]string, 0, len(problem.Errors))
+	for _, err := range problem.Errors {
+		fields = append(fields, err.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestCreateUserProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"every invalid field at once", `{"email": "not-an-email", "nickname": "ada"}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed, []string{"email", "name", "nickname"}},
+		{"mistyped field", `{"email": "ada@example.com", "name": 42}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed, []string{"name"}},
+		{"external ID without source", `{"email": "ada@example.com", "name": "Ada", "external_id": "42"}`,
+			http.StatusUnprocessableEntity, CodeValidationFailed, []string{"source"}},
+		{"malformed", `{"email": `, http.StatusBadRequest, "bad_request", nil},
+		{"duplicate email", `{"email": "TAKEN@example.com", "name": "Ada"}`,
+			http.StatusConflict, CodeDuplicateEmail, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository(models.User{ID: 1, Email: "taken@example.com", Name: "Taken", Version: 1})
+			h := NewUserHandler(repo, false)
+			req := newRequest(http.MethodPost, "/api/users", tt.body)
+			req = req.WithContext(requestctx.WithRequestID(req.Context(), "req-1"))
+
+			rec := serve(h.Create, req, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			problem := decodeProblem(t, rec)
+			if problem.Status != tt.status || problem.Code != tt.code || problem.Type != "about:blank" || problem.Title == "" {
+				t.Errorf("problem %+v, want status %d and code %s", problem, tt.status, tt.code)
+			}
+			if got := problemFields(problem); fmt.Sprint(got) != fmt.Sprint(tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+			if len(repo.users) != 1 {
+				t.Errorf("rejected request created a user")
+			}
+		})
+	}
+}
+
+// Problems from the repository name the request they answer
+func TestCreateUserProblemInstance(t *testing.T) {
+	repo := newFakeUserRepository(models.User{ID: 1, Email: "taken@example.com", Name: "Taken", Version: 1})
+	h := NewUserHandler(repo, false)
+	req := newRequest(http.MethodPost, "/api/users", `{"email": "taken@example.com", "name": "Ada"}`)
+	req = req.WithContext(requestctx.WithRequestID(req.Context(), "req-1"))
+
+	problem := decodeProblem(t, serve(h.Create, req, nil))
+	if problem.Instance != "/api/users" || problem.RequestID != "req-1" {
+		t.Errorf("instance %q and request ID %q, want /api/users and req-1", problem.Instance, problem.RequestID)
+	}
+}
+
+func TestCreateTeamProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   string
+		fields []string
+	}{
+		{"missing title", `{"description": "Platform"}`, CodeValidationFailed, []string{"title"}},
+		{"too long title and unknown field", `{"title": "` + strings.Repeat("x", 256) + `", "members": [1]}`,
+			CodeValidationFailed, []string{"members", "title"}},
+		{"mistyped parent", `{"title": "Platform", "parent_id": "1"}`, CodeValidationFailed, []string{"parent_id"}},
+		{"unknown parent", `{"title": "Platform", "parent_id": 99}`, CodeInvalidReference, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTeamRepository(newFakeUserRepository())
+			h := NewTeamHandler(repo, false)
+
+			rec := serve(h.Create, newRequest(http.MethodPost, "/api/teams", tt.body), nil)
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+			}
+			problem := decodeProblem(t, rec)
+			if problem.Code != tt.code {
+				t.Errorf("code %q, want %q", problem.Code, tt.code)
+			}
+			if got := problemFields(problem); fmt.Sprint(got) != fmt.Sprint(tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+			if len(repo.teams) != 0 {
+				t.Errorf("rejected request created a team")
+			}
+		})
+	}
+}
@@ -2,13 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+
+	"go-sample/internal/repository"
+	"go-sample/internal/requestctx"
+	"go-sample/internal/validation"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Stable error codes clients can branch on
+const (
+	CodeValidationFailed   = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodeNotDeleted         = "not_deleted"
+	CodeDuplicateEmail     = "duplicate_email"
+	CodeDuplicateExternal  = "duplicate_external_id"
+	CodeInvalidReference   = "invalid_reference"
+	CodeHierarchyCycle     = "hierarchy_cycle"
+	CodeInvalidSchema      = "invalid_schema"
+	CodeAlreadyMember      = "already_member"
+	CodeInvitationClosed   = "invitation_closed"
+	CodeInvitationExpired  = "invitation_expired"
+	CodeConflict           = "conflict"
+	CodeVersionConflict    = "version_conflict"
+	CodeImportNotResumable = "import_not_resumable"
+	CodeInternal           = "internal_error"
 )
 
 type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+}
+
+// Problem is an RFC 7807 problem details document. Code is a stable,
+// machine-readable identifier of the error.
+type Problem struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail,omitempty"`
+	Instance  string                  `json:"instance,omitempty"`
+	Code      string                  `json:"code"`
+	RequestID string                  `json:"request_id,omitempty"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
 }
 
 func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
@@ -17,16 +57,152 @@ func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// ProblemResponse writes problem as application/problem+json.
+func ProblemResponse(w http.ResponseWriter, problem Problem) {
+	problem = problem.withDefaults()
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// withDefaults fills in the type, title and code derived from the status.
+func (p Problem) withDefaults() Problem {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Code == "" {
+		p.Code = statusCode(p.Status)
+	}
+	return p
+}
+
+// ErrorResponse writes a problem whose code is derived from the status.
 func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	JSONResponse(w, statusCode, Response{
-		Success: false,
-		Error:   message,
+	ProblemResponse(w, Problem{
+		Status: statusCode,
+		Detail: message,
 	})
 }
 
+// ValidationErrorResponse responds 422 with every invalid field.
+func ValidationErrorResponse(w http.ResponseWriter, errs validation.Errors) {
+	ProblemResponse(w, Problem{
+		Status: http.StatusUnprocessableEntity,
+		Detail: "Validation failed",
+		Code:   CodeValidationFailed,
+		Errors: errs,
+	})
+}
+
+// RepositoryErrorResponse maps a repository error to a problem. subject names
+// the requested record in the 404 detail, e.g. "User". Unexpected errors are
+// logged and answered with a generic 500 so database messages never leak.
+func RepositoryErrorResponse(w http.ResponseWriter, r *http.Request, err error, subject string) {
+	ProblemResponse(w, repositoryProblem(r, err, subject))
+}
+
+func repositoryProblem(r *http.Request, err error, subject string) Problem {
+	problem, ok := knownProblem(err, subject)
+	problem.Instance = r.URL.Path
+	problem.RequestID = requestctx.RequestID(r.Context())
+	if !ok {
+		log.Printf("[%s] %s %s: %v", problem.RequestID, r.Method, r.URL.Path, err)
+		problem.Status = http.StatusInternalServerError
+		problem.Code = CodeInternal
+		problem.Detail = "An unexpected error occurred"
+	}
+	return problem
+}
+
+// knownProblem maps the repository's sentinel errors to a problem. It reports
+// false for any other error.
+func knownProblem(err error, subject string) (Problem, bool) {
+	var problem Problem
+	var attrErr *repository.AttributeError
+	switch {
+	case errors.As(err, &attrErr):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = CodeValidationFailed
+		problem.Detail = "Attributes do not match the registered schema"
+		problem.Errors = attrErr.Errors
+	case errors.Is(err, repository.ErrInvalidSchema):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = CodeInvalidSchema
+		problem.Detail = err.Error()
+	case errors.Is(err, repository.ErrNotFound):
+		problem.Status = http.StatusNotFound
+		problem.Code = CodeNotFound
+		problem.Detail = subject + " not found"
+	case errors.Is(err, repository.ErrNotDeleted):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeNotDeleted
+		problem.Detail = subject + " is not deleted"
+	case errors.Is(err, repository.ErrVersionConflict):
+		problem.Status = http.StatusPreconditionFailed
+		problem.Code = CodeVersionConflict
+		problem.Detail = "Resource has been modified; refetch and retry"
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeDuplicateEmail
+		problem.Detail = "A user with this email already exists"
+	case errors.Is(err, repository.ErrDuplicateExternalID):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeDuplicateExternal
+		problem.Detail = "Another record already has this source and external ID"
+	case errors.Is(err, repository.ErrInvalidReference):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = CodeInvalidReference
+		problem.Detail = "A referenced record does not exist"
+	case errors.Is(err, repository.ErrHierarchyCycle):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = CodeHierarchyCycle
+		problem.Detail = "A team cannot be moved below itself or its descendants"
+	case errors.Is(err, repository.ErrAlreadyMember):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeAlreadyMember
+		problem.Detail = "The user is already a member of the team"
+	case errors.Is(err, repository.ErrInvitationClosed):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeInvitationClosed
+		problem.Detail = "The invitation has already been answered or revoked"
+	case errors.Is(err, repository.ErrInvitationExpired):
+		problem.Status = http.StatusGone
+		problem.Code = CodeInvitationExpired
+		problem.Detail = "The invitation has expired"
+	case errors.Is(err, repository.ErrNameRequired):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = CodeValidationFailed
+		problem.Detail = "Validation failed"
+		problem.Errors = []validation.FieldError{{Field: "name", Message: "is required to create the invited user"}}
+	case errors.Is(err, repository.ErrImportNotResumable):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeImportNotResumable
+		problem.Detail = "Only failed imports, or running ones that stopped responding, can be resumed"
+	case errors.Is(err, repository.ErrConflict):
+		problem.Status = http.StatusConflict
+		problem.Code = CodeConflict
+		problem.Detail = "The change conflicts with the current state; retry"
+	default:
+		return problem, false
+	}
+	return problem, true
+}
+
 func SuccessResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	JSONResponse(w, statusCode, Response{
 		Success: true,
 		Data:    data,
 	})
 }
+
+// statusCode turns a status into a code, e.g. 404 into "not_found".
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
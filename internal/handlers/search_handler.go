@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type SearchHandler struct {
+	searchRepo repository.SearchRepository
+}
+
+type SearchQuery struct {
+	Query string `json:"q" validate:"required,min=2,max=200"`
+}
+
+type SearchHit struct {
+	repository.SearchResult
+	// Highlights holds HTML-escaped title and subtitle with matched terms
+	// wrapped in <mark>
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResponse struct {
+	Query   string      `json:"query"`
+	Results []SearchHit `json:"results"`
+	Total   int64       `json:"total"`
+	Limit   int         `json:"limit"`
+	Offset  int         `json:"offset"`
+}
+
+func NewSearchHandler(searchRepo repository.SearchRepository) *SearchHandler {
+	return &SearchHandler{
+		searchRepo: searchRepo,
+	}
+}
+
+// Search handles GET /api/search?q=&type=user,team&limit=&offset=.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := SearchQuery{Query: strings.TrimSpace(query.Get("q"))}
+	errs := validation.Struct(&req)
+
+	opts := repository.SearchOptions{Query: req.Query, Limit: defaultSearchLimit}
+	if value := query.Get("type"); value != "" {
+		for _, t := range strings.Split(value, ",") {
+			t = strings.TrimSpace(t)
+			if t != repository.SearchTypeUser && t != repository.SearchTypeTeam {
+				errs.Add("type", "must be user, team or both separated by a comma")
+				break
+			}
+			opts.Types = append(opts.Types, t)
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			errs.Add("limit", "must be between 1 and "+strconv.Itoa(maxSearchLimit))
+		}
+		opts.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			errs.Add("offset", "must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+	if len(errs) > 0 {
+		ValidationErrorResponse(w, errs)
+		return
+	}
+
+	page, err := h.searchRepo.Search(opts)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Search")
+		return
+	}
+
+	response := SearchResponse{
+		Query:   req.Query,
+		Results: make([]SearchHit, len(page.Results)),
+		Total:   page.Total,
+		Limit:   opts.Limit,
+		Offset:  opts.Offset,
+	}
+	terms := searchTerms(req.Query)
+	for i, result := range page.Results {
+		response.Results[i] = SearchHit{
+			SearchResult: result,
+			Highlights: map[string]string{
+				"title":    highlight(result.Title, terms),
+				"subtitle": highlight(result.Subtitle, terms),
+			},
+		}
+	}
+
+	SuccessResponse(w, http.StatusOK, response)
+}
+
+// searchTerms splits a query into lowercase words, dropping web search
+// syntax, longest first so overlapping terms highlight the longer match.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		field = strings.Trim(field, `"-`)
+		if field != "" && field != "or" {
+			terms = append(terms, field)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return terms
+}
+
+// highlight HTML-escapes text and wraps case-insensitive occurrences of terms
+// in <mark>.
+func highlight(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	// Byte offsets only line up when lowercasing keeps the length
+	if len(lower) != len(text) || len(terms) == 0 {
+		return html.EscapeString(text)
+	}
+
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		for start := 0; ; {
+			idx := strings.Index(lower[start:], term)
+			if idx < 0 {
+				break
+			}
+			for i := start + idx; i < start+idx+len(term); i++ {
+				marked[i] = true
+			}
+			start += idx + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(text[i:j])
+		if marked[i] {
+			segment = "<mark>" + segment + "</mark>"
+		}
+		b.WriteString(segment)
+		i = j
+	}
+	return b.String()
+}
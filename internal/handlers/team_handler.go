@@ -1,11 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"go-sample/internal/models"
 	"go-sample/internal/repository"
 
 	"github.com/gorilla/mux"
@@ -13,27 +12,30 @@ import (
 
 type TeamHandler struct {
 	teamRepo repository.TeamRepository
+	// Reject PUT, PATCH and DELETE requests without If-Match
+	requireIfMatch bool
 }
 
-func NewTeamHandler(teamRepo repository.TeamRepository) *TeamHandler {
+func NewTeamHandler(teamRepo repository.TeamRepository, requireIfMatch bool) *TeamHandler {
 	return &TeamHandler{
-		teamRepo: teamRepo,
+		teamRepo:       teamRepo,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
 func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var team models.Team
-	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	var req TeamRequest
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	if err := h.teamRepo.Create(&team); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	team := req.team()
+	if err := h.teamRepo.Create(r.Context(), &team); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
 		return
 	}
 
-	SuccessResponse(w, http.StatusCreated, team)
+	SuccessResponse(w, http.StatusCreated, newTeamResponse(&team))
 }
 
 func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -44,19 +46,46 @@ func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var team models.Team
-	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
 		return
 	}
-	team.ID = uint(id)
 
-	if err := h.teamRepo.Update(&team); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	var req TeamRequest
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, team)
+	team, err := h.teamRepo.GetByID(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	team.Title = req.Title
+	team.Description = req.Description
+	team.ParentID = req.ParentID
+	if req.Source != nil || req.ExternalID != nil {
+		team.Source = strings.TrimSpace(stringValue(req.Source))
+		team.ExternalID = strings.TrimSpace(stringValue(req.ExternalID))
+	}
+	if req.Attributes != nil {
+		team.Attributes = req.Attributes
+	}
+	team.Version = version
+
+	// Members are not part of the request; keep Save from touching them
+	members := team.Users
+	team.Users = nil
+
+	if err := h.teamRepo.Update(r.Context(), team); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	team.Users = members
+	w.Header().Set("ETag", entityETag(team.Version))
+	SuccessResponse(w, http.StatusOK, newTeamResponse(team))
 }
 
 func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -67,8 +96,13 @@ func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.teamRepo.Delete(uint(id)); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := h.teamRepo.Delete(r.Context(), uint(id), version); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
 		return
 	}
 
@@ -85,21 +119,31 @@ func (h *TeamHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	team, err := h.teamRepo.GetByID(uint(id))
 	if err != nil {
-		ErrorResponse(w, http.StatusNotFound, "Team not found")
+		RepositoryErrorResponse(w, r, err, "Team")
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, team)
+	ETagResponse(w, r, entityETag(team.Version), newTeamResponse(team))
 }
 
 func (h *TeamHandler) List(w http.ResponseWriter, r *http.Request) {
-	teams, err := h.teamRepo.List()
+	includeDeleted, err := parseIncludeDeleted(r)
 	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		ErrorResponse(w, http.StatusForbidden, err.Error())
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, teams)
+	teams, err := h.teamRepo.List(repository.ListOptions{
+		IncludeDeleted: includeDeleted,
+		Attributes:     parseAttributeFilters(r),
+	})
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	resp := newTeamResponses(teams)
+	ETagResponse(w, r, contentETag(resp), resp)
 }
 
 func (h *TeamHandler) AddUser(w http.ResponseWriter, r *http.Request) {
@@ -111,26 +155,175 @@ func (h *TeamHandler) AddUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request body
-	var request struct {
-		UserID uint `json:"user_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	var request AddTeamUserRequest
+	if !decodeRequest(w, r, &request) {
 		return
 	}
 
 	// Get team
 	team, err := h.teamRepo.GetByID(uint(teamID))
 	if err != nil {
-		ErrorResponse(w, http.StatusNotFound, "Team not found")
+		RepositoryErrorResponse(w, r, err, "Team")
 		return
 	}
 
 	// Add user to team
-	if err := h.teamRepo.AddUser(team.ID, request.UserID); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.teamRepo.AddUser(r.Context(), team.ID, request.UserID); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
 		return
 	}
 
 	SuccessResponse(w, http.StatusOK, map[string]string{"message": "User added to team successfully"})
 }
+
+// GetByExternalID looks a team up by the ID an upstream system gave it.
+func (h *TeamHandler) GetByExternalID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	team, err := h.teamRepo.GetByExternalID(vars["source"], vars["external_id"])
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	ETagResponse(w, r, entityETag(team.Version), newTeamResponse(team))
+}
+
+func (h *TeamHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	if err := h.teamRepo.Restore(r.Context(), uint(id)); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, map[string]string{"message": "Team restored successfully"})
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the
+// team's title, description, parent_id, source, external_id and attributes,
+// leaving fields not mentioned untouched.
+func (h *TeamHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	team, err := h.teamRepo.GetByID(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	current := TeamPatchDocument{
+		Title:       team.Title,
+		Description: team.Description,
+		ParentID:    team.ParentID,
+		Source:      team.Source,
+		ExternalID:  team.ExternalID,
+		Attributes:  attributesOrEmpty(team.Attributes),
+	}
+
+	var patched TeamPatchDocument
+	if !applyPatch(w, r, current, &patched) {
+		return
+	}
+
+	team.Title = patched.Title
+	team.Description = patched.Description
+	team.ParentID = patched.ParentID
+	team.Source = strings.TrimSpace(patched.Source)
+	team.ExternalID = strings.TrimSpace(patched.ExternalID)
+	team.Attributes = patched.Attributes
+	team.Version = version
+
+	// Members are not part of the patch document; keep Save from touching them
+	members := team.Users
+	team.Users = nil
+
+	if err := h.teamRepo.Update(r.Context(), team); err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	team.Users = members
+	w.Header().Set("ETag", entityETag(team.Version))
+	SuccessResponse(w, http.StatusOK, newTeamResponse(team))
+}
+
+// Subtree returns the team with its descendants nested as children.
+func (h *TeamHandler) Subtree(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	teams, err := h.teamRepo.Subtree(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newTeamTree(uint(id), teams))
+}
+
+// Ancestors lists the team's parent, grandparent and so on up to the root.
+func (h *TeamHandler) Ancestors(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	teams, err := h.teamRepo.Ancestors(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newTeamResponses(teams))
+}
+
+// Members lists the team's direct members, or with ?effective=true also the
+// members of all of its sub-teams.
+func (h *TeamHandler) Members(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+
+	effective, _ := strconv.ParseBool(r.URL.Query().Get("effective"))
+	if effective {
+		users, err := h.teamRepo.EffectiveMembers(uint(id))
+		if err != nil {
+			RepositoryErrorResponse(w, r, err, "Team")
+			return
+		}
+		SuccessResponse(w, http.StatusOK, newUserSummaries(users))
+		return
+	}
+
+	team, err := h.teamRepo.GetByID(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Team")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, newUserSummaries(team.Users))
+}
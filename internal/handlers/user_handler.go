@@ -1,11 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"go-sample/internal/models"
 	"go-sample/internal/repository"
 
 	"github.com/gorilla/mux"
@@ -13,33 +12,30 @@ import (
 
 type UserHandler struct {
 	userRepo repository.UserRepository
+	// Reject PUT, PATCH and DELETE requests without If-Match
+	requireIfMatch bool
 }
 
-type UpdateUserRequest struct {
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	TeamIDs []uint `json:"team_ids"`
-}
-
-func NewUserHandler(userRepo repository.UserRepository) *UserHandler {
+func NewUserHandler(userRepo repository.UserRepository, requireIfMatch bool) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	var req CreateUserRequest
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	if err := h.userRepo.Create(&user); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	user := req.user()
+	if err := h.userRepo.Create(r.Context(), &user); err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
-	SuccessResponse(w, http.StatusCreated, user)
+	SuccessResponse(w, http.StatusCreated, newUserResponse(&user))
 }
 
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -50,27 +46,38 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
 	var req UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
 	user, err := h.userRepo.GetByID(uint(id))
 	if err != nil {
-		ErrorResponse(w, http.StatusNotFound, "User not found")
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
-	user.Email = req.Email
-	user.Name = req.Name
+	req.apply(user)
+	user.Version = version
 
-	if err := h.userRepo.UpdateWithTeams(user, req.TeamIDs); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.userRepo.UpdateWithTeams(r.Context(), user, req.TeamIDs); err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	updated, err := h.userRepo.GetWithTeams(user.ID)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, user)
+	w.Header().Set("ETag", entityETag(updated.Version))
+	SuccessResponse(w, http.StatusOK, newUserResponse(updated))
 }
 
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -81,8 +88,13 @@ func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userRepo.Delete(uint(id)); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := h.userRepo.Delete(r.Context(), uint(id), version); err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
@@ -99,19 +111,145 @@ func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.userRepo.GetWithTeams(uint(id))
 	if err != nil {
-		ErrorResponse(w, http.StatusNotFound, "User not found")
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, user)
+	ETagResponse(w, r, entityETag(user.Version), newUserResponse(user))
 }
 
 func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userRepo.List()
+	includeDeleted, err := parseIncludeDeleted(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	users, err := h.userRepo.List(repository.ListOptions{
+		IncludeDeleted: includeDeleted,
+		Attributes:     parseAttributeFilters(r),
+	})
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	resp := newUserResponses(users)
+	ETagResponse(w, r, contentETag(resp), resp)
+}
+
+// GetByExternalID looks a user up by the ID an upstream system gave it.
+func (h *UserHandler) GetByExternalID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	user, err := h.userRepo.GetByExternalID(vars["source"], vars["external_id"])
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	ETagResponse(w, r, entityETag(user.Version), newUserResponse(user))
+}
+
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.userRepo.Restore(r.Context(), uint(id)); err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, map[string]string{"message": "User restored successfully"})
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the
+// user's email, name, team_ids, source, external_id and attributes, leaving
+// fields not mentioned untouched.
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	version, ok := expectedVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	user, err := h.userRepo.GetWithTeams(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	current := UserPatchDocument{
+		Email:      user.Email,
+		Name:       user.Name,
+		TeamIDs:    make([]uint, 0, len(user.Teams)),
+		Source:     user.Source,
+		ExternalID: user.ExternalID,
+		Attributes: attributesOrEmpty(user.Attributes),
+	}
+	for _, team := range user.Teams {
+		current.TeamIDs = append(current.TeamIDs, team.ID)
+	}
+
+	var patched UserPatchDocument
+	if !applyPatch(w, r, current, &patched) {
+		return
+	}
+
+	user.Email = patched.Email
+	user.Name = patched.Name
+	user.Source = strings.TrimSpace(patched.Source)
+	user.ExternalID = strings.TrimSpace(patched.ExternalID)
+	user.Attributes = patched.Attributes
+	user.Version = version
+	user.Teams = nil
+
+	// Only rewrite memberships when the patch changed them
+	var teamIDs []uint
+	if !sameIDs(current.TeamIDs, patched.TeamIDs) {
+		teamIDs = patched.TeamIDs
+		if teamIDs == nil {
+			teamIDs = []uint{}
+		}
+	}
+
+	if err := h.userRepo.UpdateWithTeams(r.Context(), user, teamIDs); err != nil {
+		RepositoryErrorResponse(w, r, err, "User")
+		return
+	}
+
+	updated, err := h.userRepo.GetWithTeams(user.ID)
 	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		RepositoryErrorResponse(w, r, err, "User")
 		return
 	}
 
-	SuccessResponse(w, http.StatusOK, users)
+	w.Header().Set("ETag", entityETag(updated.Version))
+	SuccessResponse(w, http.StatusOK, newUserResponse(updated))
+}
+
+func sameIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uint]int, len(a))
+	for _, id := range a {
+		seen[id]++
+	}
+	for _, id := range b {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+	return true
 }
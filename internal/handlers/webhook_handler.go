@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+	"go-sample/internal/validation"
+	"go-sample/internal/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultDeliveryLimit = 100
+
+type WebhookHandler struct {
+	webhookRepo repository.WebhookRepository
+	dispatcher  *webhook.Dispatcher
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=2048"`
+	Secret string   `json:"secret" validate:"max=255"`
+	Events []string `json:"events" validate:"required"`
+	Active *bool    `json:"active"`
+}
+
+func (r WebhookRequest) Validate() validation.Errors {
+	var errs validation.Errors
+	if u, err := url.Parse(r.URL); r.URL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs.Add("url", "must be an absolute http(s) URL")
+	}
+	for _, e := range r.Events {
+		if !events.IsKnownType(e) {
+			errs.Add("events", fmt.Sprintf("has unknown event %s", e))
+		}
+	}
+	return errs
+}
+
+// createdWebhook is a new subscription with its signing secret, which no
+// other response includes.
+type createdWebhook struct {
+	*models.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+func NewWebhookHandler(webhookRepo repository.WebhookRepository, dispatcher *webhook.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		webhookRepo: webhookRepo,
+		dispatcher:  dispatcher,
+	}
+}
+
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	sub := models.WebhookSubscription{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+		Active: true,
+	}
+	if sub.Secret == "" {
+		sub.Secret = events.NewID()
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := h.webhookRepo.CreateSubscription(&sub); err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+	h.dispatcher.InvalidateSubscriptions()
+
+	SuccessResponse(w, http.StatusCreated, createdWebhook{WebhookSubscription: &sub, Secret: sub.Secret})
+}
+
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var req WebhookRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	sub, err := h.webhookRepo.GetSubscription(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+
+	sub.URL = req.URL
+	sub.Events = req.Events
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := h.webhookRepo.UpdateSubscription(sub); err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+	h.dispatcher.InvalidateSubscriptions()
+
+	SuccessResponse(w, http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.webhookRepo.DeleteSubscription(uint(id)); err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+	h.dispatcher.InvalidateSubscriptions()
+
+	SuccessResponse(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+func (h *WebhookHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	sub, err := h.webhookRepo.GetSubscription(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.webhookRepo.ListSubscriptions()
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	deliveries, err := h.webhookRepo.ListDeliveries(uint(id), defaultDeliveryLimit)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Webhook")
+		return
+	}
+
+	SuccessResponse(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.dispatcher.Redeliver(uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, err, "Delivery")
+		return
+	}
+
+	SuccessResponse(w, http.StatusAccepted, delivery)
+}
+
+// decodeRequest decodes and validates a subscription request, including
+// whether the dispatcher may deliver to its URL.
+func (h *WebhookHandler) decodeRequest(w http.ResponseWriter, r *http.Request, req *WebhookRequest) bool {
+	if !decodeRequest(w, r, req) {
+		return false
+	}
+	if err := h.dispatcher.CheckURL(req.URL); err != nil {
+		ValidationErrorResponse(w, validation.Errors{{Field: "url", Message: err.Error()}})
+		return false
+	}
+	return true
+}
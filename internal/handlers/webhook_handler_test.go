@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"go-sample/internal/models"
+	"go-sample/internal/webhook"
+)
+
+func TestWebhookRequestValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"valid", `{"url": "https://hooks.example.com/in", "events": ["user.created"]}`, http.StatusOK, ""},
+		{"not an object", `["https://hooks.example.com/in"]`, http.StatusBadRequest, ""},
+		{"unknown field", `{"url": "https://hooks.example.com/in", "events": ["*"], "headers": {}}`, http.StatusUnprocessableEntity, "headers"},
+		{"missing url", `{"events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+		{"relative url", `{"url": "/in", "events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+		{"no events", `{"url": "https://hooks.example.com/in", "events": []}`, http.StatusUnprocessableEntity, "events"},
+		{"unknown event", `{"url": "https://hooks.example.com/in", "events": ["user.renamed"]}`, http.StatusUnprocessableEntity, "events"},
+		{"mistyped active", `{"url": "https://hooks.example.com/in", "events": ["*"], "active": "yes"}`, http.StatusUnprocessableEntity, "active"},
+		{"loopback", `{"url": "http://127.0.0.1:8080/in", "events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+		{"localhost", `{"url": "http://localhost/in", "events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+		{"private", `{"url": "http://10.0.0.5/in", "events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+		{"metadata service", `{"url": "http://169.254.169.254/latest", "events": ["*"]}`, http.StatusUnprocessableEntity, "url"},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			t.Run(method+" "+tt.name, func(t *testing.T) {
+				repo := newFakeWebhookRepository()
+				repo.CreateSubscription(&models.WebhookSubscription{URL: "https://old.example.com", Events: []string{"*"}, Secret: "s3cret"})
+				h := NewWebhookHandler(repo, webhook.NewDispatcher(repo, nil, webhook.DefaultOptions()))
+
+				req := newRequest(method, "/api/webhooks", tt.body)
+				handler, want := h.Create, tt.status
+				if method == http.MethodPut {
+					handler = h.Update
+				} else if want == http.StatusOK {
+					want = http.StatusCreated
+				}
+				rec := serve(handler, req, map[string]string{"id": "1"})
+				if rec.Code != want {
+					t.Fatalf("status %d, want %d; body %s", rec.Code, want, rec.Body)
+				}
+				if tt.field != "" {
+					problem := decodeProblem(t, rec)
+					if problem.Code != CodeValidationFailed || len(problem.Errors) == 0 || problem.Errors[0].Field != tt.field {
+						t.Errorf("problem %+v, want a validation error on %s", problem, tt.field)
+					}
+				}
+				if rec.Code >= 400 && repo.subs[1].URL != "https://old.example.com" {
+					t.Errorf("rejected request changed the subscription to %s", repo.subs[1].URL)
+				}
+			})
+		}
+	}
+}
+
+func TestWebhookAllowPrivateHosts(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	options := webhook.DefaultOptions()
+	options.AllowPrivateHosts = true
+	h := NewWebhookHandler(repo, webhook.NewDispatcher(repo, nil, options))
+
+	req := newRequest(http.MethodPost, "/api/webhooks", `{"url": "http://127.0.0.1:8080/in", "events": ["*"]}`)
+	if rec := serve(h.Create, req, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+}
@@ -0,0 +1,362 @@
+// Package jsonschema validates decoded JSON values against a subset of JSON
+// Schema: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minLength, maxLength, pattern, format (email),
+// minimum and maximum. Annotation keywords such as title and description are
+// accepted and ignored; any other keyword is rejected when the schema is
+// compiled so a schema never silently checks less than its author expects.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go-sample/internal/validation"
+)
+
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+}
+
+var types = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	constant   *interface{}
+	properties map[string]*Schema
+	required   []string
+	// Schema for properties not listed; nil allows any unless noAdditional
+	additional   *Schema
+	noAdditional bool
+	items        *Schema
+	minItems     *int
+	maxItems     *int
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+	format       string
+	minimum      *float64
+	maximum      *float64
+}
+
+// Compile parses a schema document.
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid schema JSON: unexpected data after the schema")
+	}
+	return compile(doc, "#")
+}
+
+func compile(doc interface{}, path string) (*Schema, error) {
+	if b, ok := doc.(bool); ok {
+		// true accepts everything, false nothing
+		if b {
+			return &Schema{}, nil
+		}
+		return &Schema{enum: []interface{}{}}, nil
+	}
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", path)
+	}
+
+	s := &Schema{}
+	keywords := make([]string, 0, len(object))
+	for keyword := range object {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		value := object[keyword]
+		at := path + "/" + keyword
+		var err error
+		switch keyword {
+		case "type":
+			s.types, err = compileTypes(value, at)
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an array", at)
+			}
+			s.enum = list
+		case "const":
+			s.constant = &value
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an object", at)
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, prop := range props {
+				if s.properties[name], err = compile(prop, at+"/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = compileStrings(value, at)
+		case "additionalProperties":
+			if b, ok := value.(bool); ok {
+				s.noAdditional = !b
+			} else {
+				s.additional, err = compile(value, at)
+			}
+		case "items":
+			s.items, err = compile(value, at)
+		case "minItems":
+			s.minItems, err = compileCount(value, at)
+		case "maxItems":
+			s.maxItems, err = compileCount(value, at)
+		case "minLength":
+			s.minLength, err = compileCount(value, at)
+		case "maxLength":
+			s.maxLength, err = compileCount(value, at)
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", at)
+			}
+			if s.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s: %w", at, err)
+			}
+		case "format":
+			format, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", at)
+			}
+			// Unknown formats are annotations, as the specification allows
+			s.format = format
+		case "minimum":
+			s.minimum, err = compileNumber(value, at)
+		case "maximum":
+			s.maximum, err = compileNumber(value, at)
+		default:
+			if !annotations[keyword] {
+				return nil, fmt.Errorf("%s: unsupported keyword", at)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func compileTypes(value interface{}, at string) ([]string, error) {
+	var list []string
+	if name, ok := value.(string); ok {
+		list = []string{name}
+	} else {
+		var err error
+		if list, err = compileStrings(value, at); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range list {
+		if !types[name] {
+			return nil, fmt.Errorf("%s: unknown type %q", at, name)
+		}
+	}
+	return list, nil
+}
+
+func compileStrings(value interface{}, at string) ([]string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an array of strings", at)
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		if list[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("%s: must be an array of strings", at)
+		}
+	}
+	return list, nil
+}
+
+func compileCount(value interface{}, at string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", at)
+	}
+	count := int(n)
+	return &count, nil
+}
+
+func compileNumber(value interface{}, at string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &n, nil
+}
+
+// Validate checks a value decoded by encoding/json and reports every
+// violation, with fields named by their dotted path below prefix.
+func (s *Schema) Validate(value interface{}, prefix string) validation.Errors {
+	var errs validation.Errors
+	s.validate(value, prefix, &errs)
+	return errs
+}
+
+func (s *Schema) validate(value interface{}, path string, errs *validation.Errors) {
+	if len(s.types) > 0 && !s.matchesType(value) {
+		errs.Add(path, "must be of type "+strings.Join(s.types, " or "))
+		return
+	}
+	if s.enum != nil && !contains(s.enum, value) {
+		errs.Add(path, "must be one of the allowed values")
+		return
+	}
+	if s.constant != nil && !equal(*s.constant, value) {
+		errs.Add(path, "must equal the constant value")
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, errs)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			errs.Add(path, fmt.Sprintf("must have at least %d items", *s.minItems))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			errs.Add(path, fmt.Sprintf("must have at most %d items", *s.maxItems))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			errs.Add(path, fmt.Sprintf("must be at least %d characters", *s.minLength))
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			errs.Add(path, fmt.Sprintf("must be at most %d characters", *s.maxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			errs.Add(path, "must match pattern "+s.pattern.String())
+		}
+		if s.format == "email" {
+			if addr, err := mail.ParseAddress(v); err != nil || addr.Address != v {
+				errs.Add(path, "must be a valid email address")
+			}
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			errs.Add(path, fmt.Sprintf("must be at least %v", *s.minimum))
+		}
+		if s.maximum != nil && v > *s.maximum {
+			errs.Add(path, fmt.Sprintf("must be at most %v", *s.maximum))
+		}
+	}
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, path string, errs *validation.Errors) {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			errs.Add(join(path, name), "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prop, ok := s.properties[name]; ok {
+			prop.validate(object[name], join(path, name), errs)
+			continue
+		}
+		if s.noAdditional {
+			errs.Add(join(path, name), "is not allowed")
+		} else if s.additional != nil {
+			s.additional.validate(object[name], join(path, name), errs)
+		}
+	}
+}
+
+// PropertyTypes returns the declared types of a top-level property, or nil
+// when the schema does not constrain it.
+func (s *Schema) PropertyTypes(name string) []string {
+	if prop, ok := s.properties[name]; ok {
+		return prop.types
+	}
+	if s.additional != nil {
+		return s.additional.types
+	}
+	return nil
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	for _, name := range s.types {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
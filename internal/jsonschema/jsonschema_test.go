@@ -0,0 +1,148 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompileMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"invalid JSON", `{"type":`},
+		{"empty document", ``},
+		{"trailing data", `{"type": "string"} {"type": "number"}`},
+		{"schema is a string", `"object"`},
+		{"schema is a number", `1`},
+		{"unknown type", `{"type": "date"}`},
+		{"type is a number", `{"type": 1}`},
+		{"type list with a number", `{"type": ["string", 1]}`},
+		{"enum is not an array", `{"enum": "a"}`},
+		{"properties is not an object", `{"properties": []}`},
+		{"malformed property", `{"properties": {"a": {"type": "text"}}}`},
+		{"required is not an array", `{"required": "a"}`},
+		{"required with a number", `{"required": ["a", 1]}`},
+		{"malformed additionalProperties", `{"additionalProperties": "no"}`},
+		{"malformed items", `{"items": 1}`},
+		{"negative minItems", `{"minItems": -1}`},
+		{"fractional maxLength", `{"maxLength": 1.5}`},
+		{"string minLength", `{"minLength": "1"}`},
+		{"pattern is not a string", `{"pattern": 1}`},
+		{"invalid pattern", `{"pattern": "(a"}`},
+		{"format is not a string", `{"format": true}`},
+		{"minimum is not a number", `{"minimum": "0"}`},
+		{"unsupported keyword", `{"oneOf": [{"type": "string"}]}`},
+		{"unsupported nested keyword", `{"items": {"$ref": "#"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile([]byte(tt.schema)); err == nil {
+				t.Errorf("expected %s to be rejected", tt.schema)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		// Fields reported invalid, in order
+		fields []string
+	}{
+		{"true accepts anything", `true`, `{"a": [1]}`, nil},
+		{"false rejects everything", `false`, `null`, []string{"v"}},
+		{"annotations are ignored", `{"title": "T", "description": "D", "default": 1}`, `"x"`, nil},
+		{"string type", `{"type": "string"}`, `"x"`, nil},
+		{"wrong type", `{"type": "string"}`, `1`, []string{"v"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer", `{"type": "integer"}`, `2`, nil},
+		{"integer with fraction", `{"type": "integer"}`, `2.5`, []string{"v"}},
+		{"number", `{"type": "number"}`, `2.5`, nil},
+		{"boolean", `{"type": "boolean"}`, `"true"`, []string{"v"}},
+		{"enum", `{"enum": ["a", 1, null]}`, `1`, nil},
+		{"not in enum", `{"enum": ["a", 1, null]}`, `"b"`, []string{"v"}},
+		{"const", `{"const": {"a": 1}}`, `{"a": 1}`, nil},
+		{"not const", `{"const": {"a": 1}}`, `{"a": 2}`, []string{"v"}},
+		{"minLength counts characters", `{"minLength": 2}`, `"é"`, []string{"v"}},
+		{"maxLength counts characters", `{"maxLength": 2}`, `"éé"`, nil},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"abc"`, nil},
+		{"pattern mismatch", `{"pattern": "^[a-z]+$"}`, `"ab1"`, []string{"v"}},
+		{"email", `{"format": "email"}`, `"a@example.com"`, nil},
+		{"invalid email", `{"format": "email"}`, `"A <a@example.com>"`, []string{"v"}},
+		{"unknown format is an annotation", `{"format": "date"}`, `"x"`, nil},
+		{"minimum", `{"minimum": 1}`, `0`, []string{"v"}},
+		{"maximum", `{"maximum": 1}`, `1`, nil},
+		{"keywords skip other types", `{"minLength": 5, "minimum": 3}`, `true`, nil},
+		{"items", `{"items": {"type": "string"}, "maxItems": 2}`, `["a", 1, "c"]`, []string{"v", "v[1]"}},
+		{"minItems", `{"minItems": 1}`, `[]`, []string{"v"}},
+		{
+			name: "object",
+			schema: `{"type": "object", "required": ["name", "age"],
+				"properties": {"name": {"type": "string"}, "age": {"type": "integer"}}}`,
+			value:  `{"name": 1}`,
+			fields: []string{"v.age", "v.name"},
+		},
+		{
+			name:   "no additional properties",
+			schema: `{"properties": {"a": {}}, "additionalProperties": false}`,
+			value:  `{"a": 1, "c": 2, "b": 3}`,
+			fields: []string{"v.b", "v.c"},
+		},
+		{
+			name:   "additional properties schema",
+			schema: `{"properties": {"a": {}}, "additionalProperties": {"type": "string"}}`,
+			value:  `{"a": 1, "b": 2}`,
+			fields: []string{"v.b"},
+		},
+		{
+			name:   "nested paths",
+			schema: `{"properties": {"tags": {"items": {"properties": {"n": {"type": "string"}}}}}}`,
+			value:  `{"tags": [{"n": "a"}, {"n": 1}]}`,
+			fields: []string{"v.tags[1].n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("invalid value: %v", err)
+			}
+			var fields []string
+			for _, fieldErr := range schema.Validate(value, "v") {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("invalid fields %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestPropertyTypes(t *testing.T) {
+	schema, err := Compile([]byte(`{"properties": {"a": {"type": ["string", "null"]}, "b": {}},
+		"additionalProperties": {"type": "number"}}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"a", []string{"string", "null"}},
+		{"b", nil},
+		{"c", []string{"number"}},
+	}
+	for _, tt := range tests {
+		if got := schema.PropertyTypes(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PropertyTypes(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
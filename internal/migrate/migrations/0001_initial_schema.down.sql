@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS team_users;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,90 @@
+-- Schema previously created by GORM AutoMigrate. IF NOT EXISTS lets databases
+-- bootstrapped that way adopt versioned migrations without changes.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    email      text CONSTRAINT uni_users_email UNIQUE,
+    name       text,
+    version    bigint NOT NULL DEFAULT 1,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS teams (
+    id          bigserial PRIMARY KEY,
+    title       text,
+    description text,
+    version     bigint NOT NULL DEFAULT 1,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_teams_deleted_at ON teams (deleted_at);
+
+CREATE TABLE IF NOT EXISTS team_users (
+    id         bigserial PRIMARY KEY,
+    team_id    bigint CONSTRAINT fk_team_users_team REFERENCES teams (id),
+    user_id    bigint CONSTRAINT fk_team_users_user REFERENCES users (id),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_team_users_deleted_at ON team_users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          bigserial PRIMARY KEY,
+    actor       text,
+    action      text,
+    entity_type text,
+    entity_id   bigint,
+    before      jsonb,
+    after       jsonb,
+    changes     jsonb,
+    request_id  text,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_entity ON audit_logs (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id         bigserial PRIMARY KEY,
+    url        text,
+    secret     text,
+    events     jsonb,
+    active     boolean,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               bigserial PRIMARY KEY,
+    subscription_id  bigint,
+    event_id         text,
+    event            text,
+    payload          jsonb,
+    status           text,
+    attempts         bigint,
+    last_status_code bigint,
+    last_error       text,
+    next_attempt_at  timestamptz,
+    delivered_at     timestamptz,
+    created_at       timestamptz,
+    updated_at       timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id            bigserial PRIMARY KEY,
+    event_id      text,
+    type          text,
+    payload       jsonb,
+    created_at    timestamptz,
+    dispatched_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events (dispatched_at);
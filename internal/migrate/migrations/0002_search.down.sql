@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_teams_description_trgm;
+DROP INDEX IF EXISTS idx_teams_title_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
+DROP INDEX IF EXISTS idx_teams_search_vector;
+DROP INDEX IF EXISTS idx_users_search_vector;
+
+ALTER TABLE teams DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
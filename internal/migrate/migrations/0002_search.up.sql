@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Weighted full-text documents, kept up to date by Postgres
+ALTER TABLE users ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(email, '')), 'B')
+) STORED;
+ALTER TABLE teams ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_users_search_vector ON users USING gin (search_vector);
+CREATE INDEX idx_teams_search_vector ON teams USING gin (search_vector);
+
+-- Trigram indexes serve fuzzy matches and substring ILIKE
+CREATE INDEX idx_users_name_trgm ON users USING gin (name gin_trgm_ops);
+CREATE INDEX idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
+CREATE INDEX idx_teams_title_trgm ON teams USING gin (title gin_trgm_ops);
+CREATE INDEX idx_teams_description_trgm ON teams USING gin (description gin_trgm_ops);
@@ -0,0 +1,3 @@
+ALTER TABLE teams ADD COLUMN parent_id bigint
+    CONSTRAINT fk_teams_parent REFERENCES teams (id) ON DELETE SET NULL;
+CREATE INDEX idx_teams_parent_id ON teams (parent_id);
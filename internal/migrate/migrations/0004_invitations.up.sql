@@ -0,0 +1,18 @@
+CREATE TABLE invitations (
+    id           bigserial PRIMARY KEY,
+    team_id      bigint NOT NULL CONSTRAINT fk_invitations_team REFERENCES teams (id) ON DELETE CASCADE,
+    email        text NOT NULL,
+    status       text NOT NULL DEFAULT 'pending',
+    nonce_hash   text NOT NULL,
+    invited_by   text NOT NULL,
+    user_id      bigint CONSTRAINT fk_invitations_user REFERENCES users (id) ON DELETE SET NULL,
+    expires_at   timestamptz NOT NULL,
+    responded_at timestamptz,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+CREATE INDEX idx_invitations_team_id ON invitations (team_id);
+CREATE INDEX idx_invitations_email ON invitations (lower(email));
+
+-- At most one open invitation per team and address
+CREATE UNIQUE INDEX uni_invitations_pending ON invitations (team_id, lower(email)) WHERE status = 'pending';
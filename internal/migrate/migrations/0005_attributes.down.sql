@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS attribute_schemas;
+
+ALTER TABLE teams DROP COLUMN IF EXISTS attributes;
+ALTER TABLE users DROP COLUMN IF EXISTS attributes;
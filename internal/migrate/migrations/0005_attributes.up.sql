@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN attributes jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE teams ADD COLUMN attributes jsonb NOT NULL DEFAULT '{}';
+
+CREATE TABLE attribute_schemas (
+    entity_type text PRIMARY KEY,
+    schema      jsonb NOT NULL,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
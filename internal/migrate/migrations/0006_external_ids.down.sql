@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS uni_teams_external_id;
+DROP INDEX IF EXISTS uni_users_external_id;
+
+ALTER TABLE teams DROP COLUMN IF EXISTS external_id;
+ALTER TABLE teams DROP COLUMN IF EXISTS source;
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS source;
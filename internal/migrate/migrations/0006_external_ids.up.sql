@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN source text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN external_id text NOT NULL DEFAULT '';
+ALTER TABLE teams ADD COLUMN source text NOT NULL DEFAULT '';
+ALTER TABLE teams ADD COLUMN external_id text NOT NULL DEFAULT '';
+
+-- An upstream record maps to at most one live row; deleted rows keep their
+-- IDs so restoring them stays possible unless the ID was reused
+CREATE UNIQUE INDEX uni_users_external_id ON users (source, external_id)
+    WHERE external_id <> '' AND deleted_at IS NULL;
+CREATE UNIQUE INDEX uni_teams_external_id ON teams (source, external_id)
+    WHERE external_id <> '' AND deleted_at IS NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE imports (
+    id         bigserial PRIMARY KEY,
+    actor      text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT '',
+    created_at timestamptz
+);
+
+-- Rejected rows of each file, kept so they can be downloaded, fixed and
+-- imported again
+CREATE TABLE import_files (
+    import_id   bigint NOT NULL CONSTRAINT fk_import_files_import REFERENCES imports (id) ON DELETE CASCADE,
+    file_index  integer NOT NULL,
+    entity_type text NOT NULL,
+    error_csv   bytea,
+    created_at  timestamptz,
+    PRIMARY KEY (import_id, file_index)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE import_files DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE import_files DROP COLUMN IF EXISTS completed;
+ALTER TABLE import_files DROP COLUMN IF EXISTS progress;
+ALTER TABLE import_files DROP COLUMN IF EXISTS checkpoint;
+ALTER TABLE import_files DROP COLUMN IF EXISTS options;
+ALTER TABLE import_files DROP COLUMN IF EXISTS data;
+
+ALTER TABLE imports DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE imports DROP COLUMN IF EXISTS status;
@@ -0,0 +1,14 @@
+ALTER TABLE imports ADD COLUMN status text NOT NULL DEFAULT 'completed';
+ALTER TABLE imports ADD COLUMN updated_at timestamptz;
+
+-- What resuming a file needs: its payload and how to read it, kept until
+-- the file completes, and the outcome of the lines before its checkpoint
+ALTER TABLE import_files ADD COLUMN data bytea;
+ALTER TABLE import_files ADD COLUMN options jsonb;
+ALTER TABLE import_files ADD COLUMN checkpoint integer NOT NULL DEFAULT 0;
+ALTER TABLE import_files ADD COLUMN progress jsonb;
+ALTER TABLE import_files ADD COLUMN completed boolean NOT NULL DEFAULT false;
+ALTER TABLE import_files ADD COLUMN updated_at timestamptz;
+
+-- Files of earlier imports were saved once done
+UPDATE import_files SET completed = true;
@@ -0,0 +1,2 @@
+-- Live rows cannot be told from the ones cleared by the up script, and NULL
+-- is what the baseline schema reads as live too; nothing to undo.
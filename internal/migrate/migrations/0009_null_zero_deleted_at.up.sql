@@ -0,0 +1,6 @@
+-- Before soft deletes, AutoMigrate stored deleted_at as a non-null zero time
+-- ('0001-01-01') on every row. Now that a non-null deleted_at means deleted,
+-- those rows would be hidden from reads and hard-deleted by the purge job.
+UPDATE users SET deleted_at = NULL WHERE deleted_at < '0002-01-01';
+UPDATE teams SET deleted_at = NULL WHERE deleted_at < '0002-01-01';
+UPDATE team_users SET deleted_at = NULL WHERE deleted_at < '0002-01-01';
@@ -0,0 +1,21 @@
+-- UNIQUE (email) covers deleted users too. Refuse rather than fail halfway
+-- when a deleted user's address was reused: purge or rename those users first.
+DO $$
+DECLARE
+    duplicate text;
+BEGIN
+    SELECT email INTO duplicate
+    FROM users
+    WHERE email IS NOT NULL
+    GROUP BY email
+    HAVING count(*) > 1
+    LIMIT 1;
+    IF duplicate IS NOT NULL THEN
+        RAISE EXCEPTION 'cannot restore UNIQUE (email): % is used by more than one user, deleted ones included', duplicate
+            USING HINT = 'Purge or rename the deleted users whose email a live user now has, then roll back again.';
+    END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS uni_users_email;
+ALTER TABLE users ADD CONSTRAINT uni_users_email UNIQUE (email);
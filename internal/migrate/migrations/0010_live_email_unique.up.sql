@@ -0,0 +1,5 @@
+-- An email belongs to at most one live user, ignoring case as invitations
+-- do; a deleted user's address can be used again right away. Fails if live
+-- users' emails differ only in case: merge or rename those users first.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email;
+CREATE UNIQUE INDEX uni_users_email ON users (lower(email)) WHERE deleted_at IS NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uni_team_users_member;
@@ -0,0 +1,6 @@
+-- A user is a live member of a team at most once. Earlier duplicates are
+-- removed, keeping the first membership of each pair.
+UPDATE team_users SET deleted_at = now()
+WHERE deleted_at IS NULL
+  AND id NOT IN (SELECT min(id) FROM team_users WHERE deleted_at IS NULL GROUP BY team_id, user_id);
+CREATE UNIQUE INDEX uni_team_users_member ON team_users (team_id, user_id) WHERE deleted_at IS NULL;
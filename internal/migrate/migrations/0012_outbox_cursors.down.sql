@@ -0,0 +1,11 @@
+-- Events every sink has been handed count as dispatched
+ALTER TABLE outbox_events ADD COLUMN dispatched_at timestamptz;
+UPDATE outbox_events e SET dispatched_at = now()
+WHERE NOT EXISTS (
+    SELECT 1 FROM outbox_cursors c WHERE (c.txid, c.last_id) < (e.txid, e.id)
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events (dispatched_at);
+
+DROP TABLE outbox_cursors;
+DROP INDEX IF EXISTS idx_outbox_events_txid;
+ALTER TABLE outbox_events DROP COLUMN txid;
@@ -0,0 +1,26 @@
+-- Events are relayed in the order of the transactions that wrote them, and
+-- only once every transaction that started before them has ended, so no
+-- event can commit behind one a sink has already been handed. Existing
+-- events sort first, by id.
+ALTER TABLE outbox_events ADD COLUMN txid bigint NOT NULL DEFAULT 0;
+ALTER TABLE outbox_events ALTER COLUMN txid SET DEFAULT pg_current_xact_id()::text::bigint;
+CREATE INDEX idx_outbox_events_txid ON outbox_events (txid, id);
+
+-- How far each sink has got through the outbox
+CREATE TABLE outbox_cursors (
+    sink       text PRIMARY KEY,
+    txid       bigint NOT NULL,
+    last_id    bigint NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- The built-in sinks carry on from where the shared relay stopped
+INSERT INTO outbox_cursors (sink, txid, last_id)
+SELECT sink, 0, coalesce(
+    (SELECT min(id) - 1 FROM outbox_events WHERE dispatched_at IS NULL),
+    (SELECT max(id) FROM outbox_events),
+    0)
+FROM unnest(ARRAY['log', 'webhook', 'redis']) AS sink;
+
+DROP INDEX IF EXISTS idx_outbox_events_dispatched_at;
+ALTER TABLE outbox_events DROP COLUMN dispatched_at;
@@ -0,0 +1,2 @@
+ALTER TABLE imports DROP COLUMN IF EXISTS last_team_id;
+ALTER TABLE imports DROP COLUMN IF EXISTS last_user_id;
@@ -0,0 +1,8 @@
+-- Resumed imports tell the records an earlier run wrote by ID rather than by
+-- timestamps from the replicas' clocks. Existing imports mark the records
+-- created before them.
+ALTER TABLE imports ADD COLUMN last_user_id bigint NOT NULL DEFAULT 0;
+ALTER TABLE imports ADD COLUMN last_team_id bigint NOT NULL DEFAULT 0;
+UPDATE imports SET
+    last_user_id = coalesce((SELECT max(id) FROM users WHERE created_at < imports.created_at), 0),
+    last_team_id = coalesce((SELECT max(id) FROM teams WHERE created_at < imports.created_at), 0);
@@ -0,0 +1,2 @@
+-- Databases created by 0001 had all of this before the up script ran, and
+-- cannot be told from adopted ones; nothing to undo.
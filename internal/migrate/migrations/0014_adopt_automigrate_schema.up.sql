@@ -0,0 +1,35 @@
+-- Databases bootstrapped by AutoMigrate adopt 0001 as a no-op, but lack what
+-- the baseline models did not have. AutoMigrate of TeamUser did add id and
+-- the timestamps to the join table, which the migrations before this one
+-- rely on; the rest is added here. Databases created by 0001 have it all.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE team_users ADD COLUMN IF NOT EXISTS id bigserial;
+ALTER TABLE team_users ADD COLUMN IF NOT EXISTS created_at timestamptz;
+ALTER TABLE team_users ADD COLUMN IF NOT EXISTS updated_at timestamptz;
+ALTER TABLE team_users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+-- AutoMigrate created team_users as the many2many join table, keyed by
+-- (user_id, team_id). Memberships are soft-deleted now, so with that key a
+-- removed member could never be added back; key the table by id instead.
+DO $$
+DECLARE
+    pkey text;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_index i
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+        WHERE i.indrelid = 'team_users'::regclass AND i.indisprimary
+          AND i.indnatts = 1 AND a.attname = 'id'
+    ) THEN
+        SELECT conname INTO pkey
+        FROM pg_constraint
+        WHERE conrelid = 'team_users'::regclass AND contype = 'p';
+        IF pkey IS NOT NULL THEN
+            EXECUTE format('ALTER TABLE team_users DROP CONSTRAINT %I', pkey);
+        END IF;
+        ALTER TABLE team_users ADD PRIMARY KEY (id);
+    END IF;
+END
+$$;
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// AttributeSchema is the JSON Schema that attributes of one entity type
+// ("users" or "teams") must satisfy.
+type AttributeSchema struct {
+	EntityType string    `json:"entity_type" gorm:"primaryKey"`
+	Schema     JSON      `json:"schema"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Actor      string    `json:"actor" gorm:"index"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type" gorm:"index:idx_audit_entity"`
+	EntityID   uint      `json:"entity_id" gorm:"index:idx_audit_entity"`
+	Before     JSON      `json:"before,omitempty"`
+	After      JSON      `json:"after,omitempty"`
+	Changes    JSON      `json:"changes,omitempty"`
+	RequestID  string    `json:"request_id"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
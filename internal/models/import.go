@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	// Stopped by a database failure; it can be resumed
+	ImportStatusFailed = "failed"
+)
+
+// Import is one run of the importer. Running imports touch UpdatedAt
+// periodically, so one whose process died can be told from a live one.
+type Import struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	Actor     string       `json:"actor"`
+	RequestID string       `json:"request_id"`
+	Status    string       `json:"status"`
+	Files     []ImportFile `json:"files,omitempty" gorm:"foreignKey:ImportID"`
+	// The highest user and team IDs when the import started. Records with
+	// higher IDs may come from an earlier run of a resumed import; unlike
+	// timestamps, IDs do not depend on the replicas' clocks.
+	LastUserID uint      `json:"-"`
+	LastTeamID uint      `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ImportFile is a file of an import, identified by its position in the
+// request.
+type ImportFile struct {
+	ImportID   uint   `json:"import_id" gorm:"primaryKey;autoIncrement:false"`
+	FileIndex  int    `json:"file_index" gorm:"primaryKey;autoIncrement:false"`
+	EntityType string `json:"entity_type"`
+	// Options holds how to read Data, i.e. the file's request without its
+	// data
+	Options JSON `json:"-"`
+	// Data is the decoded file, kept until the file completes so that the
+	// import can be resumed
+	Data []byte `json:"-"`
+	// Checkpoint counts the leading lines whose outcome is saved in
+	// Progress; resuming starts below them
+	Checkpoint int  `json:"checkpoint"`
+	Progress   JSON `json:"-"`
+	Completed  bool `json:"completed"`
+	// ErrorCSV holds the rejected rows with an error column, or nil when
+	// every row was imported
+	ErrorCSV  []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+	InvitationStatusRevoked  = "revoked"
+	// Pending invitations past their expiry are marked expired when the
+	// address is invited again
+	InvitationStatusExpired = "expired"
+)
+
+type Invitation struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	TeamID uint   `json:"team_id" gorm:"index"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	// NonceHash is the SHA-256 of the token's nonce; the token itself is
+	// never stored
+	NonceHash   string     `json:"-"`
+	InvitedBy   string     `json:"invited_by"`
+	UserID      *uint      `json:"user_id,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Expired reports whether a pending invitation can no longer be answered.
+func (i *Invitation) Expired(now time.Time) bool {
+	return i.Status == InvitationStatusPending && !now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+type OutboxEvent struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	EventID string `json:"event_id" gorm:"uniqueIndex"`
+	Type    string `json:"type"`
+	Payload JSON   `json:"payload"`
+	// TxID is the writing transaction's ID, set by the database
+	TxID      int64     `json:"-" gorm:"column:txid;->"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxCursor is the last event relayed to a sink.
+type OutboxCursor struct {
+	Sink      string `gorm:"primaryKey"`
+	TxID      int64  `gorm:"column:txid"`
+	LastID    uint   `gorm:"column:last_id"`
+	UpdatedAt time.Time
+}
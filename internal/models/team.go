@@ -2,14 +2,21 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Team struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Users       []User    `json:"users" gorm:"many2many:team_users;"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	DeletedAt   time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	ParentID    *uint          `json:"parent_id" gorm:"index"`
+	Source      string         `json:"source,omitempty" gorm:"not null;default:''"`
+	ExternalID  string         `json:"external_id,omitempty" gorm:"not null;default:''"`
+	Attributes  Attributes     `json:"attributes" gorm:"not null;default:'{}'"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
+	Users       []User         `json:"users" gorm:"many2many:team_users;"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
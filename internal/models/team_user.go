@@ -2,13 +2,15 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type TeamUser struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	TeamID    uint      `json:"team_id"`
-	UserID    uint      `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	TeamID    uint           `json:"team_id"`
+	UserID    uint           `json:"user_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
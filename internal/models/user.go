@@ -2,14 +2,20 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"unique"`
-	Name      string    `json:"name"`
-	Teams     []Team    `json:"teams" gorm:"many2many:team_users;"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	Email      string         `json:"email"`
+	Name       string         `json:"name"`
+	Source     string         `json:"source,omitempty" gorm:"not null;default:''"`
+	ExternalID string         `json:"external_id,omitempty" gorm:"not null;default:''"`
+	Attributes Attributes     `json:"attributes" gorm:"not null;default:'{}'"`
+	Version    uint           `json:"version" gorm:"not null;default:1"`
+	Teams      []Team         `json:"teams" gorm:"many2many:team_users;"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	ID  uint   `json:"id" gorm:"primaryKey"`
+	URL string `json:"url"`
+	// Secret signs deliveries; it is only returned when the subscription is
+	// created
+	Secret    string     `json:"-"`
+	Events    StringList `json:"events"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"index"`
+	EventID        string     `json:"event_id" gorm:"index"`
+	Event          string     `json:"event"`
+	Payload        JSON       `json:"payload"`
+	Status         string     `json:"status" gorm:"index:idx_webhook_delivery_due"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_delivery_due"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
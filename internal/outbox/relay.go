@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-sample/internal/events"
+	"go-sample/internal/repository"
+)
+
+// Sink receives events relayed from the outbox. Delivery is at-least-once:
+// a sink may see the same event ID again after a crash or a failure later in
+// its batch, so it must be idempotent or dedupe on Event.ID.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event events.Event) error
+}
+
+type Options struct {
+	// PollInterval is how often the outbox is checked for new events
+	PollInterval time.Duration
+	// BatchSize is the number of events relayed to a sink at a time
+	BatchSize int
+	// Retention is how long events are kept; events a sink has not been
+	// handed yet are kept until it has
+	Retention time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    24 * time.Hour,
+	}
+}
+
+// Relay moves committed events from the outbox table to its sinks. Each sink
+// is relayed on its own, in order, so a failing or slow sink only holds up
+// itself.
+type Relay struct {
+	repo    repository.OutboxRepository
+	sinks   []Sink
+	options Options
+}
+
+func NewRelay(repo repository.OutboxRepository, sinks []Sink, options Options) *Relay {
+	return &Relay{
+		repo:    repo,
+		sinks:   sinks,
+		options: options,
+	}
+}
+
+// Run relays events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sink := range r.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			r.runSink(ctx, sink)
+		}(sink)
+	}
+	defer wg.Wait()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.repo.Purge(time.Now().Add(-r.options.Retention), r.sinkNames()); err != nil {
+			log.Printf("Outbox: failed to purge dispatched events: %v", err)
+		}
+	}
+}
+
+func (r *Relay) runSink(ctx context.Context, sink Sink) {
+	ticker := time.NewTicker(r.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.drain(ctx, sink)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain relays full batches to sink until it has every event or fails.
+func (r *Relay) drain(ctx context.Context, sink Sink) {
+	for ctx.Err() == nil {
+		n, err := r.repo.Relay(ctx, sink.Name(), r.options.BatchSize, func(event events.Event) error {
+			if err := sink.Send(ctx, event); err != nil {
+				return fmt.Errorf("sink %s failed on event %s: %w", sink.Name(), event.ID, err)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Outbox: relay stopped, will retry: %v", err)
+			return
+		}
+		if n < r.options.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) sinkNames() []string {
+	names := make([]string, len(r.sinks))
+	for i, sink := range r.sinks {
+		names[i] = sink.Name()
+	}
+	return names
+}
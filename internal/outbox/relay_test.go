@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go-sample/internal/events"
+)
+
+// fakeOutboxRepository keeps events in order and a cursor per sink.
+type fakeOutboxRepository struct {
+	mu      sync.Mutex
+	events  []events.Event
+	cursors map[string]int
+}
+
+func (r *fakeOutboxRepository) Relay(ctx context.Context, sink string, limit int, handle func(event events.Event) error) (int, error) {
+	r.mu.Lock()
+	cursor := r.cursors[sink]
+	pending := r.events[cursor:]
+	r.mu.Unlock()
+
+	dispatched := 0
+	for _, event := range pending {
+		if dispatched == limit {
+			break
+		}
+		if err := handle(event); err != nil {
+			r.advance(sink, dispatched)
+			return dispatched, err
+		}
+		dispatched++
+	}
+	r.advance(sink, dispatched)
+	return dispatched, nil
+}
+
+func (r *fakeOutboxRepository) advance(sink string, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursors[sink] += n
+}
+
+func (r *fakeOutboxRepository) Purge(before time.Time, sinks []string) (int64, error) {
+	return 0, nil
+}
+
+// recordingSink keeps the IDs of the events it is sent and fails from the
+// failAt-th one, if set, while failing is true.
+type recordingSink struct {
+	name    string
+	failAt  int
+	failing bool
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Send(ctx context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing && len(s.ids) == s.failAt {
+		return errors.New("unavailable")
+	}
+	s.ids = append(s.ids, event.ID)
+	return nil
+}
+
+func (s *recordingSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ids...)
+}
+
+func TestRelayFailingSinkOnlyHoldsUpItself(t *testing.T) {
+	repo := &fakeOutboxRepository{cursors: make(map[string]int)}
+	var want []string
+	for i := 0; i < 7; i++ {
+		event := events.Event{ID: fmt.Sprintf("e%d", i), Type: events.UserCreated}
+		repo.events = append(repo.events, event)
+		want = append(want, event.ID)
+	}
+	healthy := &recordingSink{name: "healthy"}
+	broken := &recordingSink{name: "broken", failAt: 2, failing: true}
+	relay := NewRelay(repo, []Sink{broken, healthy}, Options{PollInterval: time.Hour, BatchSize: 3, Retention: time.Hour})
+
+	for _, sink := range relay.sinks {
+		relay.drain(context.Background(), sink)
+	}
+	if got := healthy.received(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("healthy sink got %v, want %v", got, want)
+	}
+	if got := broken.received(); fmt.Sprint(got) != fmt.Sprint(want[:2]) {
+		t.Errorf("broken sink got %v, want %v", got, want[:2])
+	}
+
+	// Once it recovers, the broken sink carries on in order from where it failed
+	broken.failing = false
+	relay.drain(context.Background(), broken)
+	if got := broken.received(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("recovered sink got %v, want %v", got, want)
+	}
+	if got := healthy.received(); len(got) != len(want) {
+		t.Errorf("healthy sink got %d events again", len(got)-len(want))
+	}
+}
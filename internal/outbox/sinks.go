@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go-sample/internal/events"
+	"go-sample/internal/webhook"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// LogSink writes every event to the application log.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Send(ctx context.Context, event events.Event) error {
+	log.Printf("Event %s %s", event.Type, event.ID)
+	return nil
+}
+
+// WebhookSink queues webhook deliveries for the event. The dispatcher skips
+// subscriptions that already have a delivery for the event ID.
+type WebhookSink struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookSink(dispatcher *webhook.Dispatcher) *WebhookSink {
+	return &WebhookSink{
+		dispatcher: dispatcher,
+	}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Send(ctx context.Context, event events.Event) error {
+	return s.dispatcher.Enqueue(event)
+}
+
+// RedisStreamSink appends events to a Redis stream. Consumers dedupe on the
+// "id" field.
+type RedisStreamSink struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisStreamSink(client *redis.Client, stream string, maxLen int64) *RedisStreamSink {
+	return &RedisStreamSink{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (s *RedisStreamSink) Name() string { return "redis" }
+
+func (s *RedisStreamSink) Send(ctx context.Context, event events.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"id":          event.ID,
+			"type":        event.Type,
+			"occurred_at": event.OccurredAt.Format(time.RFC3339Nano),
+			"data":        string(data),
+		},
+	}).Err()
+}
@@ -0,0 +1,292 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// ErrTestFailed is returned when a JSON Patch "test" operation does not match.
+var ErrTestFailed = errors.New("test operation failed")
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
+
+type operation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc. Operations are applied in
+// order and the patch is rejected as a whole if any of them fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("invalid JSON patch: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		target, err = applyOperation(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func applyOperation(doc interface{}, op operation) (interface{}, error) {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		var value interface{}
+		if err := json.Unmarshal(*op.Value, &value); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, op.Path, value)
+		case "replace":
+			if _, err := get(doc, op.Path); err != nil {
+				return nil, err
+			}
+			removed, err := remove(doc, op.Path)
+			if err != nil {
+				return nil, err
+			}
+			return add(removed, op.Path, value)
+		default:
+			current, err := get(doc, op.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, op.Path)
+	case "move", "copy":
+		value, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, errors.New("cannot move a value into one of its children")
+			}
+			if doc, err = remove(doc, op.From); err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, op.Path, deepCopy(value))
+	default:
+		return nil, fmt.Errorf("unsupported op %q", op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", pointer)
+			}
+			current = value
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("path %q not found", pointer)
+		}
+	}
+	return current, nil
+}
+
+func add(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[last] = value
+			return node, nil
+		case []interface{}:
+			if last == "-" {
+				return append(node, value), nil
+			}
+			idx, err := arrayIndex(last, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("path %q not found", pointer)
+		}
+	})
+}
+
+func remove(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[last]; !ok {
+				return nil, fmt.Errorf("path %q not found", pointer)
+			}
+			delete(node, last)
+			return node, nil
+		case []interface{}:
+			idx, err := arrayIndex(last, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:idx], node[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path %q not found", pointer)
+		}
+	})
+}
+
+// update walks to the parent of the last token, lets fn replace it, and
+// rebuilds the path so slice reallocations are written back.
+func update(doc interface{}, tokens []string, fn func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path segment %q not found", tokens[0])
+		}
+		updated, err := update(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = updated
+		return node, nil
+	case []interface{}:
+		idx, err := arrayIndex(tokens[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(node[idx], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = updated
+		return node, nil
+	default:
+		return nil, fmt.Errorf("path segment %q not found", tokens[0])
+	}
+}
+
+// arrayIndex parses an RFC 6901 array index: digits without a sign or
+// leading zeros.
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || strings.TrimLeft(token, "0123456789") != "" || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx > max {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return idx, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	data, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
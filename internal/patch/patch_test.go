@@ -0,0 +1,258 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result %s is not JSON: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expected %s is not JSON: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// The examples of RFC 6902 appendix A, except A.13 whose duplicate keys
+// encoding/json cannot detect
+func TestJSONPatchRFCExamples(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name: "A.8 testing a value: success",
+			doc:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"},
+				{"op": "test", "path": "/foo/1", "value": 2}]`,
+			want: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:  "A.9 testing a value: error",
+			doc:   `{"baz": "qux"}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			want:  `{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			name:  "A.12 adding to a nonexistent target",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}]`,
+			want:  `{"/": 9, "~1": 10}`,
+		},
+		{
+			name:  "A.15 comparing strings and numbers",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			checkResult(t, got, err, tt.want, tt.err)
+		})
+	}
+}
+
+func TestJSONPatchMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+	}{
+		{"invalid document", `{"foo":`, `[]`},
+		{"patch is not an array", `{}`, `{"op": "add", "path": "/a", "value": 1}`},
+		{"invalid patch JSON", `{}`, `[{"op": "add"`},
+		{"unknown op", `{}`, `[{"op": "merge", "path": "/a", "value": 1}]`},
+		{"missing op", `{}`, `[{"path": "/a", "value": 1}]`},
+		{"add without value", `{}`, `[{"op": "add", "path": "/a"}]`},
+		{"replace without value", `{"a": 1}`, `[{"op": "replace", "path": "/a"}]`},
+		{"test without value", `{"a": 1}`, `[{"op": "test", "path": "/a"}]`},
+		{"pointer without leading slash", `{"a": 1}`, `[{"op": "remove", "path": "a"}]`},
+		{"remove missing member", `{"a": 1}`, `[{"op": "remove", "path": "/b"}]`},
+		{"replace missing member", `{"a": 1}`, `[{"op": "replace", "path": "/b", "value": 2}]`},
+		{"remove whole document", `{"a": 1}`, `[{"op": "remove", "path": ""}]`},
+		{"index with leading zero", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/01"}]`},
+		{"index with sign", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/+1"}]`},
+		{"negative index", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/-1"}]`},
+		{"index past the end", `{"a": [1, 2]}`, `[{"op": "add", "path": "/a/3", "value": 3}]`},
+		{"remove the end marker", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/-"}]`},
+		{"move into own child", `{"a": {"b": 1}}`, `[{"op": "move", "from": "/a", "path": "/a/c"}]`},
+		{"copy from missing path", `{"a": 1}`, `[{"op": "copy", "from": "/b", "path": "/c"}]`},
+		{"traverse a scalar", `{"a": 1}`, `[{"op": "add", "path": "/a/b", "value": 2}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch)); err == nil {
+				t.Errorf("expected an error, got %s", got)
+			}
+		})
+	}
+}
+
+func TestJSONPatchIsAtomic(t *testing.T) {
+	_, err := JSONPatch([]byte(`{"a": 1}`), []byte(`[
+		{"op": "add", "path": "/b", "value": 2},
+		{"op": "test", "path": "/a", "value": 2}
+	]`))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("expected ErrTestFailed, got %v", err)
+	}
+}
+
+func TestJSONPatchCopyIsIndependent(t *testing.T) {
+	got, err := JSONPatch([]byte(`{"a": {"b": 1}}`), []byte(`[
+		{"op": "copy", "from": "/a", "path": "/c"},
+		{"op": "replace", "path": "/c/b", "value": 2}
+	]`))
+	checkResult(t, got, err, `{"a": {"b": 1}, "c": {"b": 2}}`, nil)
+}
+
+// The examples of RFC 7396 appendix A
+func TestMergePatchRFCExamples(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc+" "+tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			checkResult(t, got, err, tt.want, nil)
+		})
+	}
+}
+
+func TestMergePatchMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+	}{
+		{"invalid document", `{"a":`, `{}`},
+		{"empty document", ``, `{}`},
+		{"invalid patch", `{}`, `{"a":}`},
+		{"empty patch", `{}`, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := MergePatch([]byte(tt.doc), []byte(tt.patch)); err == nil {
+				t.Errorf("expected an error, got %s", got)
+			}
+		})
+	}
+}
+
+// errAny expects an error of any kind
+var errAny = errors.New("any error")
+
+func checkResult(t *testing.T, got []byte, err error, want string, wantErr error) {
+	t.Helper()
+	switch {
+	case wantErr == errAny:
+		if err == nil {
+			t.Errorf("expected an error, got %s", got)
+		}
+	case wantErr != nil:
+		if !errors.Is(err, wantErr) {
+			t.Errorf("expected %v, got %v", wantErr, err)
+		}
+	case err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+		assertJSON(t, got, want)
+	}
+}
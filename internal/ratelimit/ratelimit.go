@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether a request identified by key may proceed. When it
+// may not, retryAfter reports how long the caller should wait.
+type Limiter interface {
+	Allow(key string) (allowed bool, retryAfter time.Duration)
+}
+
+// maxBuckets bounds the keys a MemoryLimiter tracks at once.
+const maxBuckets = 100000
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryLimiter is a per-key token bucket kept in process memory.
+type MemoryLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter(rate float64, burst int) *MemoryLimiter {
+	return &MemoryLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.evict(now)
+		}
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// Refill based on elapsed time
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	idle := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// evict makes room for new buckets: idle ones go first, then an arbitrary
+// tenth, which only lets those keys start over with a full burst.
+func (l *MemoryLimiter) evict(now time.Time) {
+	l.lastSweep = time.Time{}
+	l.sweep(now)
+
+	for key := range l.buckets {
+		if len(l.buckets) < maxBuckets-maxBuckets/10 {
+			return
+		}
+		delete(l.buckets, key)
+	}
+}
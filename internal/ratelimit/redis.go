@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills and takes a token atomically. It uses the Redis
+// server clock so replicas with skewed clocks share one consistent bucket.
+// Returns {allowed, retry_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+// RedisLimiter is a token bucket shared by all replicas through Redis. If
+// Redis is unavailable it falls back to a local in-memory bucket.
+type RedisLimiter struct {
+	client   *redis.Client
+	rate     float64
+	burst    int
+	fallback *MemoryLimiter
+}
+
+func NewRedisLimiter(client *redis.Client, rate float64, burst int) *RedisLimiter {
+	return &RedisLimiter{
+		client:   client,
+		rate:     rate,
+		burst:    burst,
+		fallback: NewMemoryLimiter(rate, burst),
+	}
+}
+
+func (l *RedisLimiter) Allow(key string) (bool, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{"ratelimit:" + key}, l.rate, l.burst).Int64Slice()
+	if err != nil || len(res) != 2 {
+		log.Printf("Rate limiter: redis unavailable, using local bucket: %v", err)
+		return l.fallback.Allow(key)
+	}
+
+	if res[0] == 1 {
+		return true, 0
+	}
+	return false, time.Duration(res[1]) * time.Millisecond
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go-sample/internal/jsonschema"
+	"go-sample/internal/models"
+	"go-sample/internal/validation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Entity types that can have an attribute schema
+const (
+	AttributeEntityUsers = "users"
+	AttributeEntityTeams = "teams"
+)
+
+// AttributeError lists the attributes that violate the registered schema.
+type AttributeError struct {
+	Errors validation.Errors
+}
+
+func (e *AttributeError) Error() string {
+	return "invalid attributes: " + e.Errors.Error()
+}
+
+func (e *AttributeError) Unwrap() error {
+	return ErrInvalidAttributes
+}
+
+type attributeSchemaRepository struct {
+	db *gorm.DB
+}
+
+func NewAttributeSchemaRepository(db *gorm.DB) AttributeSchemaRepository {
+	return &attributeSchemaRepository{db: db}
+}
+
+func (r *attributeSchemaRepository) Get(entityType string) (*models.AttributeSchema, error) {
+	var schema models.AttributeSchema
+	if err := r.db.Where("entity_type = ?", entityType).First(&schema).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Put registers or replaces the schema. It applies to writes from then on;
+// stored attributes are not revalidated.
+func (r *attributeSchemaRepository) Put(ctx context.Context, schema *models.AttributeSchema) error {
+	if _, err := jsonschema.Compile(schema.Schema); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := findAttributeSchema(tx, schema.EntityType)
+		if err != nil {
+			return err
+		}
+		if before != nil {
+			schema.CreatedAt = before.CreatedAt
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"schema", "updated_at"}),
+		}).Create(schema).Error; err != nil {
+			return err
+		}
+		action := AuditActionUpdate
+		if before == nil {
+			action = AuditActionCreate
+		}
+		return recordAudit(ctx, tx, action, AuditEntityAttributeSchema, 0, before, schema)
+	})
+}
+
+// Delete removes the schema so attributes of the entity type are no longer
+// validated.
+func (r *attributeSchemaRepository) Delete(ctx context.Context, entityType string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := findAttributeSchema(tx, entityType)
+		if err != nil || before == nil {
+			return err
+		}
+		if err := tx.Where("entity_type = ?", entityType).Delete(&models.AttributeSchema{}).Error; err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditActionDelete, AuditEntityAttributeSchema, 0, before, nil)
+	})
+}
+
+// findAttributeSchema returns the entity type's schema, or nil if none is
+// registered.
+func findAttributeSchema(tx *gorm.DB, entityType string) (*models.AttributeSchema, error) {
+	var schemas []models.AttributeSchema
+	if err := tx.Where("entity_type = ?", entityType).Limit(1).Find(&schemas).Error; err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	return &schemas[0], nil
+}
+
+// validateAttributes checks attributes against the entity type's registered
+// schema, if any, and fails with an *AttributeError listing every violation.
+func validateAttributes(tx *gorm.DB, entityType string, attributes models.Attributes) error {
+	compiled, err := loadAttributeSchema(tx, entityType)
+	if err != nil {
+		return err
+	}
+	return checkAttributes(compiled, attributes)
+}
+
+// loadAttributeSchema compiles the entity type's registered schema, or
+// returns nil when there is none.
+func loadAttributeSchema(tx *gorm.DB, entityType string) (*jsonschema.Schema, error) {
+	schema, err := findAttributeSchema(tx, entityType)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	compiled, err := jsonschema.Compile(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("stored %s attribute schema is invalid: %w", entityType, err)
+	}
+	return compiled, nil
+}
+
+// checkAttributes validates attributes against a schema from
+// loadAttributeSchema; a nil schema accepts anything.
+func checkAttributes(compiled *jsonschema.Schema, attributes models.Attributes) error {
+	if compiled == nil {
+		return nil
+	}
+
+	// Validate the JSON form so values set from Go, e.g. ints, compare the
+	// same as decoded ones
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	var value interface{} = map[string]interface{}{}
+	if attributes != nil {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	if errs := compiled.Validate(value, "attributes"); len(errs) > 0 {
+		return &AttributeError{Errors: errs}
+	}
+	return nil
+}
+
+// filterAttributes narrows query to rows whose attributes match every filter.
+func filterAttributes(query *gorm.DB, filters map[string]string) *gorm.DB {
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query = query.Where("attributes ->> ? = ?", key, filters[key])
+	}
+	return query
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"go-sample/internal/models"
+	"go-sample/internal/requestctx"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionCreate       = "create"
+	AuditActionUpdate       = "update"
+	AuditActionDelete       = "delete"
+	AuditActionRestore      = "restore"
+	AuditActionPurge        = "purge"
+	AuditActionAddMember    = "add_member"
+	AuditActionRemoveMember = "remove_member"
+	AuditActionImport       = "import"
+	AuditActionInvite       = "invite"
+	AuditActionAccept       = "accept"
+	AuditActionDecline      = "decline"
+	AuditActionRevoke       = "revoke"
+
+	AuditEntityUser            = "user"
+	AuditEntityTeam            = "team"
+	AuditEntityImport          = "import"
+	AuditEntityInvitation      = "invitation"
+	AuditEntityAttributeSchema = "attribute_schema"
+)
+
+// Fields left out of audit snapshots: associations are audited separately and
+// timestamps change on every write.
+var auditIgnoredFields = []string{"users", "teams", "updated_at"}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   uint
+	Actor      string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (r *auditRepository) Record(ctx context.Context, action, entityType string, entityID uint, before, after interface{}) error {
+	return recordAudit(ctx, r.db.WithContext(ctx), action, entityType, entityID, before, after)
+}
+
+func (r *auditRepository) List(filter AuditFilter) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+
+	query := r.db.Order("created_at DESC, id DESC")
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordAudit writes an audit entry using tx, so callers inside a transaction
+// get the entry committed (or rolled back) together with the change itself.
+func recordAudit(ctx context.Context, tx *gorm.DB, action, entityType string, entityID uint, before, after interface{}) error {
+	entry, err := newAuditEntry(ctx, action, entityType, entityID, before, after)
+	if err != nil {
+		return err
+	}
+	return tx.Create(entry).Error
+}
+
+// newAuditEntry builds the audit row recordAudit writes.
+func newAuditEntry(ctx context.Context, action, entityType string, entityID uint, before, after interface{}) (*models.AuditLog, error) {
+	beforeMap, err := auditSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := auditSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.AuditLog{
+		Actor:      requestctx.Actor(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  requestctx.RequestID(ctx),
+	}
+	if entry.Before, err = marshalAuditJSON(beforeMap); err != nil {
+		return nil, err
+	}
+	if entry.After, err = marshalAuditJSON(afterMap); err != nil {
+		return nil, err
+	}
+	if entry.Changes, err = marshalAuditJSON(auditDiff(beforeMap, afterMap)); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// auditSnapshot flattens v into a JSON object with ignored fields removed.
+func auditSnapshot(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, field := range auditIgnoredFields {
+		delete(snapshot, field)
+	}
+	return snapshot, nil
+}
+
+// auditDiff returns {field: {"from": old, "to": new}} for every field that differs.
+func auditDiff(before, after map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+
+	for key, newValue := range after {
+		oldValue, ok := before[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes[key] = map[string]interface{}{"from": oldValue, "to": newValue}
+		}
+	}
+	for key, oldValue := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = map[string]interface{}{"from": oldValue, "to": nil}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func marshalAuditJSON(v map[string]interface{}) (models.JSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return models.JSON(data), nil
+}
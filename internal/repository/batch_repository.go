@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	"go-sample/internal/cache"
+
+	"gorm.io/gorm"
+)
+
+type batchRepository struct {
+	db    *gorm.DB
+	cache *cache.Cache
+}
+
+func NewBatchRepository(db *gorm.DB, cache *cache.Cache) BatchRepository {
+	return &batchRepository{
+		db:    db,
+		cache: cache,
+	}
+}
+
+// Atomic binds the repositories to the transaction, so their own
+// transactions become savepoints. They invalidate the cache as they go, which
+// can be refilled from the database before the commit; the invalidations are
+// replayed once the transaction has ended.
+func (r *batchRepository) Atomic(ctx context.Context, fn func(users UserRepository, teams TeamRepository) error) error {
+	tracking := r.cache.Tracking()
+	defer tracking.Replay()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewUserRepository(tx, tracking), NewTeamRepository(tx, tracking))
+	})
+	return translateError(err)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// insertBatchSize bounds the rows of one multi-row INSERT so its bind
+// parameters stay below Postgres' limit of 65535
+const insertBatchSize = 1000
+
+// recordCreations writes the create audit entries and outbox events for
+// records inserted together, with multi-row INSERTs.
+func recordCreations(ctx context.Context, tx *gorm.DB, entityType, eventType string, ids []uint, records []interface{}) error {
+	entries := make([]*models.AuditLog, len(records))
+	rows := make([]*models.OutboxEvent, len(records))
+	for i, record := range records {
+		var err error
+		if entries[i], err = newAuditEntry(ctx, AuditActionCreate, entityType, ids[i], nil, record); err != nil {
+			return err
+		}
+		if rows[i], err = newOutboxRow(events.New(eventType, record)); err != nil {
+			return err
+		}
+	}
+	if err := tx.CreateInBatches(entries, insertBatchSize).Error; err != nil {
+		return err
+	}
+	return tx.CreateInBatches(rows, insertBatchSize).Error
+}
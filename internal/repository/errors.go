@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = gorm.ErrRecordNotFound
+	// ErrNotDeleted is returned when restoring a record that is not deleted
+	ErrNotDeleted = errors.New("record is not deleted")
+	// ErrVersionConflict is returned when the expected version is stale
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrDuplicateEmail is returned when another user already has the email
+	ErrDuplicateEmail = errors.New("email is already in use")
+	// ErrDuplicateExternalID is returned when another live record already
+	// has the source and external ID
+	ErrDuplicateExternalID = errors.New("external ID is already in use")
+	// ErrInvalidReference is returned when a referenced record does not exist
+	ErrInvalidReference = errors.New("referenced record does not exist")
+	// ErrHierarchyCycle is returned when a team would become its own ancestor
+	ErrHierarchyCycle = errors.New("team cannot be its own ancestor")
+	// ErrInvalidAttributes is returned, wrapped in an *AttributeError, when
+	// attributes violate the registered schema
+	ErrInvalidAttributes = errors.New("attributes do not match the schema")
+	// ErrInvalidSchema is returned when registering a malformed or
+	// unsupported attribute schema
+	ErrInvalidSchema = errors.New("invalid attribute schema")
+	// ErrInvitationExpired is returned when answering an invitation after
+	// its expiry
+	ErrInvitationExpired = errors.New("invitation has expired")
+	// ErrInvitationClosed is returned when answering an invitation that was
+	// already accepted, declined or revoked
+	ErrInvitationClosed = errors.New("invitation is no longer pending")
+	// ErrAlreadyMember is returned when adding or inviting a user who is
+	// already a member of the team
+	ErrAlreadyMember = errors.New("user is already a member of the team")
+	// ErrNameRequired is returned when accepting an invitation for an email
+	// without a user and no name was given to create one
+	ErrNameRequired = errors.New("name is required to create the invited user")
+	// ErrConflict is returned when the change collides with concurrent writes
+	// or another unique value
+	ErrConflict = errors.New("conflicting change")
+	// ErrImportNotResumable is returned when resuming an import that
+	// completed or is still running
+	ErrImportNotResumable = errors.New("import cannot be resumed")
+)
+
+// Postgres SQLSTATE codes mapped by translateError
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// SQLSTATE codes of failures that say nothing about the statement: lost
+// connections (class 08 is matched as a whole), server shutdowns and writes
+// reaching a standby during failover
+var pgTransientCodes = map[string]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"25006": true, // read_only_sql_transaction
+}
+
+// IsTransient reports whether err comes from the database being unavailable
+// rather than from the data written, so the same write may succeed later.
+func IsTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || pgTransientCodes[pgErr.Code]
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || pgconn.SafeToRetry(err) || pgconn.Timeout(err)
+}
+
+// translateError maps Postgres errors to the typed errors above. The driver
+// error stays wrapped for logging but callers only need errors.Is.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if strings.Contains(pgErr.ConstraintName, "email") {
+			return fmt.Errorf("%w: %w", ErrDuplicateEmail, err)
+		}
+		if strings.Contains(pgErr.ConstraintName, "external_id") {
+			return fmt.Errorf("%w: %w", ErrDuplicateExternalID, err)
+		}
+		if strings.Contains(pgErr.ConstraintName, "team_users") {
+			return fmt.Errorf("%w: %w", ErrAlreadyMember, err)
+		}
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case pgForeignKeyViolation:
+		return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	case pgSerializationFailure, pgDeadlockDetected:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	return err
+}
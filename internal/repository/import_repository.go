@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-sample/internal/models"
+	"go-sample/internal/requestctx"
+
+	"gorm.io/gorm"
+)
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) ImportRepository {
+	return &importRepository{db: db}
+}
+
+// Create stores the import and its files, attributing it to the request's
+// actor and marking the highest user and team IDs so far.
+func (r *importRepository) Create(ctx context.Context, imp *models.Import) error {
+	imp.Actor = requestctx.Actor(ctx)
+	imp.RequestID = requestctx.RequestID(ctx)
+	db := r.db.WithContext(ctx)
+	if err := db.Unscoped().Model(&models.User{}).Select("coalesce(max(id), 0)").Scan(&imp.LastUserID).Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Model(&models.Team{}).Select("coalesce(max(id), 0)").Scan(&imp.LastTeamID).Error; err != nil {
+		return err
+	}
+	return db.Create(imp).Error
+}
+
+// Get returns the import with its files, without their data.
+func (r *importRepository) Get(id uint) (*models.Import, error) {
+	var imp models.Import
+	err := r.db.Preload("Files", func(db *gorm.DB) *gorm.DB {
+		return db.Omit("data", "error_csv").Order("file_index")
+	}).First(&imp, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+func (r *importRepository) GetFile(importID uint, fileIndex int) (*models.ImportFile, error) {
+	var file models.ImportFile
+	if err := r.db.Omit("data").Where("import_id = ? AND file_index = ?", importID, fileIndex).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Claim marks a failed import, or a running one not touched since
+// staleBefore, as running again and returns it with its files' data. Only
+// one caller can claim an import.
+func (r *importRepository) Claim(ctx context.Context, id uint, staleBefore time.Time) (*models.Import, error) {
+	var imp models.Import
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Import{}).
+			Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))",
+				id, models.ImportStatusFailed, models.ImportStatusRunning, staleBefore).
+			Updates(map[string]interface{}{"status": models.ImportStatusRunning, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.Preload("Files", func(db *gorm.DB) *gorm.DB {
+			return db.Order("file_index")
+		}).First(&imp, id).Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return ErrImportNotResumable
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &imp, nil
+}
+
+// Heartbeat shows the import is still being worked on.
+func (r *importRepository) Heartbeat(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.Import{}).Where("id = ?", id).
+		Update("updated_at", time.Now()).Error
+}
+
+// SaveProgress stores a file's checkpoint and, once it is completed, its
+// error CSV, dropping the data that is no longer needed.
+func (r *importRepository) SaveProgress(ctx context.Context, file *models.ImportFile) error {
+	updates := map[string]interface{}{
+		"checkpoint": file.Checkpoint,
+		"progress":   file.Progress,
+		"completed":  file.Completed,
+		"updated_at": time.Now(),
+	}
+	if file.Completed {
+		updates["error_csv"] = file.ErrorCSV
+		updates["data"] = nil
+	}
+	return r.db.WithContext(ctx).Model(&models.ImportFile{}).
+		Where("import_id = ? AND file_index = ?", file.ImportID, file.FileIndex).
+		Updates(updates).Error
+}
+
+func (r *importRepository) Finish(ctx context.Context, id uint, status string) error {
+	return r.db.WithContext(ctx).Model(&models.Import{}).Where("id = ?", id).
+		Update("status", status).Error
+}
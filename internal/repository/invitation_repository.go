@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-sample/internal/cache"
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+	"go-sample/internal/requestctx"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type invitationRepository struct {
+	db    *gorm.DB
+	cache *cache.Cache
+}
+
+func NewInvitationRepository(db *gorm.DB, cache *cache.Cache) InvitationRepository {
+	return &invitationRepository{
+		db:    db,
+		cache: cache,
+	}
+}
+
+// Create stores a pending invitation. The email does not need to belong to a
+// user yet; if it does, the user must not already be a member.
+func (r *invitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.First(&team, invitation.TeamID).Error; err != nil {
+			return err
+		}
+
+		user, err := findUserByEmail(tx, invitation.Email)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			member, err := isMember(tx, team.ID, user.ID)
+			if err != nil {
+				return err
+			}
+			if member {
+				return ErrAlreadyMember
+			}
+			invitation.UserID = &user.ID
+		}
+
+		// Free the address for a new invitation once the old one has expired
+		if err := tx.Model(&models.Invitation{}).
+			Where("team_id = ? AND lower(email) = lower(?) AND status = ? AND expires_at <= ?",
+				team.ID, invitation.Email, models.InvitationStatusPending, time.Now()).
+			Update("status", models.InvitationStatusExpired).Error; err != nil {
+			return err
+		}
+
+		invitation.Status = models.InvitationStatusPending
+		invitation.InvitedBy = requestctx.Actor(ctx)
+		if err := tx.Create(invitation).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionInvite, AuditEntityInvitation, invitation.ID, nil, invitation); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.InvitationCreated, invitation))
+	})
+	return translateError(err)
+}
+
+// ListPendingByTeam returns the team's unexpired pending invitations, newest
+// first.
+func (r *invitationRepository) ListPendingByTeam(teamID uint) ([]models.Invitation, error) {
+	var team models.Team
+	if err := r.db.First(&team, teamID).Error; err != nil {
+		return nil, err
+	}
+
+	invitations := []models.Invitation{}
+	err := r.db.Where("team_id = ? AND status = ? AND expires_at > ?", teamID, models.InvitationStatusPending, time.Now()).
+		Order("created_at DESC, id DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// ListPendingByEmail returns the unexpired pending invitations sent to the
+// address, newest first.
+func (r *invitationRepository) ListPendingByEmail(email string) ([]models.Invitation, error) {
+	invitations := []models.Invitation{}
+	err := r.db.Where("lower(email) = lower(?) AND status = ? AND expires_at > ?", email, models.InvitationStatusPending, time.Now()).
+		Order("created_at DESC, id DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// Accept answers the invitation with the given nonce hash and adds the
+// invited user to the team, creating the user named name if no user has the
+// invited email.
+func (r *invitationRepository) Accept(ctx context.Context, id uint, nonceHash string, name string) (*models.Invitation, *models.User, error) {
+	var invitation *models.Invitation
+	var user *models.User
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = lockPendingInvitation(tx, id, nonceHash); err != nil {
+			return err
+		}
+		before := *invitation
+
+		var team models.Team
+		if err := tx.First(&team, invitation.TeamID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: team %d", ErrInvalidReference, invitation.TeamID)
+			}
+			return err
+		}
+
+		if user, err = findUserByEmail(tx, invitation.Email); err != nil {
+			return err
+		}
+		if user == nil {
+			if name = strings.TrimSpace(name); name == "" {
+				return ErrNameRequired
+			}
+			user = &models.User{Email: invitation.Email, Name: name}
+			if err := validateAttributes(tx, AttributeEntityUsers, user.Attributes); err != nil {
+				return err
+			}
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityUser, user.ID, nil, user); err != nil {
+				return err
+			}
+			if err := writeOutbox(tx, userEvent(events.UserCreated, user, nil)); err != nil {
+				return err
+			}
+		}
+
+		if err := addMembership(ctx, tx, &team, user); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		invitation.Status = models.InvitationStatusAccepted
+		invitation.UserID = &user.ID
+		invitation.RespondedAt = &now
+		if err := tx.Save(invitation).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionAccept, AuditEntityInvitation, invitation.ID, &before, invitation); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.InvitationAccepted, invitation))
+	})
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
+
+	// Invalidate caches
+	r.cache.Delete("teams_list")
+	r.cache.Delete("users_list")
+	r.cache.Delete(fmt.Sprintf("team_%d", invitation.TeamID))
+	r.cache.Delete(fmt.Sprintf("user_%d", user.ID))
+	r.cache.Delete(fmt.Sprintf("user_teams_%d", user.ID))
+	return invitation, user, nil
+}
+
+// Decline answers the invitation with the given nonce hash without joining
+// the team.
+func (r *invitationRepository) Decline(ctx context.Context, id uint, nonceHash string) (*models.Invitation, error) {
+	var invitation *models.Invitation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = lockPendingInvitation(tx, id, nonceHash); err != nil {
+			return err
+		}
+		return closeInvitation(ctx, tx, invitation, models.InvitationStatusDeclined, AuditActionDecline, events.InvitationDeclined)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return invitation, nil
+}
+
+// Revoke withdraws a pending invitation so its token can no longer be used.
+func (r *invitationRepository) Revoke(ctx context.Context, id uint) (*models.Invitation, error) {
+	var invitation *models.Invitation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = lockInvitation(tx, id); err != nil {
+			return err
+		}
+		if invitation.Status != models.InvitationStatusPending {
+			return ErrInvitationClosed
+		}
+		return closeInvitation(ctx, tx, invitation, models.InvitationStatusRevoked, AuditActionRevoke, events.InvitationRevoked)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return invitation, nil
+}
+
+// closeInvitation moves a pending invitation to a final status without
+// creating a membership.
+func closeInvitation(ctx context.Context, tx *gorm.DB, invitation *models.Invitation, status, action, eventType string) error {
+	before := *invitation
+	now := time.Now()
+	invitation.Status = status
+	invitation.RespondedAt = &now
+	if err := tx.Save(invitation).Error; err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, action, AuditEntityInvitation, invitation.ID, &before, invitation); err != nil {
+		return err
+	}
+	return writeOutbox(tx, events.New(eventType, invitation))
+}
+
+// lockPendingInvitation locks the invitation a token refers to and checks that
+// it can still be answered. A nonce mismatch is reported as not found so a
+// guessed ID reveals nothing.
+func lockPendingInvitation(tx *gorm.DB, id uint, nonceHash string) (*models.Invitation, error) {
+	invitation, err := lockInvitation(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(invitation.NonceHash), []byte(nonceHash)) != 1 {
+		return nil, ErrNotFound
+	}
+	if invitation.Status != models.InvitationStatusPending {
+		return nil, ErrInvitationClosed
+	}
+	if invitation.Expired(time.Now()) {
+		return nil, ErrInvitationExpired
+	}
+	return invitation, nil
+}
+
+func lockInvitation(tx *gorm.DB, id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invitation, id).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// findUserByEmail returns the active user with the email, ignoring case, or
+// nil if there is none.
+func findUserByEmail(tx *gorm.DB, email string) (*models.User, error) {
+	var users []models.User
+	if err := tx.Where("lower(email) = lower(?)", email).Limit(1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return &users[0], nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxLockKey, with the hash of a sink's name, is the advisory lock held by
+// the replica currently relaying to that sink. Only one relay per sink runs at
+// a time so each sink sees events in order.
+const outboxLockKey = 727001
+
+// outboxVisible restricts events to those whose transaction, and every
+// transaction that started before it, has ended. Later events can then only
+// sort after them.
+const outboxVisible = "txid < pg_snapshot_xmin(pg_current_snapshot())::text::bigint"
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+// Relay hands up to limit events sink has not been handed yet, in the order
+// of the transactions that wrote them, to handle and moves the sink's cursor
+// past the ones it accepted. It stops at the first failure so later events
+// are never delivered ahead of an earlier one. handle runs outside any
+// transaction. Events written while a long transaction is open wait for it
+// to end. A sink relayed for the first time starts after the events already
+// visible. It returns the number of events dispatched; zero if another
+// replica holds the sink's relay lock.
+func (r *outboxRepository) Relay(ctx context.Context, sink string, limit int, handle func(event events.Event) error) (int, error) {
+	dispatched := 0
+	var handleErr error
+
+	// The session lock needs one connection throughout
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?, hashtext(?))", outboxLockKey, sink).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?, hashtext(?))", outboxLockKey, sink)
+
+		cursor, err := outboxCursor(conn, sink)
+		if err != nil {
+			return err
+		}
+
+		var pending []models.OutboxEvent
+		if err := conn.Where("(txid, id) > (?, ?)", cursor.TxID, cursor.LastID).Where(outboxVisible).
+			Order("txid, id").Limit(limit).Find(&pending).Error; err != nil {
+			return err
+		}
+
+		for _, row := range pending {
+			event, err := outboxToEvent(row)
+			if err == nil {
+				err = handle(event)
+			}
+			if err != nil {
+				handleErr = err
+				break
+			}
+			cursor.TxID, cursor.LastID = row.TxID, row.ID
+			dispatched++
+		}
+
+		if dispatched == 0 {
+			return nil
+		}
+		return conn.Model(&models.OutboxCursor{}).Where("sink = ?", sink).
+			Updates(map[string]interface{}{"txid": cursor.TxID, "last_id": cursor.LastID, "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// What was dispatched before the failure stays recorded
+	return dispatched, handleErr
+}
+
+// Purge deletes events created before the cutoff that every one of sinks has
+// been handed.
+func (r *outboxRepository) Purge(before time.Time, sinks []string) (int64, error) {
+	result := r.db.Where("created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM outbox_cursors c WHERE c.sink IN ? AND (c.txid, c.last_id) < (outbox_events.txid, outbox_events.id))", sinks).
+		Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// outboxCursor loads the cursor of sink, creating it after the last visible
+// event on its first relay.
+func outboxCursor(conn *gorm.DB, sink string) (*models.OutboxCursor, error) {
+	var cursor models.OutboxCursor
+	err := conn.Where("sink = ?", sink).Take(&cursor).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &cursor, err
+	}
+
+	var last []models.OutboxEvent
+	if err := conn.Where(outboxVisible).Order("txid DESC, id DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	cursor = models.OutboxCursor{Sink: sink}
+	if len(last) > 0 {
+		cursor.TxID, cursor.LastID = last[0].TxID, last[0].ID
+	}
+	if err := conn.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error; err != nil {
+		return nil, err
+	}
+	// Another replica may have created it first
+	return &cursor, conn.Where("sink = ?", sink).Take(&cursor).Error
+}
+
+// writeOutbox stores event in the outbox using tx, so it is committed
+// atomically with the change that produced it.
+func writeOutbox(tx *gorm.DB, event events.Event) error {
+	row, err := newOutboxRow(event)
+	if err != nil {
+		return err
+	}
+	return tx.Create(row).Error
+}
+
+func newOutboxRow(event events.Event) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxEvent{
+		EventID: event.ID,
+		Type:    event.Type,
+		Payload: models.JSON(payload),
+	}, nil
+}
+
+func outboxToEvent(row models.OutboxEvent) (events.Event, error) {
+	var envelope struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Data       json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(row.Payload, &envelope); err != nil {
+		return events.Event{}, err
+	}
+
+	return events.Event{
+		ID:         envelope.ID,
+		Type:       envelope.Type,
+		OccurredAt: envelope.OccurredAt,
+		Data:       envelope.Data,
+	}, nil
+}
@@ -1,24 +1,185 @@
 package repository
 
 import (
+	"context"
+	"sort"
+	"time"
+
+	"go-sample/internal/events"
 	"go-sample/internal/models"
+
+	"gorm.io/gorm"
 )
 
+type ListOptions struct {
+	// IncludeDeleted also returns soft-deleted rows
+	IncludeDeleted bool
+	// Attributes keeps rows whose attribute, as text, equals the value
+	Attributes map[string]string
+	// Equal keeps rows whose column equals the value, ignoring case. The keys
+	// are column names and must never come from a request
+	Equal map[string]string
+	// Offset and Limit page the rows in ID order; zero Limit returns them all
+	Offset int
+	Limit  int
+}
+
+// cached tells whether the options select the full list of live rows, the
+// only one kept in the cache.
+func (opts ListOptions) cached() bool {
+	return !opts.IncludeDeleted && len(opts.Attributes) == 0 && len(opts.Equal) == 0 &&
+		opts.Offset == 0 && opts.Limit == 0
+}
+
+// filter narrows query to the rows the options select, without paging.
+func (opts ListOptions) filter(query *gorm.DB) *gorm.DB {
+	query = filterAttributes(query, opts.Attributes)
+	columns := make([]string, 0, len(opts.Equal))
+	for column := range opts.Equal {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		query = query.Where("lower("+column+") = lower(?)", opts.Equal[column])
+	}
+	if opts.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	return query
+}
+
+// page narrows query to the requested page.
+func (opts ListOptions) page(query *gorm.DB) *gorm.DB {
+	if opts.Offset == 0 && opts.Limit == 0 {
+		return query
+	}
+	query = query.Order("id").Offset(opts.Offset)
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	return query
+}
+
+// Mutations of users and teams check the entity's Version (or the version
+// argument) against the stored one and fail with ErrVersionConflict on a
+// mismatch. Zero skips the check. Creates and updates fail with an
+// *AttributeError when attributes violate the registered schema.
 type UserRepository interface {
-	Create(user *models.User) error
-	Update(user *models.User) error
-	UpdateWithTeams(user *models.User, teamIDs []uint) error
-	Delete(id uint) error
+	Create(ctx context.Context, user *models.User) error
+	// CreateBatch creates all users or, on any failure, none
+	CreateBatch(ctx context.Context, users []*models.User) error
+	Update(ctx context.Context, user *models.User) error
+	UpdateWithTeams(ctx context.Context, user *models.User, teamIDs []uint) error
+	Delete(ctx context.Context, id uint, version uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, before time.Time) (int, error)
 	GetByID(id uint) (*models.User, error)
-	List() ([]models.User, error)
+	GetByExternalID(source, externalID string) (*models.User, error)
+	FindByExternalIDs(source string, externalIDs []string) ([]models.User, error)
+	FindByEmails(emails []string) ([]models.User, error)
+	List(opts ListOptions) ([]models.User, error)
+	// Count returns the number of users List selects, ignoring paging
+	Count(opts ListOptions) (int64, error)
 	GetWithTeams(id uint) (*models.User, error)
+	// GetIncludingDeleted returns the user with its teams even if it is
+	// soft-deleted
+	GetIncludingDeleted(id uint) (*models.User, error)
 }
 
 type TeamRepository interface {
-	Create(team *models.Team) error
-	Update(team *models.Team) error
-	Delete(id uint) error
+	Create(ctx context.Context, team *models.Team) error
+	// CreateBatch creates all teams or, on any failure, none
+	CreateBatch(ctx context.Context, teams []*models.Team) error
+	Update(ctx context.Context, team *models.Team) error
+	Delete(ctx context.Context, id uint, version uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, before time.Time) (int, error)
 	GetByID(id uint) (*models.Team, error)
-	List() ([]models.Team, error)
-	AddUser(teamID, userID uint) error
+	GetByExternalID(source, externalID string) (*models.Team, error)
+	FindByExternalIDs(source string, externalIDs []string) ([]models.Team, error)
+	List(opts ListOptions) ([]models.Team, error)
+	// Count returns the number of teams List selects, ignoring paging
+	Count(opts ListOptions) (int64, error)
+	AddUser(ctx context.Context, teamID, userID uint) error
+	// RemoveUser fails with ErrNotFound when the user is not a member
+	RemoveUser(ctx context.Context, teamID, userID uint) error
+	// SetMembers makes userIDs the team's exact membership
+	SetMembers(ctx context.Context, teamID uint, userIDs []uint) error
+	FindByTitle(title string) ([]models.Team, error)
+	FindByTitles(titles []string) ([]models.Team, error)
+	// Subtree returns the team and all of its descendants
+	Subtree(id uint) ([]models.Team, error)
+	// Ancestors returns the team's parent, its parent's parent and so on
+	Ancestors(id uint) ([]models.Team, error)
+	// EffectiveMembers returns the members of the team and its descendants
+	EffectiveMembers(id uint) ([]models.User, error)
+}
+
+// BatchRepository runs several repository calls as one unit.
+type BatchRepository interface {
+	// Atomic calls fn with user and team repositories bound to a single
+	// transaction, which commits only if fn returns nil. Each call still
+	// runs in its own savepoint, so fn may recover from a failed call.
+	Atomic(ctx context.Context, fn func(users UserRepository, teams TeamRepository) error) error
+}
+
+type AttributeSchemaRepository interface {
+	Get(entityType string) (*models.AttributeSchema, error)
+	Put(ctx context.Context, schema *models.AttributeSchema) error
+	Delete(ctx context.Context, entityType string) error
+}
+
+// InvitationRepository answers invitations by ID and the hash of the nonce
+// carried in their token. Answering a missing or mismatched invitation fails
+// with ErrNotFound, an answered one with ErrInvitationClosed and an expired
+// one with ErrInvitationExpired.
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) error
+	ListPendingByTeam(teamID uint) ([]models.Invitation, error)
+	ListPendingByEmail(email string) ([]models.Invitation, error)
+	Accept(ctx context.Context, id uint, nonceHash string, name string) (*models.Invitation, *models.User, error)
+	Decline(ctx context.Context, id uint, nonceHash string) (*models.Invitation, error)
+	Revoke(ctx context.Context, id uint) (*models.Invitation, error)
+}
+
+type SearchRepository interface {
+	Search(opts SearchOptions) (*SearchPage, error)
+}
+
+// ImportRepository keeps importer runs with per-file checkpoints, so that an
+// interrupted run can be resumed and rejected rows downloaded after the
+// response was sent. Resuming an import that completed or is still running
+// fails with ErrImportNotResumable.
+type ImportRepository interface {
+	Create(ctx context.Context, imp *models.Import) error
+	Get(id uint) (*models.Import, error)
+	GetFile(importID uint, fileIndex int) (*models.ImportFile, error)
+	Claim(ctx context.Context, id uint, staleBefore time.Time) (*models.Import, error)
+	Heartbeat(ctx context.Context, id uint) error
+	SaveProgress(ctx context.Context, file *models.ImportFile) error
+	Finish(ctx context.Context, id uint, status string) error
+}
+
+type AuditRepository interface {
+	Record(ctx context.Context, action, entityType string, entityID uint, before, after interface{}) error
+	List(filter AuditFilter) ([]models.AuditLog, error)
+}
+
+type WebhookRepository interface {
+	CreateSubscription(sub *models.WebhookSubscription) error
+	UpdateSubscription(sub *models.WebhookSubscription) error
+	DeleteSubscription(id uint) error
+	GetSubscription(id uint) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]models.WebhookSubscription, error)
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	GetDelivery(id uint) (*models.WebhookDelivery, error)
+	ListDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error)
+	DeliveredSubscriptionIDs(eventID string) ([]uint, error)
+	ClaimDueDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+}
+
+type OutboxRepository interface {
+	Relay(ctx context.Context, sink string, limit int, handle func(event events.Event) error) (int, error)
+	Purge(before time.Time, sinks []string) (int64, error)
 }
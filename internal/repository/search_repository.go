@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchTypeUser = "user"
+	SearchTypeTeam = "team"
+)
+
+type SearchOptions struct {
+	Query string
+	// Types restricts results to SearchTypeUser and/or SearchTypeTeam; empty
+	// means both
+	Types  []string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a matching user or team. For users Title is the name and
+// Subtitle the email; for teams they are the title and description.
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       uint    `json:"id"`
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle"`
+	Rank     float64 `json:"rank"`
+}
+
+type SearchPage struct {
+	Results []SearchResult
+	Total   int64
+}
+
+// Each branch matches full-text terms, substrings (served by the trigram
+// indexes) and fuzzy trigram similarity, and ranks full-text hits above
+// similar spellings
+const (
+	searchUsersSQL = `
+SELECT 'user' AS type, id, name AS title, email AS subtitle,
+	ts_rank(search_vector, websearch_to_tsquery('simple', @query))
+		+ greatest(similarity(name, @query), similarity(email, @query)) AS rank
+FROM users
+WHERE deleted_at IS NULL AND (
+	search_vector @@ websearch_to_tsquery('simple', @query)
+	OR name ILIKE @pattern OR email ILIKE @pattern
+	OR name % @query OR email % @query
+)`
+	searchTeamsSQL = `
+SELECT 'team' AS type, id, title, description AS subtitle,
+	ts_rank(search_vector, websearch_to_tsquery('simple', @query))
+		+ greatest(similarity(title, @query), similarity(description, @query)) AS rank
+FROM teams
+WHERE deleted_at IS NULL AND (
+	search_vector @@ websearch_to_tsquery('simple', @query)
+	OR title ILIKE @pattern OR description ILIKE @pattern
+	OR title % @query
+)`
+)
+
+type searchRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepository{
+		db: db,
+	}
+}
+
+// Search returns one page of users and teams matching the query, best match
+// first, together with the total number of matches.
+func (r *searchRepository) Search(opts SearchOptions) (*SearchPage, error) {
+	var branches []string
+	if includesType(opts.Types, SearchTypeUser) {
+		branches = append(branches, searchUsersSQL)
+	}
+	if includesType(opts.Types, SearchTypeTeam) {
+		branches = append(branches, searchTeamsSQL)
+	}
+
+	matches := strings.Join(branches, "\nUNION ALL\n")
+	args := map[string]interface{}{
+		"query":   opts.Query,
+		"pattern": "%" + escapeLike(opts.Query) + "%",
+		"limit":   opts.Limit,
+		"offset":  opts.Offset,
+	}
+
+	var rows []struct {
+		SearchResult
+		Total int64
+	}
+	if err := r.db.Raw(`SELECT *, count(*) OVER () AS total FROM (`+matches+`) matches
+ORDER BY rank DESC, type, id
+LIMIT @limit OFFSET @offset`, args).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Results: make([]SearchResult, len(rows))}
+	for i, row := range rows {
+		page.Results[i] = row.SearchResult
+		page.Total = row.Total
+	}
+	// Past the last page there is no row to carry the window count
+	if len(rows) == 0 && opts.Offset > 0 {
+		if err := r.db.Raw(`SELECT count(*) FROM (`+matches+`) matches`, args).Scan(&page.Total).Error; err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func includesType(types []string, searchType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == searchType {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize bounds how many rows one Purge call hard-deletes.
+const purgeBatchSize = 500
+
+// softDeleteMemberships soft-deletes the live team_users rows where column
+// equals id, stamping them with deletedAt so a restore can find exactly the
+// rows removed by the cascade. It returns the IDs on the other side of the
+// affected memberships (team IDs for a user, user IDs for a team).
+func softDeleteMemberships(tx *gorm.DB, column, otherColumn string, id uint, deletedAt time.Time) ([]uint, error) {
+	otherIDs, err := bumpMembers(tx, column, otherColumn, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.TeamUser{}).
+		Where(fmt.Sprintf("%s = ?", column), id).
+		Update("deleted_at", deletedAt).Error; err != nil {
+		return nil, err
+	}
+	return otherIDs, nil
+}
+
+// restoreMemberships undoes softDeleteMemberships for rows deleted at
+// deletedAt, skipping memberships whose other side is itself deleted.
+func restoreMemberships(tx *gorm.DB, column, otherColumn, otherTable string, id uint, deletedAt time.Time) ([]uint, error) {
+	cascaded := func() *gorm.DB {
+		return tx.Unscoped().Model(&models.TeamUser{}).
+			Where(fmt.Sprintf("%s = ? AND deleted_at = ?", column), id, deletedAt).
+			Where(fmt.Sprintf("%s IN (SELECT id FROM %s WHERE deleted_at IS NULL)", otherColumn, otherTable))
+	}
+
+	var otherIDs []uint
+	if err := cascaded().Pluck(otherColumn, &otherIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if err := cascaded().Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := bumpVersions(tx, otherModel(otherColumn), otherIDs); err != nil {
+		return nil, err
+	}
+	return otherIDs, nil
+}
+
+// purgeMemberships hard-deletes every team_users row, live or deleted, where
+// column is one of ids.
+func purgeMemberships(tx *gorm.DB, column string, ids []uint) error {
+	return tx.Unscoped().Where(fmt.Sprintf("%s IN ?", column), ids).Delete(&models.TeamUser{}).Error
+}
+
+func otherModel(column string) interface{} {
+	if column == "team_id" {
+		return &models.Team{}
+	}
+	return &models.User{}
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// hierarchyLockKey serializes parent changes so two concurrent moves cannot
+// combine into a cycle
+const hierarchyLockKey = 727003
+
+// maxHierarchyDepth bounds the recursive queries
+const maxHierarchyDepth = 100
+
+// subtreeSQL selects the IDs of a live team and its live descendants
+const subtreeSQL = `WITH RECURSIVE subtree AS (
+	SELECT id, 0 AS depth FROM teams WHERE id = @id AND deleted_at IS NULL
+	UNION ALL
+	SELECT t.id, s.depth + 1 FROM teams t JOIN subtree s ON t.parent_id = s.id
+	WHERE t.deleted_at IS NULL AND s.depth < @max_depth
+) SELECT id FROM subtree`
+
+// checkParents verifies that the parents of new teams are live teams. New
+// teams cannot be ancestors, so there is no cycle to look for.
+func checkParents(tx *gorm.DB, teams []*models.Team) error {
+	var parentIDs []uint
+	for _, team := range teams {
+		if team.ParentID != nil {
+			parentIDs = append(parentIDs, *team.ParentID)
+		}
+	}
+	parentIDs = uniqueIDs(parentIDs)
+	if len(parentIDs) == 0 {
+		return nil
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", hierarchyLockKey).Error; err != nil {
+		return err
+	}
+
+	var found []uint
+	if err := tx.Model(&models.Team{}).Where("id IN ?", parentIDs).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	if len(found) != len(parentIDs) {
+		return fmt.Errorf("%w: parent teams %v", ErrInvalidReference, symmetricDifference(parentIDs, found))
+	}
+	return nil
+}
+
+// checkParent verifies that team.ParentID names a live team that does not
+// have team among its ancestors. It must run inside the transaction that
+// writes the team.
+func checkParent(tx *gorm.DB, team *models.Team) error {
+	if team.ParentID == nil {
+		return nil
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", hierarchyLockKey).Error; err != nil {
+		return err
+	}
+
+	var parent models.Team
+	if err := tx.First(&parent, *team.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: parent team %d", ErrInvalidReference, *team.ParentID)
+		}
+		return err
+	}
+	if team.ID == 0 {
+		return nil
+	}
+
+	// Soft-deleted ancestors count too, as restoring them would close the cycle
+	var ancestorIDs []uint
+	if err := tx.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id, 0 AS depth FROM teams WHERE id = ?
+		UNION ALL
+		SELECT t.id, t.parent_id, a.depth + 1 FROM teams t JOIN ancestors a ON t.id = a.parent_id
+		WHERE a.depth < ?
+	) SELECT id FROM ancestors`, parent.ID, maxHierarchyDepth).Scan(&ancestorIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range ancestorIDs {
+		if id == team.ID {
+			return ErrHierarchyCycle
+		}
+	}
+	return nil
+}
+
+func (r *teamRepository) FindByTitle(title string) ([]models.Team, error) {
+	var teams []models.Team
+	if err := r.db.Where("title = ?", title).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (r *teamRepository) FindByTitles(titles []string) ([]models.Team, error) {
+	teams := []models.Team{}
+	if len(titles) == 0 {
+		return teams, nil
+	}
+	if err := r.db.Where("title IN ?", titles).Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (r *teamRepository) Subtree(id uint) ([]models.Team, error) {
+	var teams []models.Team
+	if err := r.db.Where("id IN ("+subtreeSQL+")", subtreeArgs(id)).
+		Order("id").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrNotFound
+	}
+	return teams, nil
+}
+
+func (r *teamRepository) Ancestors(id uint) ([]models.Team, error) {
+	var team models.Team
+	if err := r.db.First(&team, id).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	if err := r.db.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT parent_id AS id, 1 AS depth FROM teams WHERE id = ? AND parent_id IS NOT NULL
+		UNION ALL
+		SELECT t.parent_id, a.depth + 1 FROM teams t JOIN ancestors a ON t.id = a.id
+		WHERE t.parent_id IS NOT NULL AND t.deleted_at IS NULL AND a.depth < ?
+	) SELECT id FROM ancestors ORDER BY depth`, id, maxHierarchyDepth).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	var found []models.Team
+	if err := r.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	// Keep nearest-first order and stop at the first deleted ancestor
+	byID := make(map[uint]models.Team, len(found))
+	for _, t := range found {
+		byID[t.ID] = t
+	}
+	ancestors := make([]models.Team, 0, len(ids))
+	for _, ancestorID := range ids {
+		t, ok := byID[ancestorID]
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, t)
+	}
+	return ancestors, nil
+}
+
+func (r *teamRepository) EffectiveMembers(id uint) ([]models.User, error) {
+	var team models.Team
+	if err := r.db.First(&team, id).Error; err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	if err := r.db.Where(`id IN (SELECT tu.user_id FROM team_users tu
+		WHERE tu.deleted_at IS NULL AND tu.team_id IN (`+subtreeSQL+`))`, subtreeArgs(id)).
+		Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func subtreeArgs(id uint) map[string]interface{} {
+	return map[string]interface{}{"id": id, "max_depth": maxHierarchyDepth}
+}
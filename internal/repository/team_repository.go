@@ -1,13 +1,17 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go-sample/internal/cache"
+	"go-sample/internal/events"
 	"go-sample/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type teamRepository struct {
@@ -22,35 +26,226 @@ func NewTeamRepository(db *gorm.DB, cache *cache.Cache) TeamRepository {
 	}
 }
 
-func (r *teamRepository) Create(team *models.Team) error {
-	if err := r.db.Create(team).Error; err != nil {
-		return err
+func (r *teamRepository) Create(ctx context.Context, team *models.Team) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkParent(tx, team); err != nil {
+			return err
+		}
+		if err := validateAttributes(tx, AttributeEntityTeams, team.Attributes); err != nil {
+			return err
+		}
+		if err := tx.Create(team).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityTeam, team.ID, nil, team); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.TeamCreated, team))
+	})
+	if err != nil {
+		return translateError(err)
 	}
 	// Invalidate cache
 	r.cache.Delete("teams_list")
 	return nil
 }
 
-func (r *teamRepository) Update(team *models.Team) error {
-	if err := r.db.Save(team).Error; err != nil {
-		return err
+// CreateBatch inserts the teams with multi-row INSERTs in one transaction.
+// Parents must already exist. Any invalid or conflicting team fails, and
+// rolls back, the whole batch.
+func (r *teamRepository) CreateBatch(ctx context.Context, teams []*models.Team) error {
+	if len(teams) == 0 {
+		return nil
 	}
-	// Invalidate caches
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkParents(tx, teams); err != nil {
+			return err
+		}
+		schema, err := loadAttributeSchema(tx, AttributeEntityTeams)
+		if err != nil {
+			return err
+		}
+		for _, team := range teams {
+			if err := checkAttributes(schema, team.Attributes); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateInBatches(teams, insertBatchSize).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(teams))
+		records := make([]interface{}, len(teams))
+		for i, team := range teams {
+			ids[i], records[i] = team.ID, team
+		}
+		return recordCreations(ctx, tx, AuditEntityTeam, events.TeamCreated, ids, records)
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	// Invalidate cache once for the whole batch
 	r.cache.Delete("teams_list")
-	r.cache.Delete(fmt.Sprintf("team_%d", team.ID))
 	return nil
 }
 
-func (r *teamRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.Team{}, id).Error; err != nil {
-		return err
+func (r *teamRepository) Update(ctx context.Context, team *models.Team) error {
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockTeam(tx, team.ID, team.Version)
+		if err != nil {
+			return err
+		}
+		if !sameParent(before.ParentID, team.ParentID) {
+			if err := checkParent(tx, team); err != nil {
+				return err
+			}
+		}
+		if err := validateAttributes(tx, AttributeEntityTeams, team.Attributes); err != nil {
+			return err
+		}
+		team.Version = before.Version + 1
+		if err := tx.Save(team).Error; err != nil {
+			return err
+		}
+		// Users list their teams' titles
+		if before.Title != team.Title {
+			if memberIDs, err = bumpMembers(tx, "team_id", "user_id", team.ID); err != nil {
+				return err
+			}
+		}
+		if err := recordAudit(ctx, tx, AuditActionUpdate, AuditEntityTeam, team.ID, before, team); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.TeamUpdated, team))
+	})
+	if err != nil {
+		return translateError(err)
 	}
 	// Invalidate caches
-	r.cache.Delete("teams_list")
-	r.cache.Delete(fmt.Sprintf("team_%d", id))
+	r.invalidateTeam(team.ID, memberIDs)
 	return nil
 }
 
+// Delete soft-deletes the team and, with the same timestamp, its memberships.
+// A non-zero version must match the stored one.
+func (r *teamRepository) Delete(ctx context.Context, id uint, version uint) error {
+	deleted := false
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := lockTeam(tx, id, version)
+		if err != nil {
+			// Deleting a missing team is a no-op
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		deletedAt := time.Now()
+		if err := tx.Model(&models.Team{}).Where("id = ?", id).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		if memberIDs, err = softDeleteMemberships(tx, "team_id", "user_id", id, deletedAt); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, AuditActionDelete, AuditEntityTeam, id, existing, nil); err != nil {
+			return err
+		}
+		deleted = true
+		return writeOutbox(tx, events.New(events.TeamDeleted, existing))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	if !deleted {
+		return nil
+	}
+	// Invalidate caches
+	r.invalidateTeam(id, memberIDs)
+	return nil
+}
+
+// Restore undeletes a soft-deleted team together with the memberships that
+// were removed by its deletion.
+func (r *teamRepository) Restore(ctx context.Context, id uint) error {
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Team
+		if err := tx.Unscoped().First(&existing, id).Error; err != nil {
+			return err
+		}
+		if !existing.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+
+		if err := tx.Unscoped().Model(&models.Team{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": existing.Version + 1}).Error; err != nil {
+			return err
+		}
+		var err error
+		if memberIDs, err = restoreMemberships(tx, "team_id", "user_id", "users", id, existing.DeletedAt.Time); err != nil {
+			return err
+		}
+
+		restored := existing
+		restored.DeletedAt = gorm.DeletedAt{}
+		restored.Version++
+		if err := recordAudit(ctx, tx, AuditActionRestore, AuditEntityTeam, id, &existing, &restored); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.TeamRestored, &restored))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	// Invalidate caches
+	r.invalidateTeam(id, memberIDs)
+	return nil
+}
+
+// Purge hard-deletes up to purgeBatchSize teams soft-deleted before the
+// cutoff, along with all of their memberships.
+func (r *teamRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	var purged []models.Team
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Order("id").
+			Limit(purgeBatchSize).
+			Find(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(purged))
+		for i, team := range purged {
+			ids[i] = team.ID
+		}
+
+		if err := purgeMemberships(tx, "team_id", ids); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.Team{}, ids).Error; err != nil {
+			return err
+		}
+
+		for i := range purged {
+			if err := recordAudit(ctx, tx, AuditActionPurge, AuditEntityTeam, purged[i].ID, &purged[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return len(purged), nil
+}
+
 func (r *teamRepository) GetByID(id uint) (*models.Team, error) {
 	var team models.Team
 	cacheKey := fmt.Sprintf("team_%d", id)
@@ -71,10 +266,39 @@ func (r *teamRepository) GetByID(id uint) (*models.Team, error) {
 	return &team, nil
 }
 
-func (r *teamRepository) List() ([]models.Team, error) {
+// GetByExternalID finds the live team an upstream system knows by externalID.
+func (r *teamRepository) GetByExternalID(source, externalID string) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.Preload("Users").Where("source = ? AND external_id = ?", source, externalID).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// FindByExternalIDs returns the teams of the source with any of the external
+// IDs, without their members.
+func (r *teamRepository) FindByExternalIDs(source string, externalIDs []string) ([]models.Team, error) {
+	teams := []models.Team{}
+	if len(externalIDs) == 0 {
+		return teams, nil
+	}
+	err := r.db.Where("source = ? AND external_id IN ?", source, externalIDs).Find(&teams).Error
+	return teams, err
+}
+
+func (r *teamRepository) List(opts ListOptions) ([]models.Team, error) {
 	var teams []models.Team
 	cacheKey := "teams_list"
 
+	// Deleted, filtered and paged rows are never cached
+	if !opts.cached() {
+		query := opts.page(opts.filter(r.db))
+		if err := query.Preload("Users").Find(&teams).Error; err != nil {
+			return nil, err
+		}
+		return teams, nil
+	}
+
 	// Try to get from cache
 	err := r.cache.Get(cacheKey, &teams)
 	if err == nil {
@@ -91,29 +315,245 @@ func (r *teamRepository) List() ([]models.Team, error) {
 	return teams, nil
 }
 
-func (r *teamRepository) AddUser(teamID, userID uint) error {
-	// First check if team exists
-	var team models.Team
-	if err := r.db.First(&team, teamID).Error; err != nil {
-		return fmt.Errorf("team not found: %w", err)
+func (r *teamRepository) Count(opts ListOptions) (int64, error) {
+	var count int64
+	if err := opts.filter(r.db.Model(&models.Team{})).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *teamRepository) AddUser(ctx context.Context, teamID, userID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// First check if team exists
+		var team models.Team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return fmt.Errorf("team not found: %w", err)
+		}
+
+		// Check if user exists
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: user %d", ErrInvalidReference, userID)
+			}
+			return err
+		}
+
+		return addMembership(ctx, tx, &team, &user)
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Invalidate caches
+	r.invalidateTeam(teamID, []uint{userID})
+
+	return nil
+}
+
+// RemoveUser soft-deletes the user's membership of the team.
+func (r *teamRepository) RemoveUser(ctx context.Context, teamID, userID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return fmt.Errorf("team not found: %w", err)
+		}
+
+		result := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamUser{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: user %d is not a member of team %d", ErrNotFound, userID, teamID)
+		}
+
+		// Both sides now have a new representation
+		if err := bumpVersions(tx, &models.Team{}, []uint{teamID}); err != nil {
+			return err
+		}
+		if err := bumpVersions(tx, &models.User{}, []uint{userID}); err != nil {
+			return err
+		}
+
+		membership := map[string]uint{"team_id": teamID, "user_id": userID}
+		if err := recordAudit(ctx, tx, AuditActionRemoveMember, AuditEntityTeam, teamID, membership, nil); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.MembershipRemoved, membership))
+	})
+	if err != nil {
+		return translateError(err)
 	}
 
-	// Check if user exists
-	var user models.User
-	if err := r.db.First(&user, userID).Error; err != nil {
-		return fmt.Errorf("user not found: %w", err)
+	// Invalidate caches
+	r.invalidateTeam(teamID, []uint{userID})
+	return nil
+}
+
+// SetMembers adds and removes memberships so the team's members are exactly
+// userIDs. Removed memberships are soft-deleted.
+func (r *teamRepository) SetMembers(ctx context.Context, teamID uint, userIDs []uint) error {
+	var changedUserIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		team, err := lockTeam(tx, teamID, 0)
+		if err != nil {
+			return err
+		}
+
+		var beforeUserIDs []uint
+		if err := tx.Model(&models.TeamUser{}).Where("team_id = ?", teamID).Pluck("user_id", &beforeUserIDs).Error; err != nil {
+			return err
+		}
+
+		afterUserIDs := uniqueIDs(userIDs)
+		if len(afterUserIDs) > 0 {
+			var found []uint
+			if err := tx.Model(&models.User{}).Where("id IN ?", afterUserIDs).Pluck("id", &found).Error; err != nil {
+				return err
+			}
+			if len(found) != len(afterUserIDs) {
+				return fmt.Errorf("%w: users %v", ErrInvalidReference, symmetricDifference(afterUserIDs, found))
+			}
+		}
+
+		changedUserIDs = symmetricDifference(beforeUserIDs, afterUserIDs)
+		if len(changedUserIDs) == 0 {
+			return nil
+		}
+
+		isMember := make(map[uint]bool, len(beforeUserIDs))
+		for _, id := range beforeUserIDs {
+			isMember[id] = true
+		}
+		for _, id := range changedUserIDs {
+			if isMember[id] {
+				if err := tx.Where("team_id = ? AND user_id = ?", teamID, id).Delete(&models.TeamUser{}).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Create(&models.TeamUser{TeamID: teamID, UserID: id}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := bumpVersions(tx, &models.Team{}, []uint{teamID}); err != nil {
+			return err
+		}
+		if err := bumpVersions(tx, &models.User{}, changedUserIDs); err != nil {
+			return err
+		}
+
+		after := *team
+		after.Version++
+		if err := recordAudit(ctx, tx, AuditActionUpdate, AuditEntityTeam, teamID,
+			teamWithUserIDs{Team: team, UserIDs: beforeUserIDs},
+			teamWithUserIDs{Team: &after, UserIDs: afterUserIDs}); err != nil {
+			return err
+		}
+		return writeOutbox(tx, events.New(events.TeamUpdated, teamWithUserIDs{Team: &after, UserIDs: afterUserIDs}))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Invalidate caches
+	if len(changedUserIDs) > 0 {
+		r.invalidateTeam(teamID, changedUserIDs)
+	}
+	return nil
+}
+
+// teamWithUserIDs is the audit snapshot of a team together with its members.
+type teamWithUserIDs struct {
+	*models.Team
+	UserIDs []uint `json:"user_ids"`
+}
+
+// uniqueIDs returns ids without duplicates, in first-seen order.
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// addMembership adds the user to the team, bumps both versions and records the
+// change in the audit log and outbox. It fails with ErrAlreadyMember if the
+// user is a member already.
+func addMembership(ctx context.Context, tx *gorm.DB, team *models.Team, user *models.User) error {
+	member, err := isMember(tx, team.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if member {
+		return ErrAlreadyMember
 	}
 
 	// Add the association
-	if err := r.db.Model(&team).Association("Users").Append(&user); err != nil {
+	if err := tx.Model(team).Association("Users").Append(user); err != nil {
 		return fmt.Errorf("failed to add user to team: %w", err)
 	}
 
-	// Invalidate caches
-	r.cache.Delete(fmt.Sprintf("team_%d", teamID))
-	r.cache.Delete(fmt.Sprintf("user_%d", userID))
+	// Both sides now have a new representation
+	if err := bumpVersions(tx, &models.Team{}, []uint{team.ID}); err != nil {
+		return err
+	}
+	if err := bumpVersions(tx, &models.User{}, []uint{user.ID}); err != nil {
+		return err
+	}
+
+	membership := map[string]uint{"team_id": team.ID, "user_id": user.ID}
+	if err := recordAudit(ctx, tx, AuditActionAddMember, AuditEntityTeam, team.ID, nil, membership); err != nil {
+		return err
+	}
+	return writeOutbox(tx, events.New(events.MembershipAdded, membership))
+}
+
+// isMember tells whether the user has a live membership of the team.
+func isMember(tx *gorm.DB, teamID, userID uint) (bool, error) {
+	var members int64
+	if err := tx.Model(&models.TeamUser{}).
+		Where("team_id = ? AND user_id = ?", teamID, userID).
+		Count(&members).Error; err != nil {
+		return false, err
+	}
+	return members > 0, nil
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// invalidateTeam drops cached entries for a team and its members.
+func (r *teamRepository) invalidateTeam(id uint, userIDs []uint) {
 	r.cache.Delete("teams_list")
-	r.cache.Delete("users_list")
+	r.cache.Delete(fmt.Sprintf("team_%d", id))
+	if len(userIDs) > 0 {
+		r.cache.Delete("users_list")
+	}
+	for _, userID := range userIDs {
+		r.cache.Delete(fmt.Sprintf("user_%d", userID))
+		r.cache.Delete(fmt.Sprintf("user_teams_%d", userID))
+	}
+}
 
-	return nil
+// lockTeam loads a team with a row lock for the rest of the transaction. A
+// non-zero expectedVersion must match the stored version.
+func lockTeam(tx *gorm.DB, id uint, expectedVersion uint) (*models.Team, error) {
+	var team models.Team
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, id).Error; err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && expectedVersion != team.Version {
+		return nil, ErrVersionConflict
+	}
+	return &team, nil
 }
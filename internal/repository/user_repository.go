@@ -1,13 +1,18 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"go-sample/internal/cache"
+	"go-sample/internal/events"
 	"go-sample/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
@@ -22,35 +27,224 @@ func NewUserRepository(db *gorm.DB, cache *cache.Cache) UserRepository {
 	}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	if err := r.db.Create(user).Error; err != nil {
-		return err
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := validateAttributes(tx, AttributeEntityUsers, user.Attributes); err != nil {
+			return err
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, AuditActionCreate, AuditEntityUser, user.ID, nil, user); err != nil {
+			return err
+		}
+		return writeOutbox(tx, userEvent(events.UserCreated, user, nil))
+	})
+	if err != nil {
+		return translateError(err)
 	}
 	// Invalidate cache
 	r.cache.Delete("users_list")
 	return nil
 }
 
-func (r *userRepository) Update(user *models.User) error {
-	if err := r.db.Save(user).Error; err != nil {
-		return err
+// CreateBatch inserts the users with multi-row INSERTs in one transaction.
+// Any invalid or conflicting user fails, and rolls back, the whole batch.
+func (r *userRepository) CreateBatch(ctx context.Context, users []*models.User) error {
+	if len(users) == 0 {
+		return nil
 	}
-	// Invalidate caches
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		schema, err := loadAttributeSchema(tx, AttributeEntityUsers)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := checkAttributes(schema, user.Attributes); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateInBatches(users, insertBatchSize).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(users))
+		records := make([]interface{}, len(users))
+		for i, user := range users {
+			ids[i], records[i] = user.ID, userWithTeamIDs{User: user, TeamIDs: []uint{}}
+		}
+		return recordCreations(ctx, tx, AuditEntityUser, events.UserCreated, ids, records)
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	// Invalidate cache once for the whole batch
 	r.cache.Delete("users_list")
-	r.cache.Delete(fmt.Sprintf("user_%d", user.ID))
 	return nil
 }
 
-func (r *userRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.User{}, id).Error; err != nil {
-		return err
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	var teamIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockUser(tx, user.ID, user.Version)
+		if err != nil {
+			return err
+		}
+		if err := validateAttributes(tx, AttributeEntityUsers, user.Attributes); err != nil {
+			return err
+		}
+		user.Version = before.Version + 1
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if teamIDs, err = memberIDs(tx, "user_id", "team_id", user.ID); err != nil {
+			return err
+		}
+		// Teams list their members' emails and names
+		renamed := renamedUser(before, user)
+		if renamed {
+			if err := bumpVersions(tx, &models.Team{}, teamIDs); err != nil {
+				return err
+			}
+		}
+		if err := recordAudit(ctx, tx, AuditActionUpdate, AuditEntityUser, user.ID, before, user); err != nil {
+			return err
+		}
+		if err := writeOutbox(tx, userEvent(events.UserUpdated, user, teamIDs)); err != nil {
+			return err
+		}
+		if !renamed {
+			teamIDs = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return translateError(err)
 	}
 	// Invalidate caches
-	r.cache.Delete("users_list")
-	r.cache.Delete(fmt.Sprintf("user_%d", id))
+	r.invalidateUser(user.ID, teamIDs)
+	return nil
+}
+
+// Delete soft-deletes the user and, with the same timestamp, its memberships.
+// A non-zero version must match the stored one.
+func (r *userRepository) Delete(ctx context.Context, id uint, version uint) error {
+	deleted := false
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := lockUser(tx, id, version)
+		if err != nil {
+			// Deleting a missing user is a no-op
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		deletedAt := time.Now()
+		if err := tx.Model(&models.User{}).Where("id = ?", id).Update("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		if memberIDs, err = softDeleteMemberships(tx, "user_id", "team_id", id, deletedAt); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, AuditActionDelete, AuditEntityUser, id, existing, nil); err != nil {
+			return err
+		}
+		deleted = true
+		return writeOutbox(tx, userEvent(events.UserDeleted, existing, memberIDs))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	if !deleted {
+		return nil
+	}
+	// Invalidate caches
+	r.invalidateUser(id, memberIDs)
 	return nil
 }
 
+// Restore undeletes a soft-deleted user together with the memberships that
+// were removed by its deletion.
+func (r *userRepository) Restore(ctx context.Context, id uint) error {
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.User
+		if err := tx.Unscoped().First(&existing, id).Error; err != nil {
+			return err
+		}
+		if !existing.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": existing.Version + 1}).Error; err != nil {
+			return err
+		}
+		var err error
+		if memberIDs, err = restoreMemberships(tx, "user_id", "team_id", "teams", id, existing.DeletedAt.Time); err != nil {
+			return err
+		}
+
+		restored := existing
+		restored.DeletedAt = gorm.DeletedAt{}
+		restored.Version++
+		if err := recordAudit(ctx, tx, AuditActionRestore, AuditEntityUser, id, &existing, &restored); err != nil {
+			return err
+		}
+		return writeOutbox(tx, userEvent(events.UserRestored, &restored, memberIDs))
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	// Invalidate caches
+	r.invalidateUser(id, memberIDs)
+	return nil
+}
+
+// Purge hard-deletes up to purgeBatchSize users soft-deleted before the
+// cutoff, along with all of their memberships.
+func (r *userRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	var purged []models.User
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Order("id").
+			Limit(purgeBatchSize).
+			Find(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(purged))
+		for i, user := range purged {
+			ids[i] = user.ID
+		}
+
+		if err := purgeMemberships(tx, "user_id", ids); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.User{}, ids).Error; err != nil {
+			return err
+		}
+
+		for i := range purged {
+			if err := recordAudit(ctx, tx, AuditActionPurge, AuditEntityUser, purged[i].ID, &purged[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return len(purged), nil
+}
+
 func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
 	cacheKey := fmt.Sprintf("user_%d", id)
@@ -71,6 +265,41 @@ func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// GetByExternalID finds the live user an upstream system knows by externalID.
+func (r *userRepository) GetByExternalID(source, externalID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Preload("Teams").Where("source = ? AND external_id = ?", source, externalID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByExternalIDs returns the users of the source with any of the external
+// IDs, without their teams.
+func (r *userRepository) FindByExternalIDs(source string, externalIDs []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(externalIDs) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("source = ? AND external_id IN ?", source, externalIDs).Find(&users).Error
+	return users, err
+}
+
+// FindByEmails returns the users with any of the emails, without their
+// teams.
+func (r *userRepository) FindByEmails(emails []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(emails) == 0 {
+		return users, nil
+	}
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+	err := r.db.Where("lower(email) IN ?", lowered).Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) GetWithTeams(id uint) (*models.User, error) {
 	var user models.User
 	cacheKey := fmt.Sprintf("user_teams_%d", id)
@@ -91,10 +320,28 @@ func (r *userRepository) GetWithTeams(id uint) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) List() ([]models.User, error) {
+// GetIncludingDeleted is never cached, like every read of deleted rows.
+func (r *userRepository) GetIncludingDeleted(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.Unscoped().Preload("Teams").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) List(opts ListOptions) ([]models.User, error) {
 	var users []models.User
 	cacheKey := "users_list"
 
+	// Deleted, filtered and paged rows are never cached
+	if !opts.cached() {
+		query := opts.page(opts.filter(r.db))
+		if err := query.Find(&users).Error; err != nil {
+			return nil, err
+		}
+		return users, nil
+	}
+
 	// Try to get from cache
 	err := r.cache.Get(cacheKey, &users)
 	if err == nil {
@@ -111,50 +358,154 @@ func (r *userRepository) List() ([]models.User, error) {
 	return users, nil
 }
 
-func (r *userRepository) UpdateWithTeams(user *models.User, teamIDs []uint) error {
-	// Start transaction
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return tx.Error
+func (r *userRepository) Count(opts ListOptions) (int64, error) {
+	var count int64
+	if err := opts.filter(r.db.Model(&models.User{})).Count(&count).Error; err != nil {
+		return 0, err
 	}
+	return count, nil
+}
 
-	// Update user basic info
-	if err := tx.Save(user).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
+func (r *userRepository) UpdateWithTeams(ctx context.Context, user *models.User, teamIDs []uint) error {
+	var changedTeamIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the row, check the version and snapshot current state for the audit log
+		before, err := lockUser(tx, user.ID, user.Version)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(before).Association("Teams").Find(&before.Teams); err != nil {
+			return err
+		}
+		beforeTeamIDs := make([]uint, 0, len(before.Teams))
+		for _, team := range before.Teams {
+			beforeTeamIDs = append(beforeTeamIDs, team.ID)
+		}
 
-	// If teamIDs is provided, update team associations
-	if teamIDs != nil {
-		// Clear existing associations
-		if err := tx.Model(user).Association("Teams").Clear(); err != nil {
-			tx.Rollback()
+		if err := validateAttributes(tx, AttributeEntityUsers, user.Attributes); err != nil {
 			return err
 		}
 
-		// Add new team associations
-		if len(teamIDs) > 0 {
-			var teams []models.Team
-			if err := tx.Find(&teams, teamIDs).Error; err != nil {
-				tx.Rollback()
+		// Update user basic info
+		user.Version = before.Version + 1
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		// If teamIDs is provided, update team associations
+		afterTeamIDs := beforeTeamIDs
+		if teamIDs != nil {
+			// Clear existing associations
+			if err := tx.Model(user).Association("Teams").Clear(); err != nil {
 				return err
 			}
 
-			if err := tx.Model(user).Association("Teams").Replace(&teams); err != nil {
-				tx.Rollback()
-				return err
+			// Add new team associations
+			afterTeamIDs = []uint{}
+			if len(teamIDs) > 0 {
+				var teams []models.Team
+				if err := tx.Find(&teams, teamIDs).Error; err != nil {
+					return err
+				}
+				if missing := missingIDs(teamIDs, teams); len(missing) > 0 {
+					return fmt.Errorf("%w: teams %v", ErrInvalidReference, missing)
+				}
+
+				if err := tx.Model(user).Association("Teams").Replace(&teams); err != nil {
+					return err
+				}
+
+				for _, team := range teams {
+					afterTeamIDs = append(afterTeamIDs, team.ID)
+				}
 			}
 		}
-	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return err
+		// Teams that gained or lost this user have a new representation, and
+		// so do all of its teams when its email or name changed
+		changedTeamIDs = symmetricDifference(beforeTeamIDs, afterTeamIDs)
+		if renamedUser(before, user) {
+			changedTeamIDs = uniqueIDs(append(append([]uint{}, beforeTeamIDs...), afterTeamIDs...))
+		}
+		if err := bumpVersions(tx, &models.Team{}, changedTeamIDs); err != nil {
+			return err
+		}
+
+		// Record the change, including membership, in the same transaction as
+		// the update itself
+		if err := recordAudit(ctx, tx, AuditActionUpdate, AuditEntityUser, user.ID,
+			userWithTeamIDs{User: before, TeamIDs: beforeTeamIDs},
+			userWithTeamIDs{User: user, TeamIDs: afterTeamIDs}); err != nil {
+			return err
+		}
+		return writeOutbox(tx, userEvent(events.UserUpdated, user, afterTeamIDs))
+	})
+	if err != nil {
+		return translateError(err)
 	}
 
 	// Invalidate caches
-	r.cache.Delete("users_list")
-	r.cache.Delete(fmt.Sprintf("user_%d", user.ID))
-	r.cache.Delete(fmt.Sprintf("user_teams_%d", user.ID))
+	r.invalidateUser(user.ID, changedTeamIDs)
 	return nil
 }
+
+// missingIDs returns the ids that have no matching team.
+func missingIDs(ids []uint, teams []models.Team) []uint {
+	found := make(map[uint]bool, len(teams))
+	for _, team := range teams {
+		found[team.ID] = true
+	}
+	var missing []uint
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// userWithTeamIDs is the audit snapshot of a user together with its memberships.
+type userWithTeamIDs struct {
+	*models.User
+	TeamIDs []uint `json:"team_ids"`
+}
+
+// userEvent builds a user event whose data carries the IDs of the teams the
+// user belongs to, or belonged to before a deletion, for team_id filters.
+func userEvent(eventType string, user *models.User, teamIDs []uint) events.Event {
+	if teamIDs == nil {
+		teamIDs = []uint{}
+	}
+	return events.New(eventType, userWithTeamIDs{User: user, TeamIDs: teamIDs})
+}
+
+// renamedUser tells whether an update changes what teams show of the user.
+func renamedUser(before, after *models.User) bool {
+	return before.Email != after.Email || before.Name != after.Name
+}
+
+// invalidateUser drops cached entries for a user and the teams it belongs to.
+func (r *userRepository) invalidateUser(id uint, teamIDs []uint) {
+	r.cache.Delete("users_list")
+	r.cache.Delete(fmt.Sprintf("user_%d", id))
+	r.cache.Delete(fmt.Sprintf("user_teams_%d", id))
+	if len(teamIDs) > 0 {
+		r.cache.Delete("teams_list")
+	}
+	for _, teamID := range teamIDs {
+		r.cache.Delete(fmt.Sprintf("team_%d", teamID))
+	}
+}
+
+// lockUser loads a user with a row lock for the rest of the transaction. A
+// non-zero expectedVersion must match the stored version.
+func lockUser(tx *gorm.DB, id uint, expectedVersion uint) (*models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && expectedVersion != user.Version {
+		return nil, ErrVersionConflict
+	}
+	return &user, nil
+}
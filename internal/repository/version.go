@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"fmt"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// bumpVersions increments the version of every row of model in ids, for
+// changes that alter a representation without going through Save.
+func bumpVersions(tx *gorm.DB, model interface{}, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(model).Where("id IN ?", ids).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// memberIDs returns the IDs on the other side of the live memberships where
+// column equals id.
+func memberIDs(tx *gorm.DB, column, otherColumn string, id uint) ([]uint, error) {
+	ids := []uint{}
+	err := tx.Model(&models.TeamUser{}).
+		Where(fmt.Sprintf("%s = ?", column), id).
+		Pluck(otherColumn, &ids).Error
+	return ids, err
+}
+
+// bumpMembers increments the versions of the rows on the other side of the
+// live memberships where column equals id, whose representations embed the
+// changed row, and returns their IDs.
+func bumpMembers(tx *gorm.DB, column, otherColumn string, id uint) ([]uint, error) {
+	ids, err := memberIDs(tx, column, otherColumn, id)
+	if err != nil {
+		return nil, err
+	}
+	return ids, bumpVersions(tx, otherModel(otherColumn), ids)
+}
+
+// symmetricDifference returns the IDs present in exactly one of a and b.
+func symmetricDifference(a, b []uint) []uint {
+	inA := make(map[uint]bool, len(a))
+	for _, id := range a {
+		inA[id] = true
+	}
+	inB := make(map[uint]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+	}
+
+	var diff []uint
+	for _, id := range a {
+		if !inB[id] {
+			diff = append(diff, id)
+		}
+	}
+	for _, id := range b {
+		if !inA[id] {
+			diff = append(diff, id)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"time"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+func (r *webhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	return r.db.Create(sub).Error
+}
+
+func (r *webhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	return r.db.Save(sub).Error
+}
+
+func (r *webhookRepository) DeleteSubscription(id uint) error {
+	return r.db.Delete(&models.WebhookSubscription{}, id).Error
+}
+
+func (r *webhookRepository) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	if err := r.db.First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *webhookRepository) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	if err := r.db.Order("id").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (r *webhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Create(&deliveries).Error
+}
+
+func (r *webhookRepository) GetDelivery(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) ListDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	query := r.db.Where("subscription_id = ?", subscriptionID).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// DeliveredSubscriptionIDs returns the subscriptions that already have a
+// delivery for eventID, so a replayed event is not queued twice.
+func (r *webhookRepository) DeliveredSubscriptionIDs(eventID string) ([]uint, error) {
+	var ids []uint
+	if err := r.db.Model(&models.WebhookDelivery{}).
+		Where("event_id = ?", eventID).
+		Distinct().
+		Pluck("subscription_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ClaimDueDeliveries locks up to limit pending deliveries whose next attempt is
+// due and pushes their next attempt out by lease, so other replicas skip them
+// while this one is delivering.
+func (r *webhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
@@ -0,0 +1,48 @@
+package requestctx
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	actorKey
+	adminKey
+)
+
+// Anonymous is the actor recorded when a request does not identify itself.
+const Anonymous = "anonymous"
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
+
+func WithAdmin(ctx context.Context, admin bool) context.Context {
+	return context.WithValue(ctx, adminKey, admin)
+}
+
+// IsAdmin reports whether the request authenticated with an admin API key.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey).(bool)
+	return admin
+}
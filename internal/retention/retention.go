@@ -0,0 +1,71 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-sample/internal/repository"
+)
+
+// Job hard-deletes users and teams that have been soft-deleted for longer
+// than the retention period.
+type Job struct {
+	userRepo  repository.UserRepository
+	teamRepo  repository.TeamRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewJob(userRepo repository.UserRepository, teamRepo repository.TeamRepository, retention, interval time.Duration) *Job {
+	return &Job{
+		userRepo:  userRepo,
+		teamRepo:  teamRepo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges on every interval until ctx is cancelled.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges everything currently past retention, in batches.
+func (j *Job) RunOnce(ctx context.Context) {
+	cutoff := time.Now().Add(-j.retention)
+
+	users := purgeAll(ctx, "users", func() (int, error) { return j.userRepo.Purge(ctx, cutoff) })
+	teams := purgeAll(ctx, "teams", func() (int, error) { return j.teamRepo.Purge(ctx, cutoff) })
+
+	if users > 0 || teams > 0 {
+		log.Printf("Retention: purged %d users and %d teams deleted before %s", users, teams, cutoff.Format(time.RFC3339))
+	}
+}
+
+func purgeAll(ctx context.Context, name string, purge func() (int, error)) int {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := purge()
+		if err != nil {
+			log.Printf("Retention: failed to purge %s: %v", name, err)
+			break
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+	}
+	return total
+}
@@ -1,35 +1,119 @@
 package router
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"go-sample/internal/handlers"
+	"go-sample/internal/ratelimit"
+	"go-sample/internal/requestctx"
+	"go-sample/internal/scim"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRouter(userHandler *handlers.UserHandler, teamHandler *handlers.TeamHandler, importHandler *handlers.ImportHandler) *mux.Router {
+func SetupRouter(userHandler *handlers.UserHandler, teamHandler *handlers.TeamHandler, importHandler *handlers.ImportHandler, auditHandler *handlers.AuditHandler, webhookHandler *handlers.WebhookHandler, eventHandler *handlers.EventHandler, searchHandler *handlers.SearchHandler, invitationHandler *handlers.InvitationHandler, schemaHandler *handlers.AttributeSchemaHandler, batchHandler *handlers.BatchHandler, scimHandler *scim.Handler, limiter ratelimit.Limiter, apiKeys, adminKeys []string, trustedProxies []*net.IPNet) *mux.Router {
+	keys := newKeyring(apiKeys, adminKeys)
+
 	router := mux.NewRouter()
+	router.Use(requestContextMiddleware(keys))
 	router.Use(loggingMiddleware)
+	router.Use(rateLimitMiddleware(limiter, keys, trustedProxies))
 
 	// User routes
 	router.HandleFunc("/api/users", userHandler.Create).Methods("POST")
 	router.HandleFunc("/api/users/{id}", userHandler.Update).Methods("PUT")
+	router.HandleFunc("/api/users/{id}", userHandler.Patch).Methods("PATCH")
 	router.HandleFunc("/api/users/{id}", userHandler.Delete).Methods("DELETE")
 	router.HandleFunc("/api/users/{id}", userHandler.GetByID).Methods("GET")
 	router.HandleFunc("/api/users", userHandler.List).Methods("GET")
+	router.HandleFunc("/api/users/{id}/restore", userHandler.Restore).Methods("POST")
+	router.HandleFunc("/api/users/by-external/{source}/{external_id}", userHandler.GetByExternalID).Methods("GET")
 
 	// Team routes
 	router.HandleFunc("/api/teams", teamHandler.Create).Methods("POST")
 	router.HandleFunc("/api/teams/{id}", teamHandler.Update).Methods("PUT")
+	router.HandleFunc("/api/teams/{id}", teamHandler.Patch).Methods("PATCH")
 	router.HandleFunc("/api/teams/{id}", teamHandler.Delete).Methods("DELETE")
 	router.HandleFunc("/api/teams/{id}", teamHandler.GetByID).Methods("GET")
 	router.HandleFunc("/api/teams", teamHandler.List).Methods("GET")
 	router.HandleFunc("/api/teams/{id}/users", teamHandler.AddUser).Methods("POST")
+	router.HandleFunc("/api/teams/{id}/members", teamHandler.Members).Methods("GET")
+	router.HandleFunc("/api/teams/{id}/subtree", teamHandler.Subtree).Methods("GET")
+	router.HandleFunc("/api/teams/{id}/ancestors", teamHandler.Ancestors).Methods("GET")
+	router.HandleFunc("/api/teams/{id}/restore", teamHandler.Restore).Methods("POST")
+	router.HandleFunc("/api/teams/by-external/{source}/{external_id}", teamHandler.GetByExternalID).Methods("GET")
+
+	// Invitation routes
+	router.HandleFunc("/api/teams/{id}/invitations", invitationHandler.Create).Methods("POST")
+	router.HandleFunc("/api/teams/{id}/invitations", invitationHandler.ListByTeam).Methods("GET")
+	router.HandleFunc("/api/users/{id}/invitations", invitationHandler.ListByUser).Methods("GET")
+	router.HandleFunc("/api/invitations/accept", invitationHandler.Accept).Methods("POST")
+	router.HandleFunc("/api/invitations/decline", invitationHandler.Decline).Methods("POST")
+	router.HandleFunc("/api/invitations/{id}", invitationHandler.Revoke).Methods("DELETE")
+
+	// Attribute schema routes
+	router.HandleFunc("/api/attribute-schemas/{entity}", schemaHandler.Get).Methods("GET")
+	router.HandleFunc("/api/attribute-schemas/{entity}", schemaHandler.Put).Methods("PUT")
+	router.HandleFunc("/api/attribute-schemas/{entity}", schemaHandler.Delete).Methods("DELETE")
 
-	// Import route
+	// Import routes
 	router.HandleFunc("/api/import", importHandler.ImportCSV).Methods("POST")
+	router.HandleFunc("/api/imports/{id}", importHandler.Get).Methods("GET")
+	router.HandleFunc("/api/imports/{id}/resume", importHandler.Resume).Methods("POST")
+	router.HandleFunc("/api/imports/{id}/files/{index}/errors.csv", importHandler.DownloadErrors).Methods("GET")
+
+	// Batch route
+	router.HandleFunc("/api/batch", batchHandler.Run).Methods("POST")
+
+	// Audit route
+	router.HandleFunc("/api/audit", auditHandler.List).Methods("GET")
+
+	// Webhook routes
+	router.HandleFunc("/api/webhooks", webhookHandler.Create).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id}", webhookHandler.Update).Methods("PUT")
+	router.HandleFunc("/api/webhooks/{id}", webhookHandler.Delete).Methods("DELETE")
+	router.HandleFunc("/api/webhooks/{id}", webhookHandler.GetByID).Methods("GET")
+	router.HandleFunc("/api/webhooks", webhookHandler.List).Methods("GET")
+	router.HandleFunc("/api/webhooks/{id}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	router.HandleFunc("/api/webhooks/deliveries/{id}/redeliver", webhookHandler.Redeliver).Methods("POST")
+
+	// Search route
+	router.HandleFunc("/api/search", searchHandler.Search).Methods("GET")
+
+	// Change event stream
+	router.HandleFunc("/api/events", eventHandler.Stream).Methods("GET")
+
+	// SCIM provisioning routes
+	scimRouter := router.PathPrefix("/scim/v2").Subrouter()
+	scimRouter.Use(scimHandler.RequireAdmin)
+	scimRouter.HandleFunc("/ServiceProviderConfig", scimHandler.ServiceProviderConfig).Methods("GET")
+	scimRouter.HandleFunc("/Schemas", scimHandler.Schemas).Methods("GET")
+	scimRouter.HandleFunc("/Schemas/{id}", scimHandler.Schema).Methods("GET")
+	scimRouter.HandleFunc("/ResourceTypes", scimHandler.ResourceTypes).Methods("GET")
+	scimRouter.HandleFunc("/ResourceTypes/{id}", scimHandler.ResourceType).Methods("GET")
+	scimRouter.HandleFunc("/Users", scimHandler.ListUsers).Methods("GET")
+	scimRouter.HandleFunc("/Users", scimHandler.CreateUser).Methods("POST")
+	scimRouter.HandleFunc("/Users/{id}", scimHandler.GetUser).Methods("GET")
+	scimRouter.HandleFunc("/Users/{id}", scimHandler.ReplaceUser).Methods("PUT")
+	scimRouter.HandleFunc("/Users/{id}", scimHandler.PatchUser).Methods("PATCH")
+	scimRouter.HandleFunc("/Users/{id}", scimHandler.DeleteUser).Methods("DELETE")
+	scimRouter.HandleFunc("/Groups", scimHandler.ListGroups).Methods("GET")
+	scimRouter.HandleFunc("/Groups", scimHandler.CreateGroup).Methods("POST")
+	scimRouter.HandleFunc("/Groups/{id}", scimHandler.GetGroup).Methods("GET")
+	scimRouter.HandleFunc("/Groups/{id}", scimHandler.ReplaceGroup).Methods("PUT")
+	scimRouter.HandleFunc("/Groups/{id}", scimHandler.PatchGroup).Methods("PATCH")
+	scimRouter.HandleFunc("/Groups/{id}", scimHandler.DeleteGroup).Methods("DELETE")
 
 	// Add a health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +126,198 @@ func SetupRouter(userHandler *handlers.UserHandler, teamHandler *handlers.TeamHa
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.RequestURI)
+		log.Printf("[%s] %s %s", requestctx.RequestID(r.Context()), r.Method, r.RequestURI)
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestContextMiddleware attaches the request ID, acting principal and admin
+// flag to the request context. The request ID is echoed back in the
+// X-Request-ID header. The actor is the configured API key the request
+// authenticated with; admin keys may name who they act for in X-Actor, which
+// is refused with 400 if too long or holding control characters.
+func requestContextMiddleware(keys keyring) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get("X-Request-ID")
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			var actor string
+			apiKey, admin, ok := keys.authenticate(r)
+			if ok {
+				actor = apiKeyActor(apiKey)
+				if onBehalfOf := strings.TrimSpace(r.Header.Get("X-Actor")); onBehalfOf != "" && admin {
+					if !validActor(onBehalfOf) {
+						handlers.ErrorResponse(w, http.StatusBadRequest,
+							fmt.Sprintf("X-Actor must be at most %d characters, without control characters", maxActorLength))
+						return
+					}
+					actor += " as " + onBehalfOf
+				}
+			}
+
+			ctx := requestctx.WithRequestID(r.Context(), requestID)
+			ctx = requestctx.WithActor(ctx, actor)
+			ctx = requestctx.WithAdmin(ctx, admin)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// maxActorLength bounds the X-Actor an admin key may send, which is stored in
+// every audit record the request makes
+const maxActorLength = 255
+
+// validActor reports whether an X-Actor value is short enough and free of
+// control characters, which could forge lines in logs or audit records.
+func validActor(actor string) bool {
+	if utf8.RuneCountInString(actor) > maxActorLength || !utf8.ValidString(actor) {
+		return false
+	}
+	for _, r := range actor {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyring holds the configured API keys, mapped to whether they are admin
+// keys.
+type keyring map[string]bool
+
+func newKeyring(apiKeys, adminKeys []string) keyring {
+	keys := make(keyring, len(apiKeys)+len(adminKeys))
+	for _, key := range apiKeys {
+		keys[key] = false
+	}
+	for _, key := range adminKeys {
+		keys[key] = true
+	}
+	return keys
+}
+
+// authenticate returns the request's API key if it is a configured one, and
+// whether it is an admin key.
+func (k keyring) authenticate(r *http.Request) (apiKey string, admin bool, ok bool) {
+	apiKey = requestAPIKey(r)
+	if apiKey == "" {
+		return "", false, false
+	}
+	if admin, ok = k[apiKey]; !ok {
+		return "", false, false
+	}
+	return apiKey, admin, true
+}
+
+// requestAPIKey reads the API key from X-API-Key or, as identity providers
+// send it for SCIM, from a bearer Authorization header.
+func requestAPIKey(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
+
+// apiKeyActor identifies an API key in audit records without storing the key.
+func apiKeyActor(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "api-key:" + hex.EncodeToString(sum[:4])
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func rateLimitMiddleware(limiter ratelimit.Limiter, keys keyring, trustedProxies []*net.IPNet) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Health checks are never throttled
+			if r.URL.Path == "/health" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter := limiter.Allow(clientKey(r, keys, trustedProxies))
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				handlers.ErrorResponse(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			// A key that is not configured is refused rather than served as
+			// anonymous, once it has counted against the caller's IP so keys
+			// cannot be guessed faster than the anonymous limit. SCIM refuses
+			// it itself, in the error format its clients read
+			if requestAPIKey(r) != "" && !strings.HasPrefix(r.URL.Path, "/scim/") {
+				if _, _, ok := keys.authenticate(r); !ok {
+					handlers.ErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller by API key when it sends a configured one,
+// otherwise by IP, so a client cannot pick its own bucket.
+func clientKey(r *http.Request, keys keyring, trustedProxies []*net.IPNet) string {
+	if apiKey, _, ok := keys.authenticate(r); ok {
+		return "key:" + apiKey
+	}
+	return "ip:" + clientIP(r, trustedProxies)
+}
+
+// clientIP returns the peer's address or, when the peer is a trusted proxy,
+// the last address in X-Forwarded-For that is not one. Entries before it are
+// whatever the client sent.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop, trustedProxies) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
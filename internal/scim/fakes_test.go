@@ -0,0 +1,216 @@
+package scim
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// fakeUserRepository keeps users, deactivated ones included, in memory.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[uint]*models.User
+}
+
+func newFakeUserRepository(users ...models.User) *fakeUserRepository {
+	repo := &fakeUserRepository{users: make(map[uint]*models.User)}
+	for i := range users {
+		user := users[i]
+		repo.users[user.ID] = &user
+	}
+	return repo
+}
+
+// userColumn returns the value of a column List may be asked to match.
+func userColumn(user *models.User, column string) string {
+	switch column {
+	case "email":
+		return user.Email
+	case "name":
+		return user.Name
+	case "source":
+		return user.Source
+	case "external_id":
+		return user.ExternalID
+	}
+	panic("unexpected column " + column)
+}
+
+// selected returns the users opts select in ID order, without paging.
+func (r *fakeUserRepository) selected(opts repository.ListOptions) []models.User {
+	users := []models.User{}
+	for _, user := range r.users {
+		if user.DeletedAt.Valid && !opts.IncludeDeleted {
+			continue
+		}
+		matches := true
+		for column, value := range opts.Equal {
+			matches = matches && strings.EqualFold(userColumn(user, column), value)
+		}
+		if matches {
+			users = append(users, *user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func (r *fakeUserRepository) Count(opts repository.ListOptions) (int64, error) {
+	return int64(len(r.selected(opts))), nil
+}
+
+func (r *fakeUserRepository) List(opts repository.ListOptions) ([]models.User, error) {
+	users := r.selected(opts)
+	if opts.Offset > len(users) {
+		opts.Offset = len(users)
+	}
+	users = users[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(users) {
+		users = users[:opts.Limit]
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) GetIncludingDeleted(id uint) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) Update(ctx context.Context, user *models.User) error {
+	stored, ok := r.users[user.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	if user.Version != 0 && user.Version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	user.Version = stored.Version + 1
+	updated := *user
+	r.users[user.ID] = &updated
+	return nil
+}
+
+func (r *fakeUserRepository) Delete(ctx context.Context, id uint, version uint) error {
+	stored, ok := r.users[id]
+	if !ok || stored.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	if version != 0 && version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	stored.Version++
+	return nil
+}
+
+func (r *fakeUserRepository) Restore(ctx context.Context, id uint) error {
+	stored, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if !stored.DeletedAt.Valid {
+		return repository.ErrNotDeleted
+	}
+	stored.DeletedAt = gorm.DeletedAt{}
+	stored.Version++
+	return nil
+}
+
+// fakeTeamRepository keeps teams and the IDs of their members, which must be
+// users of users.
+type fakeTeamRepository struct {
+	repository.TeamRepository
+	users   *fakeUserRepository
+	teams   map[uint]*models.Team
+	members map[uint][]uint
+}
+
+func newFakeTeamRepository(users *fakeUserRepository, teams ...models.Team) *fakeTeamRepository {
+	repo := &fakeTeamRepository{users: users, teams: make(map[uint]*models.Team), members: make(map[uint][]uint)}
+	for i := range teams {
+		team := teams[i]
+		repo.teams[team.ID] = &team
+	}
+	return repo
+}
+
+// GetByID returns the team with its members.
+func (r *fakeTeamRepository) GetByID(id uint) (*models.Team, error) {
+	team, ok := r.teams[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *team
+	copied.Users = nil
+	for _, userID := range r.members[id] {
+		copied.Users = append(copied.Users, *r.users.users[userID])
+	}
+	return &copied, nil
+}
+
+func (r *fakeTeamRepository) Update(ctx context.Context, team *models.Team) error {
+	stored, ok := r.teams[team.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if team.Version != 0 && team.Version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	team.Version = stored.Version + 1
+	updated := *team
+	updated.Users = nil
+	r.teams[team.ID] = &updated
+	return nil
+}
+
+func (r *fakeTeamRepository) SetMembers(ctx context.Context, teamID uint, userIDs []uint) error {
+	team, ok := r.teams[teamID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for _, userID := range userIDs {
+		if _, ok := r.users.users[userID]; !ok {
+			return repository.ErrInvalidReference
+		}
+	}
+	r.members[teamID] = append([]uint(nil), userIDs...)
+	team.Version++
+	return nil
+}
+
+// serve runs handler for a request with the mux variables vars set.
+func serve(handler http.HandlerFunc, req *http.Request, vars map[string]string) *httptest.ResponseRecorder {
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func newRequest(method, target, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", ContentType)
+	}
+	return req
+}
@@ -0,0 +1,474 @@
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2). It
+// is evaluated against a resource decoded into generic JSON values; string
+// comparisons ignore case, as every supported attribute is caseExact=false.
+type filter interface {
+	match(resource map[string]interface{}) bool
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right filter
+}
+
+type notFilter struct {
+	inner filter
+}
+
+type compareFilter struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+// valuePathFilter is attr[filter], matching when any element does.
+type valuePathFilter struct {
+	attr  string
+	inner filter
+}
+
+func (f logicalFilter) match(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.match(resource) && f.right.match(resource)
+	}
+	return f.left.match(resource) || f.right.match(resource)
+}
+
+func (f notFilter) match(resource map[string]interface{}) bool {
+	return !f.inner.match(resource)
+}
+
+func (f valuePathFilter) match(resource map[string]interface{}) bool {
+	for _, element := range asList(lookup(resource, f.attr)) {
+		if object, ok := element.(map[string]interface{}); ok && f.inner.match(object) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f compareFilter) match(resource map[string]interface{}) bool {
+	values := resolve(resource, f.path)
+	if f.op == "pr" {
+		for _, value := range values {
+			if present(value) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, value := range values {
+		// emails co "example.com" compares the value of each email
+		if object, ok := value.(map[string]interface{}); ok {
+			value = lookup(object, "value")
+		}
+		if compare(value, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns every value at path, descending into multi-valued
+// attributes.
+func resolve(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return asList(value)
+	}
+	var values []interface{}
+	for _, element := range asList(value) {
+		if object, ok := element.(map[string]interface{}); ok {
+			values = append(values, resolve(lookup(object, path[0]), path[1:])...)
+		}
+	}
+	return values
+}
+
+// lookup finds an attribute ignoring case, as SCIM attribute names are
+// case-insensitive.
+func lookup(object map[string]interface{}, name string) interface{} {
+	if value, ok := object[name]; ok {
+		return value
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+func asList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+func present(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func compare(actual interface{}, op string, expected interface{}) bool {
+	switch a := actual.(type) {
+	case string:
+		e, ok := expected.(string)
+		if !ok {
+			return false
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case float64:
+		e, ok := expected.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		e, ok := expected.(bool)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		}
+	case nil:
+		return op == "eq" && expected == nil
+	}
+	return false
+}
+
+var operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+// parseFilter parses a filter expression. Precedence, highest first, is
+// grouping, not, and, or.
+func parseFilter(input string) (filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text string
+	// quoted tokens are string literals, never keywords
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[]\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *filterParser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.quoted || t.text != text {
+		return fmt.Errorf("expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return notFilter{inner: inner}, nil
+	}
+	if p.peekKeyword("(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filter, error) {
+	attr, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if attr.quoted {
+		return nil, fmt.Errorf("expected an attribute, got %q", attr.text)
+	}
+	name := stripSchema(attr.text)
+
+	// emails[type eq "work"] and the like
+	if p.peekKeyword("[") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return valuePathFilter{attr: name, inner: inner}, nil
+	}
+
+	opToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opToken.text)
+	if opToken.quoted || !operators[op] {
+		return nil, fmt.Errorf("unknown operator %q", opToken.text)
+	}
+	f := compareFilter{path: strings.Split(name, "."), op: op}
+	if op == "pr" {
+		return f, nil
+	}
+
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if f.value, err = literal(valueToken); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func literal(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", t.text)
+	}
+	return n, nil
+}
+
+// stripSchema turns a fully qualified attribute such as
+// urn:ietf:params:scim:schemas:core:2.0:User:userName into userName.
+func stripSchema(attr string) string {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(attr) > len(schema) && strings.EqualFold(attr[:len(schema)+1], schema+":") {
+			return attr[len(schema)+1:]
+		}
+	}
+	return attr
+}
+
+// splitFilter takes the eq comparisons on the attributes in columns out of
+// the top-level conjunction of f, so the repository can match them. It
+// returns the columns to match and the rest of the filter, which is nil when
+// nothing is left to evaluate in memory. columns maps lower-cased attribute
+// names to column names.
+func splitFilter(f filter, columns map[string]string) (map[string]string, filter) {
+	equal := map[string]string{}
+	var rest filter
+	for _, conjunct := range conjuncts(f, nil) {
+		if column, value, ok := equalColumn(conjunct, columns); ok {
+			if current, set := equal[column]; !set || strings.EqualFold(current, value) {
+				equal[column] = value
+				continue
+			}
+		}
+		if rest == nil {
+			rest = conjunct
+		} else {
+			rest = logicalFilter{and: true, left: rest, right: conjunct}
+		}
+	}
+	return equal, rest
+}
+
+func conjuncts(f filter, list []filter) []filter {
+	if logical, ok := f.(logicalFilter); ok && logical.and {
+		return conjuncts(logical.right, conjuncts(logical.left, list))
+	}
+	return append(list, f)
+}
+
+// equalColumn matches attr eq "value" on an attribute in columns. Empty
+// values are left to the filter, as empty attributes are omitted from
+// resources and never equal.
+func equalColumn(f filter, columns map[string]string) (string, string, bool) {
+	compare, ok := f.(compareFilter)
+	if !ok || compare.op != "eq" || len(compare.path) != 1 {
+		return "", "", false
+	}
+	value, ok := compare.value.(string)
+	column, known := columns[strings.ToLower(compare.path[0])]
+	if !ok || !known || value == "" {
+		return "", "", false
+	}
+	return column, value, true
+}
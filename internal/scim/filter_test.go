@@ -0,0 +1,159 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// bjensen is the user of the RFC 7644 examples
+const bjensen = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+	"userName": "bjensen",
+	"name": {"familyName": "O'Malley", "givenName": "Barbara"},
+	"title": "Tour Guide",
+	"userType": "Employee",
+	"active": true,
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work"},
+		{"value": "babs@jensen.org", "type": "home"}
+	],
+	"ims": [{"value": "someaimhandle", "type": "aim"}],
+	"meta": {"lastModified": "2011-05-13T04:42:34Z"}
+}`
+
+func decodeObject(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return object
+}
+
+// The examples of RFC 7644 section 3.4.2.2, and variations on them
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "bjensen"`, true},
+		{`userName eq "BJensen"`, true},
+		{`userName ne "bjensen"`, false},
+		{`name.familyName co "O'Malley"`, true},
+		{`userName sw "J"`, false},
+		{`userName sw "b"`, true},
+		{`userName ew "sen"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "b"`, true},
+		{`title pr`, true},
+		{`nickName pr`, false},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`, false},
+		{`meta.lastModified ge "2011-05-13T04:42:34Z"`, true},
+		{`meta.lastModified lt "2011-05-13T04:42:34Z"`, false},
+		{`meta.lastModified le "2011-05-13T04:42:34Z"`, true},
+		{`title pr and userType eq "Employee"`, true},
+		{`title pr or userType eq "Intern"`, true},
+		{`schemas eq "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`, true},
+		{`userType eq "Employee" and (emails co "example.com" or emails.value co "example.org")`, true},
+		{`userType ne "Employee" and not (emails co "example.com" or emails.value co "example.org")`, false},
+		{`userType eq "Employee" and (emails.type eq "work")`, true},
+		{`userType eq "Employee" and emails[type eq "work" and value co "@example.com"]`, true},
+		{`emails[type eq "work" and value co "@example.com"] or ims[type eq "xmpp" and value co "@foo.com"]`, true},
+		{`emails[type eq "home" and value co "@example.com"]`, false},
+		{`not (userName eq "bjensen")`, false},
+		{`userName eq "x" or userName eq "bjensen" and title pr`, true},
+		{`(userName eq "x" or userName eq "bjensen") and nickName pr`, false},
+		{`active eq true`, true},
+		{`active eq "true"`, false},
+		{`USERNAME EQ "bjensen" AND TITLE PR`, true},
+	}
+	resource := decodeObject(t, bjensen)
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := f.match(resource); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterMalformed(t *testing.T) {
+	tests := []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName foo "bjensen"`,
+		`userName eq "bjensen`,
+		`userName eq bjensen`,
+		`"userName" eq "bjensen"`,
+		`userName "eq" "bjensen"`,
+		`(userName eq "bjensen"`,
+		`userName eq "bjensen")`,
+		`emails[type eq "work"`,
+		`not userName eq "bjensen"`,
+		`userName eq "bjensen" and`,
+		`userName eq "bjensen" xor title pr`,
+		`userName eq "bjensen" title pr`,
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if _, err := parseFilter(input); err == nil {
+				t.Errorf("expected %s to be rejected", input)
+			}
+		})
+	}
+}
+
+func TestSplitFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		equal  map[string]string
+		rest   bool
+	}{
+		{`userName eq "a@example.com"`, map[string]string{"email": "a@example.com"}, false},
+		{`USERNAME eq "a@example.com"`, map[string]string{"email": "a@example.com"}, false},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "a@example.com"`, map[string]string{"email": "a@example.com"}, false},
+		{`userName eq "a@example.com" and displayName eq "A"`, map[string]string{"email": "a@example.com", "name": "A"}, false},
+		{`userName eq "a@example.com" and title pr`, map[string]string{"email": "a@example.com"}, true},
+		{`userName eq "a@example.com" and userName eq "b@example.com"`, map[string]string{"email": "a@example.com"}, true},
+		{`userName eq "a@example.com" or displayName eq "A"`, map[string]string{}, true},
+		{`userName co "example.com"`, map[string]string{}, true},
+		{`userName eq ""`, map[string]string{}, true},
+		{`displayName eq 1`, map[string]string{}, true},
+		{`name.formatted eq "A"`, map[string]string{}, true},
+		{`not (userName eq "a@example.com")`, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			equal, rest := splitFilter(f, userColumns)
+			if !reflect.DeepEqual(equal, tt.equal) {
+				t.Errorf("equal = %v, want %v", equal, tt.equal)
+			}
+			if (rest != nil) != tt.rest {
+				t.Errorf("rest = %v, want one: %v", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestListOptionsMatchExternalIDSource(t *testing.T) {
+	f, err := parseFilter(`externalId eq "42" and displayName eq "Ops"`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	query := &listQuery{filter: f}
+	opts, rest := query.listOptions(groupColumns)
+	want := map[string]string{"external_id": "42", "title": "Ops", "source": ExternalSource}
+	if !reflect.DeepEqual(opts.Equal, want) || rest != nil {
+		t.Errorf("got %v and %v, want %v and no rest", opts.Equal, rest, want)
+	}
+}
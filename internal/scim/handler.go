@@ -0,0 +1,777 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+	"go-sample/internal/requestctx"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const maxBodySize = 1 << 20
+
+// Handler serves the /scim/v2 endpoints. Every endpoint requires an admin
+// API key. A user's active flag maps onto soft deletion: deactivating or
+// deleting a user soft-deletes it, and reactivating restores it.
+type Handler struct {
+	userRepo repository.UserRepository
+	teamRepo repository.TeamRepository
+}
+
+func NewHandler(userRepo repository.UserRepository, teamRepo repository.TeamRepository) *Handler {
+	return &Handler{
+		userRepo: userRepo,
+		teamRepo: teamRepo,
+	}
+}
+
+// RequireAdmin rejects requests without an admin API key.
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requestctx.IsAdmin(r.Context()) {
+			errorResponse(w, http.StatusUnauthorized, "", "SCIM requires an admin API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, serviceProviderConfig(baseURL(r)))
+}
+
+func (h *Handler) Schemas(w http.ResponseWriter, r *http.Request) {
+	list := schemas(baseURL(r))
+	resources := make([]interface{}, len(list))
+	for i := range list {
+		resources[i] = list[i]
+	}
+	jsonResponse(w, http.StatusOK, newListResponse(resources, len(resources), 1))
+}
+
+func (h *Handler) Schema(w http.ResponseWriter, r *http.Request) {
+	for _, s := range schemas(baseURL(r)) {
+		if s.ID == mux.Vars(r)["id"] {
+			jsonResponse(w, http.StatusOK, s)
+			return
+		}
+	}
+	errorResponse(w, http.StatusNotFound, "", "Schema not found")
+}
+
+func (h *Handler) ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	list := resourceTypes(baseURL(r))
+	resources := make([]interface{}, len(list))
+	for i := range list {
+		resources[i] = list[i]
+	}
+	jsonResponse(w, http.StatusOK, newListResponse(resources, len(resources), 1))
+}
+
+func (h *Handler) ResourceType(w http.ResponseWriter, r *http.Request) {
+	for _, t := range resourceTypes(baseURL(r)) {
+		if t.ID == mux.Vars(r)["id"] {
+			jsonResponse(w, http.StatusOK, t)
+			return
+		}
+	}
+	errorResponse(w, http.StatusNotFound, "", "Resource type not found")
+}
+
+// ListUsers returns users, deactivated ones included, in ID order.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseListQuery(w, r)
+	if !ok {
+		return
+	}
+	opts, rest := query.listOptions(userColumns)
+	opts.IncludeDeleted = true
+
+	var total int64
+	var users []models.User
+	var err error
+	if rest == nil {
+		total, err = h.userRepo.Count(opts)
+	}
+	if err == nil && query.fetch(rest, &opts) {
+		users, err = h.userRepo.List(opts)
+	}
+	if err != nil {
+		repositoryError(w, r, err, "User")
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	base := baseURL(r)
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, newUser(&users[i], base))
+	}
+	h.list(w, r, query, rest, resources, total)
+}
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+	resourceResponse(w, http.StatusOK, newUser(user, baseURL(r)))
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var resource User
+	if !decode(w, r, &resource) {
+		return
+	}
+	user := &models.User{}
+	if err := applyUser(user, &resource); err != nil {
+		requestErrorResponse(w, err)
+		return
+	}
+	if err := h.userRepo.Create(r.Context(), user); err != nil {
+		repositoryError(w, r, err, "User")
+		return
+	}
+	if resource.Active != nil && !*resource.Active {
+		if err := h.userRepo.Delete(r.Context(), user.ID, user.Version); err != nil {
+			repositoryError(w, r, err, "User")
+			return
+		}
+	}
+	h.respondUser(w, r, user.ID, http.StatusCreated)
+}
+
+func (h *Handler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok || !checkVersion(w, r, user.Version) {
+		return
+	}
+	var resource User
+	if !decode(w, r, &resource) {
+		return
+	}
+	h.saveUser(w, r, user, &resource)
+}
+
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok || !checkVersion(w, r, user.Version) {
+		return
+	}
+	current := newUser(user, baseURL(r))
+	var resource User
+	if !patch(w, r, current, &resource) {
+		return
+	}
+	resource.preferChangedName(current)
+	h.saveUser(w, r, user, &resource)
+}
+
+// DeleteUser soft-deletes the user, the same as deactivating it.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok || !checkVersion(w, r, user.Version) {
+		return
+	}
+	if !user.DeletedAt.Valid {
+		if err := h.userRepo.Delete(r.Context(), user.ID, user.Version); err != nil {
+			repositoryError(w, r, err, "User")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveUser stores the replacement resource, restoring or deleting the user
+// when its active flag changes. Attributes of an inactive user cannot change
+// unless it is reactivated in the same request.
+func (h *Handler) saveUser(w http.ResponseWriter, r *http.Request, user *models.User, resource *User) {
+	ctx := r.Context()
+	wasActive := !user.DeletedAt.Valid
+	active := wasActive
+	if resource.Active != nil {
+		active = *resource.Active
+	}
+
+	updated := *user
+	updated.Teams = nil
+	if err := applyUser(&updated, resource); err != nil {
+		requestErrorResponse(w, err)
+		return
+	}
+	changed := updated.Email != user.Email || updated.Name != user.Name ||
+		updated.Source != user.Source || updated.ExternalID != user.ExternalID
+
+	if !wasActive && !active {
+		if changed {
+			errorResponse(w, http.StatusBadRequest, "mutability", "An inactive user cannot be modified; set active to true")
+			return
+		}
+		h.respondUser(w, r, user.ID, http.StatusOK)
+		return
+	}
+
+	if !wasActive {
+		if err := h.userRepo.Restore(ctx, user.ID); err != nil {
+			repositoryError(w, r, err, "User")
+			return
+		}
+		updated.DeletedAt = gorm.DeletedAt{}
+		updated.Version++
+	}
+	if changed {
+		if err := h.userRepo.Update(ctx, &updated); err != nil {
+			repositoryError(w, r, err, "User")
+			return
+		}
+	}
+	if !active {
+		if err := h.userRepo.Delete(ctx, updated.ID, updated.Version); err != nil {
+			repositoryError(w, r, err, "User")
+			return
+		}
+	}
+	h.respondUser(w, r, user.ID, http.StatusOK)
+}
+
+func (h *Handler) loadUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return nil, false
+	}
+	user, err := h.userRepo.GetIncludingDeleted(id)
+	if err != nil {
+		repositoryError(w, r, err, "User")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *Handler) respondUser(w http.ResponseWriter, r *http.Request, id uint, status int) {
+	user, err := h.userRepo.GetIncludingDeleted(id)
+	if err != nil {
+		repositoryError(w, r, err, "User")
+		return
+	}
+	resource := newUser(user, baseURL(r))
+	if status == http.StatusCreated {
+		w.Header().Set("Location", resource.Meta.Location)
+	}
+	resourceResponse(w, status, resource)
+}
+
+// applyUser copies the writable attributes of a SCIM user onto the model.
+func applyUser(user *models.User, resource *User) error {
+	email := strings.TrimSpace(resource.UserName)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return badRequest("invalidValue", "userName must be an email address")
+	}
+	user.Email = email
+	user.Name = strings.TrimSpace(resource.displayName())
+	applyExternalID(&user.Source, &user.ExternalID, resource.ExternalID)
+	return nil
+}
+
+// ListGroups returns teams in ID order.
+func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseListQuery(w, r)
+	if !ok {
+		return
+	}
+	opts, rest := query.listOptions(groupColumns)
+
+	var total int64
+	var teams []models.Team
+	var err error
+	if rest == nil {
+		total, err = h.teamRepo.Count(opts)
+	}
+	if err == nil && query.fetch(rest, &opts) {
+		teams, err = h.teamRepo.List(opts)
+	}
+	if err != nil {
+		repositoryError(w, r, err, "Group")
+		return
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+
+	base := baseURL(r)
+	resources := make([]interface{}, 0, len(teams))
+	for i := range teams {
+		resources = append(resources, newGroup(&teams[i], base))
+	}
+	h.list(w, r, query, rest, resources, total)
+}
+
+func (h *Handler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	resourceResponse(w, http.StatusOK, newGroup(team, baseURL(r)))
+}
+
+func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var resource Group
+	if !decode(w, r, &resource) {
+		return
+	}
+	team := &models.Team{}
+	memberIDs, err := applyGroup(team, &resource)
+	if err != nil {
+		requestErrorResponse(w, err)
+		return
+	}
+	if err := h.teamRepo.Create(r.Context(), team); err != nil {
+		repositoryError(w, r, err, "Group")
+		return
+	}
+	if len(memberIDs) > 0 {
+		if err := h.teamRepo.SetMembers(r.Context(), team.ID, memberIDs); err != nil {
+			repositoryError(w, r, err, "Group")
+			return
+		}
+	}
+	h.respondGroup(w, r, team.ID, http.StatusCreated)
+}
+
+func (h *Handler) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadGroup(w, r)
+	if !ok || !checkVersion(w, r, team.Version) {
+		return
+	}
+	var resource Group
+	if !decode(w, r, &resource) {
+		return
+	}
+	h.saveGroup(w, r, team, &resource)
+}
+
+func (h *Handler) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadGroup(w, r)
+	if !ok || !checkVersion(w, r, team.Version) {
+		return
+	}
+	var resource Group
+	if !patch(w, r, newGroup(team, baseURL(r)), &resource) {
+		return
+	}
+	h.saveGroup(w, r, team, &resource)
+}
+
+func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.loadGroup(w, r)
+	if !ok || !checkVersion(w, r, team.Version) {
+		return
+	}
+	if err := h.teamRepo.Delete(r.Context(), team.ID, team.Version); err != nil {
+		repositoryError(w, r, err, "Group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveGroup stores the replacement resource; omitted members clear the team.
+func (h *Handler) saveGroup(w http.ResponseWriter, r *http.Request, team *models.Team, resource *Group) {
+	ctx := r.Context()
+	updated := *team
+	updated.Users = nil
+	memberIDs, err := applyGroup(&updated, resource)
+	if err != nil {
+		requestErrorResponse(w, err)
+		return
+	}
+
+	if updated.Title != team.Title || updated.Source != team.Source || updated.ExternalID != team.ExternalID {
+		if err := h.teamRepo.Update(ctx, &updated); err != nil {
+			repositoryError(w, r, err, "Group")
+			return
+		}
+	}
+	if !sameMembers(team.Users, memberIDs) {
+		if err := h.teamRepo.SetMembers(ctx, team.ID, memberIDs); err != nil {
+			repositoryError(w, r, err, "Group")
+			return
+		}
+	}
+	h.respondGroup(w, r, team.ID, http.StatusOK)
+}
+
+func (h *Handler) loadGroup(w http.ResponseWriter, r *http.Request) (*models.Team, bool) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return nil, false
+	}
+	team, err := h.teamRepo.GetByID(id)
+	if err != nil {
+		repositoryError(w, r, err, "Group")
+		return nil, false
+	}
+	return team, true
+}
+
+func (h *Handler) respondGroup(w http.ResponseWriter, r *http.Request, id uint, status int) {
+	team, err := h.teamRepo.GetByID(id)
+	if err != nil {
+		repositoryError(w, r, err, "Group")
+		return
+	}
+	resource := newGroup(team, baseURL(r))
+	if status == http.StatusCreated {
+		w.Header().Set("Location", resource.Meta.Location)
+	}
+	resourceResponse(w, status, resource)
+}
+
+// applyGroup copies the writable attributes of a SCIM group onto the model
+// and returns its member IDs.
+func applyGroup(team *models.Team, resource *Group) ([]uint, error) {
+	title := strings.TrimSpace(resource.DisplayName)
+	if title == "" {
+		return nil, badRequest("invalidValue", "displayName is required")
+	}
+	memberIDs, err := resource.memberIDs()
+	if err != nil {
+		return nil, badRequest("invalidValue", "%v", err)
+	}
+	team.Title = title
+	applyExternalID(&team.Source, &team.ExternalID, resource.ExternalID)
+	return memberIDs, nil
+}
+
+// applyExternalID records a SCIM externalId under the scim source. Clearing
+// it only clears an ID that SCIM set, never one from another source.
+func applyExternalID(source, externalID *string, value string) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value != "":
+		*source, *externalID = ExternalSource, value
+	case *source == ExternalSource:
+		*source, *externalID = "", ""
+	}
+}
+
+func sameMembers(users []models.User, ids []uint) bool {
+	current := make(map[uint]bool, len(users))
+	for _, user := range users {
+		current[user.ID] = true
+	}
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	if len(current) != len(wanted) {
+		return false
+	}
+	for id := range wanted {
+		if !current[id] {
+			return false
+		}
+	}
+	return true
+}
+
+type listQuery struct {
+	filter     filter
+	startIndex int
+	count      int
+}
+
+// parseListQuery reads filter, startIndex (1-based) and count. Out of range
+// paging values are clamped as RFC 7644 section 3.4.2.4 asks.
+func parseListQuery(w http.ResponseWriter, r *http.Request) (*listQuery, bool) {
+	params := r.URL.Query()
+	query := &listQuery{startIndex: 1, count: 100}
+
+	if expr := params.Get("filter"); expr != "" {
+		f, err := parseFilter(expr)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return nil, false
+		}
+		query.filter = f
+	}
+	if value := params.Get("startIndex"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+			return nil, false
+		}
+		if n > 1 {
+			query.startIndex = n
+		}
+	}
+	if value := params.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "invalidValue", "count must be an integer")
+			return nil, false
+		}
+		query.count = n
+	}
+	if query.count < 0 {
+		query.count = 0
+	}
+	if query.count > maxResults {
+		query.count = maxResults
+	}
+	return query, true
+}
+
+// Attributes that list filters match in the repository, by lower-cased name
+var (
+	userColumns  = map[string]string{"username": "email", "displayname": "name", "externalid": "external_id"}
+	groupColumns = map[string]string{"displayname": "title", "externalid": "external_id"}
+)
+
+// listOptions moves the comparisons of the filter that the repository can
+// match into list options, and returns the rest of the filter.
+func (q *listQuery) listOptions(columns map[string]string) (repository.ListOptions, filter) {
+	if q.filter == nil {
+		return repository.ListOptions{}, nil
+	}
+	equal, rest := splitFilter(q.filter, columns)
+	if _, ok := equal["external_id"]; ok {
+		// Only SCIM external IDs are exposed
+		equal["source"] = ExternalSource
+	}
+	return repository.ListOptions{Equal: equal}, rest
+}
+
+// fetch tells whether rows must be listed. When the repository matches the
+// whole filter it also pages the rows, so an empty page needs none.
+func (q *listQuery) fetch(rest filter, opts *repository.ListOptions) bool {
+	if rest != nil {
+		return true
+	}
+	opts.Offset, opts.Limit = q.startIndex-1, q.count
+	return q.count > 0
+}
+
+// list responds with the page of resources. Without a remaining filter the
+// resources are already the page and total counts every match; otherwise
+// they are filtered and paged here.
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, query *listQuery, rest filter, resources []interface{}, total int64) {
+	if rest == nil {
+		jsonResponse(w, http.StatusOK, newListResponse(resources, int(total), query.startIndex))
+		return
+	}
+
+	matched := resources[:0:0]
+	for _, resource := range resources {
+		object, err := toObject(resource)
+		if err != nil {
+			repositoryError(w, r, err, "")
+			return
+		}
+		if rest.match(object) {
+			matched = append(matched, resource)
+		}
+	}
+
+	start := query.startIndex - 1
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + query.count
+	if end > len(matched) {
+		end = len(matched)
+	}
+	response := newListResponse(matched[start:end], len(matched), query.startIndex)
+	jsonResponse(w, http.StatusOK, response)
+}
+
+func newListResponse(resources []interface{}, total, startIndex int) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// patch applies the PatchOp request body to the current resource and decodes
+// the result into patched.
+func patch(w http.ResponseWriter, r *http.Request, current interface{}, patched interface{}) bool {
+	var request PatchRequest
+	if !decode(w, r, &request) {
+		return false
+	}
+	if len(request.Operations) == 0 {
+		errorResponse(w, http.StatusBadRequest, "invalidSyntax", "Operations is required")
+		return false
+	}
+
+	object, err := toObject(current)
+	if err != nil {
+		repositoryError(w, r, err, "")
+		return false
+	}
+	if err := applyPatch(object, request.Operations); err != nil {
+		requestErrorResponse(w, err)
+		return false
+	}
+	normalizeActive(object)
+
+	data, err := json.Marshal(object)
+	if err == nil {
+		err = json.Unmarshal(data, patched)
+	}
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalidValue", "Patched resource is invalid: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// normalizeActive accepts "True" and "False", which some identity providers
+// send instead of booleans.
+func normalizeActive(object map[string]interface{}) {
+	name := key(object, "active")
+	if s, ok := object[name].(string); ok {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			object[name] = b
+		}
+	}
+}
+
+func toObject(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalidSyntax", "Could not read request body")
+		return false
+	}
+	if len(body) > maxBodySize {
+		errorResponse(w, http.StatusRequestEntityTooLarge, "", "Request body is too large")
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalidSyntax", "Invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func parseID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "", "Resource not found")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// checkVersion honours an optional If-Match against the resource version.
+func checkVersion(w http.ResponseWriter, r *http.Request, version uint) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag(version) {
+			return true
+		}
+	}
+	errorResponse(w, http.StatusPreconditionFailed, "", "Resource has been modified; refetch and retry")
+	return false
+}
+
+// baseURL is the absolute /scim/v2 URL of this server, used in meta.location.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+func resourceResponse(w http.ResponseWriter, status int, resource interface{}) {
+	var version string
+	switch v := resource.(type) {
+	case *User:
+		version = v.Meta.Version
+	case *Group:
+		version = v.Meta.Version
+	}
+	if version != "" {
+		w.Header().Set("ETag", version)
+	}
+	jsonResponse(w, status, resource)
+}
+
+func jsonResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func errorResponse(w http.ResponseWriter, status int, scimType, detail string) {
+	jsonResponse(w, status, Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func requestErrorResponse(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		errorResponse(w, http.StatusBadRequest, reqErr.scimType, reqErr.detail)
+		return
+	}
+	errorResponse(w, http.StatusBadRequest, "invalidValue", err.Error())
+}
+
+// repositoryError maps a repository error to a SCIM error. Unexpected errors
+// are logged and answered with a generic 500.
+func repositoryError(w http.ResponseWriter, r *http.Request, err error, subject string) {
+	var attrErr *repository.AttributeError
+	switch {
+	case errors.As(err, &attrErr):
+		errorResponse(w, http.StatusBadRequest, "invalidValue", attrErr.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		errorResponse(w, http.StatusNotFound, "", subject+" not found")
+	case errors.Is(err, repository.ErrVersionConflict):
+		errorResponse(w, http.StatusPreconditionFailed, "", "Resource has been modified; refetch and retry")
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		errorResponse(w, http.StatusConflict, "uniqueness", "A user with this userName already exists")
+	case errors.Is(err, repository.ErrDuplicateExternalID):
+		errorResponse(w, http.StatusConflict, "uniqueness", "Another resource already has this externalId")
+	case errors.Is(err, repository.ErrInvalidReference):
+		errorResponse(w, http.StatusBadRequest, "invalidValue", "A referenced member does not exist")
+	case errors.Is(err, repository.ErrConflict), errors.Is(err, repository.ErrAlreadyMember):
+		// Memberships added concurrently collide on the same member
+		errorResponse(w, http.StatusConflict, "", "The change conflicts with the current state; retry")
+	default:
+		log.Printf("[%s] %s %s: %v", requestctx.RequestID(r.Context()), r.Method, r.URL.Path, err)
+		errorResponse(w, http.StatusInternalServerError, "", "An unexpected error occurred")
+	}
+}
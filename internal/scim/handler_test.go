@@ -0,0 +1,199 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-sample/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// listUsers returns four users; Bob is deactivated and Carol provisioned
+// with a SCIM externalId.
+func listUsers() *fakeUserRepository {
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return newFakeUserRepository(
+		models.User{ID: 1, Email: "ada@example.com", Name: "Ada", Version: 1},
+		models.User{ID: 2, Email: "bob@example.com", Name: "Bob", Version: 2, DeletedAt: deleted},
+		models.User{ID: 3, Email: "carol@example.com", Name: "Carol", Version: 1, Source: ExternalSource, ExternalID: "c-1"},
+		models.User{ID: 4, Email: "dave@example.com", Name: "Dave", Version: 1},
+	)
+}
+
+func TestListUsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		total int
+		ids   []string
+	}{
+		{"everyone", "", 4, []string{"1", "2", "3", "4"}},
+		{"page", "startIndex=2&count=2", 4, []string{"2", "3"}},
+		{"past the end", "startIndex=9", 4, []string{}},
+		{"no resources", "count=0", 4, []string{}},
+		{"userName ignoring case", `filter=userName eq "ADA@example.com"`, 1, []string{"1"}},
+		{"externalId", `filter=externalId eq "c-1"`, 1, []string{"3"}},
+		{"deactivated", `filter=active eq false`, 1, []string{"2"}},
+		{"matched in memory and paged", `filter=displayName co "a" and active eq true&startIndex=2&count=1`, 3, []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(listUsers(), nil)
+			target := "/scim/v2/Users"
+			if tt.query != "" {
+				query, _ := url.ParseQuery(tt.query)
+				target += "?" + query.Encode()
+			}
+
+			rec := serve(h.ListUsers, newRequest(http.MethodGet, target, ""), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			var list struct {
+				TotalResults int    `json:"totalResults"`
+				ItemsPerPage int    `json:"itemsPerPage"`
+				Resources    []User `json:"Resources"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatalf("invalid list %s: %v", rec.Body, err)
+			}
+			ids := []string{}
+			for _, user := range list.Resources {
+				ids = append(ids, user.ID)
+			}
+			if list.TotalResults != tt.total || list.ItemsPerPage != len(ids) || fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+				t.Errorf("%d of %d users %v, want %d users %v", list.ItemsPerPage, list.TotalResults, ids, tt.total, tt.ids)
+			}
+		})
+	}
+}
+
+func TestListUsersInvalidFilter(t *testing.T) {
+	h := NewHandler(listUsers(), nil)
+
+	rec := serve(h.ListUsers, newRequest(http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(`userName eq`), ""), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d; body %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	var scimErr Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &scimErr); err != nil || scimErr.ScimType != "invalidFilter" {
+		t.Errorf("error %s, want scimType invalidFilter", rec.Body)
+	}
+}
+
+func patchBody(operations string) string {
+	return `{"schemas": ["` + SchemaPatchOp + `"], "Operations": ` + operations + `}`
+}
+
+func TestPatchUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		ifMatch    string
+		operations string
+		status     int
+		// The stored user afterwards
+		userName string
+		active   bool
+	}{
+		{"rename", "1", "", `[{"op": "replace", "path": "displayName", "value": "Ada Lovelace"}]`,
+			http.StatusOK, "Ada Lovelace", true},
+		{"rename by given and family name", "1", "", `[{"op": "replace", "value": {"name": {"givenName": "Ada", "familyName": "King"}}}]`,
+			http.StatusOK, "Ada King", true},
+		{"deactivate", "1", `"1"`, `[{"op": "replace", "path": "active", "value": false}]`,
+			http.StatusOK, "Ada", false},
+		{"deactivate with a string", "1", "", `[{"op": "replace", "path": "active", "value": "False"}]`,
+			http.StatusOK, "Ada", false},
+		{"reactivate and rename", "2", "", `[{"op": "replace", "value": {"active": true, "displayName": "Robert"}}]`,
+			http.StatusOK, "Robert", true},
+		{"rename while deactivated", "2", "", `[{"op": "replace", "path": "displayName", "value": "Robert"}]`,
+			http.StatusBadRequest, "Bob", false},
+		{"invalid userName", "1", "", `[{"op": "replace", "path": "userName", "value": "ada"}]`,
+			http.StatusBadRequest, "Ada", true},
+		{"stale version", "1", `"0"`, `[{"op": "replace", "path": "displayName", "value": "Ada Lovelace"}]`,
+			http.StatusPreconditionFailed, "Ada", true},
+		{"no operations", "1", "", `[]`, http.StatusBadRequest, "Ada", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := listUsers()
+			h := NewHandler(users, nil)
+			req := newRequest(http.MethodPatch, "/scim/v2/Users/"+tt.id, patchBody(tt.operations))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			rec := serve(h.PatchUser, req, map[string]string{"id": tt.id})
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			id, _ := strconv.ParseUint(tt.id, 10, 32)
+			stored := users.users[uint(id)]
+			if stored.Name != tt.userName || stored.DeletedAt.Valid == tt.active {
+				t.Errorf("user %q active %v, want %q active %v", stored.Name, !stored.DeletedAt.Valid, tt.userName, tt.active)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var resource User
+			if err := json.Unmarshal(rec.Body.Bytes(), &resource); err != nil {
+				t.Fatalf("invalid user %s: %v", rec.Body, err)
+			}
+			if resource.DisplayName != tt.userName || resource.Active == nil || *resource.Active != tt.active {
+				t.Errorf("resource %s, want %q active %v", rec.Body, tt.userName, tt.active)
+			}
+			if got := rec.Header().Get("ETag"); got != etag(stored.Version) {
+				t.Errorf("ETag %s, want %s", got, etag(stored.Version))
+			}
+		})
+	}
+}
+
+func TestPatchGroupMembers(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		status     int
+		members    []uint
+	}{
+		{"add", `[{"op": "add", "path": "members", "value": [{"value": "3"}]}]`, http.StatusOK, []uint{1, 2, 3}},
+		{"add an existing member", `[{"op": "add", "path": "members", "value": [{"value": "1"}]}]`, http.StatusOK, []uint{1, 2}},
+		{"remove by filter", `[{"op": "remove", "path": "members[value eq \"2\"]"}]`, http.StatusOK, []uint{1}},
+		{"remove all", `[{"op": "remove", "path": "members"}]`, http.StatusOK, []uint{}},
+		{"add an unknown user", `[{"op": "add", "path": "members", "value": [{"value": "99"}]}]`, http.StatusBadRequest, []uint{1, 2}},
+		{"add a non-numeric member", `[{"op": "add", "path": "members", "value": [{"value": "ada"}]}]`, http.StatusBadRequest, []uint{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := listUsers()
+			teams := newFakeTeamRepository(users, models.Team{ID: 1, Title: "Platform", Version: 1})
+			teams.members[1] = []uint{1, 2}
+			h := NewHandler(users, teams)
+
+			rec := serve(h.PatchGroup, newRequest(http.MethodPatch, "/scim/v2/Groups/1", patchBody(tt.operations)), map[string]string{"id": "1"})
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			members := append([]uint{}, teams.members[1]...)
+			if fmt.Sprint(members) != fmt.Sprint(tt.members) {
+				t.Errorf("members %v, want %v", members, tt.members)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var resource Group
+			if err := json.Unmarshal(rec.Body.Bytes(), &resource); err != nil {
+				t.Fatalf("invalid group %s: %v", rec.Body, err)
+			}
+			if len(resource.Members) != len(tt.members) || resource.DisplayName != "Platform" {
+				t.Errorf("group %s, want Platform with %d members", rec.Body, len(tt.members))
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// requestError is a client error reported with a SCIM scimType.
+type requestError struct {
+	scimType string
+	detail   string
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func badRequest(scimType, format string, args ...interface{}) error {
+	return &requestError{scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+// patchPath is attr, attr.sub, attr[filter] or attr[filter].sub.
+type patchPath struct {
+	attr   string
+	filter filter
+	sub    string
+}
+
+func parsePatchPath(path string) (*patchPath, error) {
+	path = stripSchema(strings.TrimSpace(path))
+	p := &patchPath{}
+	if open := strings.IndexByte(path, '['); open >= 0 {
+		end := strings.LastIndexByte(path, ']')
+		if end < open {
+			return nil, badRequest("invalidPath", "invalid path %q", path)
+		}
+		f, err := parseFilter(path[open+1 : end])
+		if err != nil {
+			return nil, badRequest("invalidPath", "invalid path %q: %v", path, err)
+		}
+		p.attr, p.filter = path[:open], f
+		rest := path[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return nil, badRequest("invalidPath", "invalid path %q", path)
+			}
+			p.sub = rest[1:]
+		}
+	} else if dot := strings.IndexByte(path, '.'); dot >= 0 {
+		p.attr, p.sub = path[:dot], path[dot+1:]
+	} else {
+		p.attr = path
+	}
+	if p.attr == "" || strings.Contains(p.sub, ".") {
+		return nil, badRequest("invalidPath", "invalid path %q", path)
+	}
+	return p, nil
+}
+
+// applyPatch applies the operations, in order, to a resource in its generic
+// JSON form.
+func applyPatch(resource map[string]interface{}, operations []PatchOperation) error {
+	for _, operation := range operations {
+		var err error
+		switch strings.ToLower(operation.Op) {
+		case "add":
+			err = patchSet(resource, operation, true)
+		case "replace":
+			err = patchSet(resource, operation, false)
+		case "remove":
+			err = patchRemove(resource, operation)
+		default:
+			err = badRequest("invalidSyntax", "unknown operation %q", operation.Op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchSet handles add and replace, which differ only for multi-valued
+// attributes: add appends to them, replace overwrites them.
+func patchSet(resource map[string]interface{}, operation PatchOperation, add bool) error {
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return badRequest("invalidValue", "%s without a path needs an object value", operation.Op)
+		}
+		for name, value := range values {
+			setAttribute(resource, stripSchema(name), value, add)
+		}
+		return nil
+	}
+
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return err
+	}
+	if path.filter == nil {
+		if path.sub == "" {
+			setAttribute(resource, path.attr, operation.Value, add)
+			return nil
+		}
+		object, _ := lookup(resource, path.attr).(map[string]interface{})
+		if object == nil {
+			object = map[string]interface{}{}
+			resource[key(resource, path.attr)] = object
+		}
+		object[key(object, path.sub)] = operation.Value
+		return nil
+	}
+
+	elements := matching(resource, path)
+	if len(elements) == 0 {
+		return badRequest("noTarget", "no values match %q", operation.Path)
+	}
+	for _, element := range elements {
+		if path.sub != "" {
+			element[key(element, path.sub)] = operation.Value
+			continue
+		}
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return badRequest("invalidValue", "%q needs an object value", operation.Path)
+		}
+		for name, value := range values {
+			element[key(element, name)] = value
+		}
+	}
+	return nil
+}
+
+func patchRemove(resource map[string]interface{}, operation PatchOperation) error {
+	if operation.Path == "" {
+		return badRequest("noTarget", "remove needs a path")
+	}
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return err
+	}
+	name := key(resource, path.attr)
+
+	if path.filter != nil {
+		var kept []interface{}
+		for _, element := range asList(resource[name]) {
+			object, ok := element.(map[string]interface{})
+			if !ok || !path.filter.match(object) {
+				kept = append(kept, element)
+				continue
+			}
+			if path.sub != "" {
+				delete(object, key(object, path.sub))
+				kept = append(kept, object)
+			}
+		}
+		resource[name] = emptyList(kept)
+		return nil
+	}
+	if path.sub != "" {
+		if object, ok := resource[name].(map[string]interface{}); ok {
+			delete(object, key(object, path.sub))
+		}
+		return nil
+	}
+
+	// Some providers name the members to remove in the value instead of a
+	// filter; honour that rather than clearing the attribute.
+	if list, ok := resource[name].([]interface{}); ok && operation.Value != nil {
+		remove := map[string]bool{}
+		for _, value := range asList(operation.Value) {
+			remove[memberValue(value)] = true
+		}
+		var kept []interface{}
+		for _, element := range list {
+			if !remove[memberValue(element)] {
+				kept = append(kept, element)
+			}
+		}
+		resource[name] = emptyList(kept)
+		return nil
+	}
+	delete(resource, name)
+	return nil
+}
+
+// setAttribute sets a top-level attribute. Adding to a multi-valued
+// attribute appends the values not already present; adding to a complex one
+// merges its sub-attributes.
+func setAttribute(resource map[string]interface{}, name string, value interface{}, add bool) {
+	name = key(resource, name)
+	existing := resource[name]
+	if list, ok := existing.([]interface{}); ok && add {
+		seen := map[string]bool{}
+		for _, element := range list {
+			seen[memberValue(element)] = true
+		}
+		for _, element := range asList(value) {
+			if v := memberValue(element); v == "" || !seen[v] {
+				list = append(list, element)
+				seen[v] = true
+			}
+		}
+		resource[name] = list
+		return
+	}
+	if object, ok := existing.(map[string]interface{}); ok {
+		if values, ok := value.(map[string]interface{}); ok {
+			for sub, v := range values {
+				object[key(object, sub)] = v
+			}
+			return
+		}
+	}
+	resource[name] = value
+}
+
+func matching(resource map[string]interface{}, path *patchPath) []map[string]interface{} {
+	var elements []map[string]interface{}
+	for _, element := range asList(lookup(resource, path.attr)) {
+		if object, ok := element.(map[string]interface{}); ok && path.filter.match(object) {
+			elements = append(elements, object)
+		}
+	}
+	return elements
+}
+
+// key returns the existing attribute name matching name ignoring case, or
+// name itself.
+func key(object map[string]interface{}, name string) string {
+	if _, ok := object[name]; ok {
+		return name
+	}
+	for existing := range object {
+		if strings.EqualFold(existing, name) {
+			return existing
+		}
+	}
+	return name
+}
+
+// memberValue identifies an element of a multi-valued attribute by its value.
+func memberValue(element interface{}) string {
+	if object, ok := element.(map[string]interface{}); ok {
+		element = lookup(object, "value")
+	}
+	if s, ok := element.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func emptyList(list []interface{}) []interface{} {
+	if list == nil {
+		return []interface{}{}
+	}
+	return list
+}
@@ -0,0 +1,180 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Based on the examples of RFC 7644 section 3.5.2 and on what identity
+// providers send
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		resource   string
+		operations string
+		want       string
+	}{
+		{
+			name:       "add a member",
+			resource:   `{"members": [{"value": "1"}]}`,
+			operations: `[{"op": "add", "path": "members", "value": [{"display": "Babs Jensen", "value": "2"}]}]`,
+			want:       `{"members": [{"value": "1"}, {"display": "Babs Jensen", "value": "2"}]}`,
+		},
+		{
+			name:       "add an existing member",
+			resource:   `{"members": [{"value": "1"}]}`,
+			operations: `[{"op": "add", "path": "members", "value": [{"value": "1"}]}]`,
+			want:       `{"members": [{"value": "1"}]}`,
+		},
+		{
+			name:     "add without a path",
+			resource: `{"emails": [{"value": "bjensen@example.com", "type": "work"}]}`,
+			operations: `[{"op": "add", "value": {
+				"emails": [{"value": "babs@jensen.org", "type": "home"}], "nickname": "Babs"}}]`,
+			want: `{"emails": [{"value": "bjensen@example.com", "type": "work"},
+				{"value": "babs@jensen.org", "type": "home"}], "nickname": "Babs"}`,
+		},
+		{
+			name:       "remove all members",
+			resource:   `{"displayName": "Ops", "members": [{"value": "1"}, {"value": "2"}]}`,
+			operations: `[{"op": "remove", "path": "members"}]`,
+			want:       `{"displayName": "Ops"}`,
+		},
+		{
+			name:       "remove a member by filter",
+			resource:   `{"members": [{"value": "1"}, {"value": "2"}]}`,
+			operations: `[{"op": "remove", "path": "members[value eq \"2\"]"}]`,
+			want:       `{"members": [{"value": "1"}]}`,
+		},
+		{
+			name:       "remove the last member by filter",
+			resource:   `{"members": [{"value": "1"}]}`,
+			operations: `[{"op": "remove", "path": "members[value eq \"1\"]"}]`,
+			want:       `{"members": []}`,
+		},
+		{
+			name:       "remove members named in the value",
+			resource:   `{"members": [{"value": "1"}, {"value": "2"}, {"value": "3"}]}`,
+			operations: `[{"op": "remove", "path": "members", "value": [{"value": "1"}, {"value": "3"}]}]`,
+			want:       `{"members": [{"value": "2"}]}`,
+		},
+		{
+			name:       "remove a sub-attribute by filter",
+			resource:   `{"emails": [{"value": "a@example.com", "type": "work", "primary": true}]}`,
+			operations: `[{"op": "remove", "path": "emails[type eq \"work\"].primary"}]`,
+			want:       `{"emails": [{"value": "a@example.com", "type": "work"}]}`,
+		},
+		{
+			name:       "remove a complex sub-attribute",
+			resource:   `{"name": {"givenName": "Barbara", "familyName": "Jensen"}}`,
+			operations: `[{"op": "remove", "path": "name.givenName"}]`,
+			want:       `{"name": {"familyName": "Jensen"}}`,
+		},
+		{
+			name:       "replace all members",
+			resource:   `{"members": [{"value": "1"}, {"value": "2"}]}`,
+			operations: `[{"op": "replace", "path": "members", "value": [{"value": "3"}]}]`,
+			want:       `{"members": [{"value": "3"}]}`,
+		},
+		{
+			name:       "replace a value by filter",
+			resource:   `{"emails": [{"value": "a@example.com", "type": "work"}, {"value": "b@example.org", "type": "home"}]}`,
+			operations: `[{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "c@example.com"}]`,
+			want:       `{"emails": [{"value": "c@example.com", "type": "work"}, {"value": "b@example.org", "type": "home"}]}`,
+		},
+		{
+			name:       "replace matching elements",
+			resource:   `{"emails": [{"value": "a@example.com", "type": "work"}]}`,
+			operations: `[{"op": "replace", "path": "emails[type eq \"work\"]", "value": {"value": "c@example.com", "primary": true}}]`,
+			want:       `{"emails": [{"value": "c@example.com", "type": "work", "primary": true}]}`,
+		},
+		{
+			name:       "replace a sub-attribute",
+			resource:   `{"name": {"givenName": "Barbara", "familyName": "Jensen"}}`,
+			operations: `[{"op": "replace", "path": "name.familyName", "value": "O'Malley"}]`,
+			want:       `{"name": {"givenName": "Barbara", "familyName": "O'Malley"}}`,
+		},
+		{
+			name:       "replace without a path merges complex attributes",
+			resource:   `{"name": {"givenName": "Barbara", "familyName": "Jensen"}, "active": true}`,
+			operations: `[{"op": "replace", "value": {"name": {"familyName": "O'Malley"}, "active": false}}]`,
+			want:       `{"name": {"givenName": "Barbara", "familyName": "O'Malley"}, "active": false}`,
+		},
+		{
+			name:       "names and ops ignore case",
+			resource:   `{"displayName": "Ops"}`,
+			operations: `[{"op": "Replace", "path": "DISPLAYNAME", "value": "Dev"}]`,
+			want:       `{"displayName": "Dev"}`,
+		},
+		{
+			name:       "schema-qualified path",
+			resource:   `{"displayName": "Ops"}`,
+			operations: `[{"op": "replace", "path": "urn:ietf:params:scim:schemas:core:2.0:Group:displayName", "value": "Dev"}]`,
+			want:       `{"displayName": "Dev"}`,
+		},
+		{
+			name:     "operations apply in order",
+			resource: `{"members": [{"value": "1"}]}`,
+			operations: `[{"op": "remove", "path": "members"},
+				{"op": "add", "path": "members", "value": [{"value": "2"}]}]`,
+			want: `{"members": [{"value": "2"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := decodeObject(t, tt.resource)
+			if err := applyPatch(resource, decodeOperations(t, tt.operations)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := decodeObject(t, tt.want); !reflect.DeepEqual(resource, want) {
+				got, _ := json.Marshal(resource)
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchMalformed(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		scimType   string
+	}{
+		{"unknown op", `[{"op": "move", "path": "displayName"}]`, "invalidSyntax"},
+		{"missing op", `[{"path": "displayName", "value": "x"}]`, "invalidSyntax"},
+		{"remove without a path", `[{"op": "remove"}]`, "noTarget"},
+		{"add without a path or object", `[{"op": "add", "value": "x"}]`, "invalidValue"},
+		{"filter matching nothing", `[{"op": "replace", "path": "emails[type eq \"home\"].value", "value": "x"}]`, "noTarget"},
+		{"filter target without an object", `[{"op": "replace", "path": "emails[type eq \"work\"]", "value": "x"}]`, "invalidValue"},
+		{"unterminated filter", `[{"op": "replace", "path": "emails[type eq \"work\"", "value": "x"}]`, "invalidPath"},
+		{"invalid filter", `[{"op": "remove", "path": "emails[type foo \"work\"]"}]`, "invalidPath"},
+		{"filter without an attribute", `[{"op": "remove", "path": "[type eq \"work\"]"}]`, "invalidPath"},
+		{"text after a filter", `[{"op": "remove", "path": "emails[type eq \"work\"]value"}]`, "invalidPath"},
+		{"nested sub-attribute", `[{"op": "replace", "path": "name.givenName.first", "value": "x"}]`, "invalidPath"},
+		{"empty attribute", `[{"op": "replace", "path": ".givenName", "value": "x"}]`, "invalidPath"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := decodeObject(t, `{"emails": [{"value": "a@example.com", "type": "work"}]}`)
+			err := applyPatch(resource, decodeOperations(t, tt.operations))
+			var reqErr *requestError
+			if !errors.As(err, &reqErr) {
+				t.Fatalf("expected a request error, got %v", err)
+			}
+			if reqErr.scimType != tt.scimType {
+				t.Errorf("scimType = %q, want %q", reqErr.scimType, tt.scimType)
+			}
+		})
+	}
+}
+
+func decodeOperations(t *testing.T, data string) []PatchOperation {
+	t.Helper()
+	var operations []PatchOperation
+	if err := json.Unmarshal([]byte(data), &operations); err != nil {
+		t.Fatalf("invalid operations %s: %v", data, err)
+	}
+	return operations
+}
@@ -0,0 +1,216 @@
+// Package scim serves SCIM 2.0 (RFC 7643, RFC 7644) provisioning endpoints
+// for identity providers, mapping Users onto models.User and Groups onto
+// models.Team with their members in team_users. All writes go through the
+// regular repositories, so they are versioned, audited and published like
+// any other change.
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-sample/internal/models"
+)
+
+const ContentType = "application/scim+json"
+
+// Schema URNs
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ExternalSource is the models source recorded with a SCIM externalId.
+const ExternalSource = "scim"
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Groups      []MultiValue `json:"groups,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// Error is the SCIM error response body. Status is a string per RFC 7644.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// newUser renders a user. baseURL is the /scim/v2 URL the resource and its
+// groups are located under.
+func newUser(user *models.User, baseURL string) *User {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := !user.DeletedAt.Valid
+	resource := &User{
+		Schemas:     []string{SchemaUser},
+		ID:          id,
+		UserName:    user.Email,
+		Name:        &Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []MultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     baseURL + "/Users/" + id,
+			Version:      etag(user.Version),
+		},
+	}
+	if user.Source == ExternalSource {
+		resource.ExternalID = user.ExternalID
+	}
+	for _, team := range user.Teams {
+		teamID := strconv.FormatUint(uint64(team.ID), 10)
+		resource.Groups = append(resource.Groups, MultiValue{
+			Value:   teamID,
+			Display: team.Title,
+			Ref:     baseURL + "/Groups/" + teamID,
+		})
+	}
+	return resource
+}
+
+func newGroup(team *models.Team, baseURL string) *Group {
+	id := strconv.FormatUint(uint64(team.ID), 10)
+	resource := &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          id,
+		DisplayName: team.Title,
+		Members:     make([]MultiValue, 0, len(team.Users)),
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      team.CreatedAt,
+			LastModified: team.UpdatedAt,
+			Location:     baseURL + "/Groups/" + id,
+			Version:      etag(team.Version),
+		},
+	}
+	if team.Source == ExternalSource {
+		resource.ExternalID = team.ExternalID
+	}
+	for _, user := range team.Users {
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+		resource.Members = append(resource.Members, MultiValue{
+			Value:   userID,
+			Display: user.Email,
+			Ref:     baseURL + "/Users/" + userID,
+		})
+	}
+	return resource
+}
+
+// displayName picks the user's name from the most specific attribute set.
+func (u *User) displayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+		if full := joinNonEmpty(u.Name.GivenName, u.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	return u.UserName
+}
+
+// preferChangedName makes displayName() pick whichever name attribute a
+// patch changed; the rendered user repeats the name in several attributes.
+func (u *User) preferChangedName(before *User) {
+	if u.DisplayName != before.DisplayName {
+		return
+	}
+	u.DisplayName = ""
+	if u.Name != nil && u.Name.Formatted == before.Name.Formatted &&
+		(u.Name.GivenName != "" || u.Name.FamilyName != "") {
+		u.Name.Formatted = ""
+	}
+}
+
+// memberIDs parses the member values as user IDs.
+func (g *Group) memberIDs() ([]uint, error) {
+	ids := make([]uint, 0, len(g.Members))
+	for _, member := range g.Members {
+		id, err := strconv.ParseUint(member.Value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("member %q is not a user id", member.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func joinNonEmpty(parts ...string) string {
+	joined := ""
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if joined != "" {
+			joined += " "
+		}
+		joined += part
+	}
+	return joined
+}
+
+// etag matches the strong ETags of the JSON API, so If-Match works the same.
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
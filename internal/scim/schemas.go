@@ -0,0 +1,147 @@
+package scim
+
+// maxResults caps the count of a single list request.
+const maxResults = 1000
+
+type attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []attribute `json:"subAttributes,omitempty"`
+}
+
+type schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []attribute `json:"attributes"`
+	Meta        schemaMeta  `json:"meta"`
+}
+
+type schemaMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type resourceType struct {
+	Schemas     []string   `json:"schemas"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Endpoint    string     `json:"endpoint"`
+	Description string     `json:"description"`
+	Schema      string     `json:"schema"`
+	Meta        schemaMeta `json:"meta"`
+}
+
+func simple(name, typ, mutability string, required bool) attribute {
+	return attribute{Name: name, Type: typ, Required: required, Mutability: mutability, Returned: "default", Uniqueness: "none"}
+}
+
+// reference describes the members and groups multi-valued attributes.
+func reference(name, mutability string) attribute {
+	return attribute{
+		Name: name, Type: "complex", MultiValued: true, Mutability: mutability, Returned: "default", Uniqueness: "none",
+		SubAttributes: []attribute{
+			simple("value", "string", "immutable", false),
+			simple("display", "string", "readOnly", false),
+			simple("$ref", "reference", "immutable", false),
+		},
+	}
+}
+
+func userSchema(baseURL string) schema {
+	userName := simple("userName", "string", "readWrite", true)
+	userName.Uniqueness = "server"
+	return schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          SchemaUser,
+		Name:        "User",
+		Description: "User account; userName is the user's email address",
+		Attributes: []attribute{
+			userName,
+			{
+				Name: "name", Type: "complex", Mutability: "readWrite", Returned: "default", Uniqueness: "none",
+				SubAttributes: []attribute{
+					simple("formatted", "string", "readWrite", false),
+					simple("givenName", "string", "writeOnly", false),
+					simple("familyName", "string", "writeOnly", false),
+				},
+			},
+			simple("displayName", "string", "readWrite", false),
+			simple("externalId", "string", "readWrite", false),
+			simple("active", "boolean", "readWrite", false),
+			{
+				Name: "emails", Type: "complex", MultiValued: true, Mutability: "readOnly", Returned: "default", Uniqueness: "none",
+				SubAttributes: []attribute{
+					simple("value", "string", "readOnly", false),
+					simple("type", "string", "readOnly", false),
+					simple("primary", "boolean", "readOnly", false),
+				},
+			},
+			reference("groups", "readOnly"),
+		},
+		Meta: schemaMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + SchemaUser},
+	}
+}
+
+func groupSchema(baseURL string) schema {
+	return schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          SchemaGroup,
+		Name:        "Group",
+		Description: "Team; members are its users",
+		Attributes: []attribute{
+			simple("displayName", "string", "readWrite", true),
+			simple("externalId", "string", "readWrite", false),
+			reference("members", "readWrite"),
+		},
+		Meta: schemaMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + SchemaGroup},
+	}
+}
+
+func schemas(baseURL string) []schema {
+	return []schema{userSchema(baseURL), groupSchema(baseURL)}
+}
+
+func resourceTypes(baseURL string) []resourceType {
+	return []resourceType{
+		{
+			Schemas: []string{SchemaResourceType}, ID: "User", Name: "User", Endpoint: "/Users",
+			Description: "User account", Schema: SchemaUser,
+			Meta: schemaMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/User"},
+		},
+		{
+			Schemas: []string{SchemaResourceType}, ID: "Group", Name: "Group", Endpoint: "/Groups",
+			Description: "Team", Schema: SchemaGroup,
+			Meta: schemaMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+func serviceProviderConfig(baseURL string) map[string]interface{} {
+	supported := func(ok bool) map[string]interface{} {
+		return map[string]interface{}{"supported": ok}
+	}
+	return map[string]interface{}{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "An admin API key sent as a bearer token or in X-API-Key",
+			"primary":     true,
+		}},
+		"meta": schemaMeta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
@@ -0,0 +1,184 @@
+package seed
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Options controls the size and shape of a generated dataset.
+type Options struct {
+	Users int
+	Teams int
+	// Seed makes generation deterministic: the same options always produce
+	// the same dataset
+	Seed int64
+	// MedianTeamSize is the typical number of members; sizes are log-normally
+	// distributed around it, so most teams are small and a few are large
+	MedianTeamSize int
+}
+
+// DefaultOptions returns a small dataset suitable for local development.
+func DefaultOptions() Options {
+	return Options{
+		Users:          1000,
+		Teams:          100,
+		Seed:           1,
+		MedianTeamSize: 8,
+	}
+}
+
+type User struct {
+	Email string
+	Name  string
+}
+
+type Team struct {
+	Title       string
+	Description string
+	// Members are indexes into Dataset.Users, in ascending order
+	Members []int
+}
+
+type Dataset struct {
+	Users []User
+	Teams []Team
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Barbara", "Brian", "Carol", "Dennis", "Edsger", "Frances",
+		"Grace", "Hedy", "Ivan", "Jean", "Ken", "Linus", "Margaret", "Niklaus", "Olga", "Radia",
+		"Rob", "Shafi", "Sophie", "Tim", "Whitfield", "Yukihiro"}
+	lastNames = []string{"Allen", "Backus", "Cerf", "Dijkstra", "Engelbart", "Floyd", "Goldberg",
+		"Hamilton", "Hopper", "Kay", "Knuth", "Lamport", "Liskov", "Lovelace", "McCarthy", "Perlman",
+		"Pike", "Ritchie", "Stroustrup", "Thompson", "Torvalds", "Turing", "Wilson", "Wirth"}
+	teamAdjectives = []string{"Agile", "Blue", "Bright", "Crimson", "Golden", "Iron", "Quiet",
+		"Rapid", "Silver", "Steady", "Swift", "Wild"}
+	teamNouns = []string{"Badgers", "Comets", "Falcons", "Foxes", "Herons", "Lynxes", "Orcas",
+		"Otters", "Owls", "Pandas", "Ravens", "Wolves"}
+	teamAreas = []string{"billing", "data platform", "developer tooling", "growth", "identity",
+		"infrastructure", "mobile", "payments", "search", "support"}
+)
+
+// Generate builds a dataset from opts. Emails and titles carry the record's
+// index so they stay unique at any size.
+func Generate(opts Options) Dataset {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	dataset := Dataset{
+		Users: make([]User, opts.Users),
+		Teams: make([]Team, opts.Teams),
+	}
+
+	for i := range dataset.Users {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+		dataset.Users[i] = User{
+			Email: fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1),
+			Name:  first + " " + last,
+		}
+	}
+
+	median := opts.MedianTeamSize
+	if median < 1 {
+		median = 1
+	}
+	for i := range dataset.Teams {
+		area := teamAreas[rng.Intn(len(teamAreas))]
+		dataset.Teams[i] = Team{
+			Title: fmt.Sprintf("%s %s %d", teamAdjectives[rng.Intn(len(teamAdjectives))],
+				teamNouns[rng.Intn(len(teamNouns))], i+1),
+			Description: fmt.Sprintf("Owns %s", area),
+			Members:     pickMembers(rng, teamSize(rng, median, opts.Users), opts.Users),
+		}
+	}
+	return dataset
+}
+
+// teamSize draws a log-normal size around median, clamped to [1, users].
+func teamSize(rng *rand.Rand, median, users int) int {
+	if users == 0 {
+		return 0
+	}
+	size := int(math.Round(float64(median) * math.Exp(rng.NormFloat64()*0.75)))
+	if size < 1 {
+		size = 1
+	}
+	if size > users {
+		size = users
+	}
+	return size
+}
+
+// pickMembers chooses size distinct users. Low indexes are favoured, so some
+// users belong to many teams and others to none.
+func pickMembers(rng *rand.Rand, size, users int) []int {
+	if size == 0 {
+		return nil
+	}
+	zipf := rand.NewZipf(rng, 1.1, 10, uint64(users-1))
+	picked := make(map[int]bool, size)
+	for len(picked) < size {
+		// Fall back to uniform picks when the skewed draw keeps colliding
+		candidate := int(zipf.Uint64())
+		if picked[candidate] {
+			candidate = rng.Intn(users)
+		}
+		picked[candidate] = true
+	}
+
+	members := make([]int, 0, size)
+	for index := range picked {
+		members = append(members, index)
+	}
+	sort.Ints(members)
+	return members
+}
+
+// MembershipCount is the total number of team memberships.
+func (d Dataset) MembershipCount() int {
+	count := 0
+	for _, team := range d.Teams {
+		count += len(team.Members)
+	}
+	return count
+}
+
+// WriteUsersCSV writes the users in the format POST /api/import accepts.
+func (d Dataset) WriteUsersCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"email", "name"})
+	for _, user := range d.Users {
+		writer.Write([]string{user.Email, user.Name})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTeamsCSV writes the teams in the format POST /api/import accepts.
+func (d Dataset) WriteTeamsCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"title", "description"})
+	for _, team := range d.Teams {
+		writer.Write([]string{team.Title, team.Description})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMembershipsCSV writes one team_title,user_email row per membership.
+// The importer does not read it; it documents the generated distribution.
+func (d Dataset) WriteMembershipsCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"team_title", "user_email"})
+	for _, team := range d.Teams {
+		for _, member := range team.Members {
+			writer.Write([]string{team.Title, d.Users[member].Email})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
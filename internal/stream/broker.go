@@ -0,0 +1,194 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Event is a change event read from the shared Redis stream. ID is the Redis
+// stream entry ID, which is monotonic across replicas and used as the SSE id.
+type Event struct {
+	ID         string          `json:"-"`
+	EventID    string          `json:"event_id"`
+	Type       string          `json:"type"`
+	OccurredAt string          `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Subscription receives live events until it is closed. The channel is
+// closed if the subscriber falls too far behind; clients resume with
+// Last-Event-ID.
+type Subscription struct {
+	Events chan Event
+}
+
+// Broker tails the Redis event stream written by the outbox relay and fans
+// events out to local subscribers. Every replica runs its own broker, so
+// clients see the same events whichever replica they are connected to.
+type Broker struct {
+	client      *redis.Client
+	stream      string
+	historySize int64
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker(client *redis.Client, stream string, historySize int64) *Broker {
+	return &Broker{
+		client:      client,
+		stream:      stream,
+		historySize: historySize,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe() *Subscription {
+	sub := &Subscription{Events: make(chan Event, 256)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.Events)
+	}
+}
+
+// History returns up to historySize events recorded after afterID.
+func (b *Broker) History(ctx context.Context, afterID string) ([]Event, error) {
+	messages, err := b.client.XRangeN(ctx, b.stream, afterID, "+", b.historySize).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(messages))
+	for _, msg := range messages {
+		if msg.ID == afterID {
+			continue
+		}
+		events = append(events, toEvent(msg))
+	}
+	return events, nil
+}
+
+// Run tails the stream until ctx is cancelled.
+func (b *Broker) Run(ctx context.Context) {
+	lastID := b.latestID(ctx)
+
+	for ctx.Err() == nil {
+		streams, err := b.client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{b.stream, lastID},
+			Count:   100,
+			Block:   5 * time.Second,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				log.Printf("Event stream: read failed: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				b.broadcast(toEvent(msg))
+				lastID = msg.ID
+			}
+		}
+	}
+}
+
+func (b *Broker) latestID(ctx context.Context) string {
+	messages, err := b.client.XRevRangeN(ctx, b.stream, "+", "-", 1).Result()
+	if err != nil || len(messages) == 0 {
+		return "0-0"
+	}
+	return messages[0].ID
+}
+
+func (b *Broker) broadcast(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.Events <- event:
+		default:
+			// Too slow; drop it so the client reconnects and resumes
+			delete(b.subs, sub)
+			close(sub.Events)
+		}
+	}
+}
+
+func toEvent(msg redis.XMessage) Event {
+	event := Event{ID: msg.ID}
+	if v, ok := msg.Values["id"].(string); ok {
+		event.EventID = v
+	}
+	if v, ok := msg.Values["type"].(string); ok {
+		event.Type = v
+	}
+	if v, ok := msg.Values["occurred_at"].(string); ok {
+		event.OccurredAt = v
+	}
+	if v, ok := msg.Values["data"].(string); ok {
+		event.Data = json.RawMessage(v)
+	}
+	return event
+}
+
+// CompareIDs orders two Redis stream IDs ("<ms>-<seq>"), returning -1, 0 or 1.
+func CompareIDs(a, b string) int {
+	aMs, aSeq := splitID(a)
+	bMs, bSeq := splitID(b)
+
+	switch {
+	case aMs < bMs:
+		return -1
+	case aMs > bMs:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+// ValidID reports whether id is a well-formed Redis stream ID.
+func ValidID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(seq, 10, 64)
+	return err == nil
+}
+
+func splitID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msValue, _ := strconv.ParseUint(ms, 10, 64)
+	seqValue, _ := strconv.ParseUint(seq, 10, 64)
+	return msValue, seqValue
+}
@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes why a single field of a request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every invalid field of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fieldErr := range e {
+		parts[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add appends an error for field.
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Struct checks the exported fields of the struct v points to against their
+// `validate` tags and returns every violation, in field order. Supported rules
+// are required, email, min=N and max=N; lengths are counted in characters.
+// Rules other than required skip nil pointers and check what others point
+// to. Fields are reported by their JSON name. If v implements Validator, its
+// errors for rules spanning several fields follow.
+func Struct(v interface{}) Errors {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: %T is not a struct", v))
+	}
+
+	var errs Errors
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		name := FieldName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if message := check(value.Field(i), rule); message != "" {
+				errs.Add(name, message)
+				// Report only the first broken rule per field
+				break
+			}
+		}
+	}
+	if validator, ok := v.(Validator); ok {
+		errs = append(errs, validator.Validate()...)
+	}
+	return errs
+}
+
+// Validator is implemented by requests with rules spanning several fields.
+type Validator interface {
+	Validate() Errors
+}
+
+// FieldName is the name a struct field has in JSON.
+func FieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func check(value reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	if value.Kind() == reflect.Ptr && name != "required" {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch name {
+	case "required":
+		if isBlank(value) {
+			return "is required"
+		}
+	case "email":
+		if value.Kind() == reflect.String && value.String() != "" && !isEmail(value.String()) {
+			return "must be a valid email address"
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validation: invalid rule %q", rule))
+		}
+		n, unit := size(value)
+		if name == "min" && n < limit {
+			return fmt.Sprintf("must be at least %d %s", limit, unit)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("must be at most %d %s", limit, unit)
+		}
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", rule))
+	}
+	return ""
+}
+
+func isBlank(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	default:
+		return value.IsZero()
+	}
+}
+
+func size(value reflect.Value) (int, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), "characters"
+	case reflect.Slice, reflect.Map:
+		return value.Len(), "items"
+	default:
+		panic(fmt.Sprintf("validation: length rule on %s", value.Kind()))
+	}
+}
+
+// isEmail accepts a bare address such as "jane@example.com", without a
+// display name or angle brackets.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// publicIP reports whether ip may receive deliveries: anything but loopback,
+// private, link-local, multicast and unspecified addresses, which would let
+// subscribers reach services inside the network.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast()
+}
+
+// checkHost rejects hosts that are, or are named as, non-public addresses.
+// Other names are checked once resolved, when connecting.
+func checkHost(host string) error {
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return fmt.Errorf("host %s is a loopback address", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return fmt.Errorf("host %s is a loopback, private or link-local address", host)
+	}
+	return nil
+}
+
+// CheckURL reports why rawURL may not be subscribed, if it may not. Unless
+// AllowPrivateHosts is set, its host must not be a non-public address.
+func (d *Dispatcher) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) URL")
+	}
+	if d.options.AllowPrivateHosts {
+		return nil
+	}
+	return checkHost(u.Hostname())
+}
+
+// NewClient returns an HTTP client that refuses to connect to non-public
+// addresses. The check runs on the resolved address of every connection, so
+// it also covers names resolving inside the network and redirects.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, unchecked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go-sample/internal/models"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://hooks.example.com/in", true},
+		{"http://93.184.216.34:8080/in", true},
+		{"http://[2606:2800:220:1::]/in", true},
+		{"ftp://hooks.example.com/in", false},
+		{"/in", false},
+		{"http://localhost/in", false},
+		{"http://api.LOCALHOST./in", false},
+		{"http://127.0.0.1/in", false},
+		{"http://[::1]/in", false},
+		{"http://10.1.2.3/in", false},
+		{"http://172.16.0.1/in", false},
+		{"http://192.168.1.1/in", false},
+		{"http://[fd00::1]/in", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/in", false},
+		{"http://0.0.0.0/in", false},
+		{"http://[::ffff:127.0.0.1]/in", false},
+	}
+	d := NewDispatcher(newFakeWebhookRepository(), nil, testOptions())
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if err := d.CheckURL(tt.url); (err == nil) != tt.allowed {
+				t.Errorf("CheckURL = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestCheckURLAllowPrivateHosts(t *testing.T) {
+	options := testOptions()
+	options.AllowPrivateHosts = true
+	d := NewDispatcher(newFakeWebhookRepository(), nil, options)
+	if err := d.CheckURL("http://127.0.0.1:8080/in"); err != nil {
+		t.Errorf("CheckURL = %v, want allowed", err)
+	}
+	if err := d.CheckURL("ftp://127.0.0.1/in"); err == nil {
+		t.Error("CheckURL allowed a non-http URL")
+	}
+}
+
+// Names resolving to private addresses, redirects and subscriptions made
+// before the check all end up connecting to one
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	d, repo, _ := setup(t, rcv, nil)
+
+	d.deliverDue(context.Background())
+
+	if rcv.count() != 0 {
+		t.Fatalf("delivered to %s", rcv.URL)
+	}
+	if failed := delivery(t, repo); failed.Status != models.DeliveryStatusPending || failed.LastError == "" {
+		t.Errorf("delivery %+v, want pending with an error", failed)
+	}
+}
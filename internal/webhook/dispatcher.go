@@ -0,0 +1,368 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+)
+
+type Options struct {
+	// MaxAttempts before a delivery is marked failed
+	MaxAttempts int
+	// BaseBackoff is the delay after the first failure; it doubles per attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// PollInterval is how often due deliveries are picked up
+	PollInterval time.Duration
+	// BatchSize is the number of deliveries claimed per poll
+	BatchSize int
+	// Concurrency is the number of deliveries of a batch sent at once
+	Concurrency int
+	// AllowPrivateHosts permits subscribing and delivering to loopback,
+	// private and link-local addresses, for development
+	AllowPrivateHosts bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxAttempts:  8,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   time.Hour,
+		PollInterval: 2 * time.Second,
+		BatchSize:    50,
+		Concurrency:  10,
+	}
+}
+
+// Dispatcher turns published events into persisted deliveries and sends them
+// to subscribers, retrying failures with exponential backoff.
+type Dispatcher struct {
+	repo    repository.WebhookRepository
+	client  *http.Client
+	options Options
+	wake    chan struct{}
+	// Bound on each attempt, so claimed deliveries are done within the lease
+	timeout time.Duration
+
+	mu         sync.Mutex
+	subs       []models.WebhookSubscription
+	subsLoaded time.Time
+}
+
+// NewDispatcher sends deliveries with client. Without one it uses a client
+// that only connects to public addresses, unless AllowPrivateHosts is set.
+func NewDispatcher(repo repository.WebhookRepository, client *http.Client, options Options) *Dispatcher {
+	if client == nil && options.AllowPrivateHosts {
+		client = &http.Client{Timeout: 10 * time.Second}
+	} else if client == nil {
+		client = NewClient(10 * time.Second)
+	}
+	timeout := client.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	return &Dispatcher{
+		repo:    repo,
+		client:  client,
+		options: options,
+		wake:    make(chan struct{}, 1),
+		timeout: timeout,
+	}
+}
+
+// Enqueue records a pending delivery for every active subscription that wants
+// the event. Delivery happens asynchronously in Run. Enqueueing the same event
+// again skips subscriptions that already have a delivery for it.
+func (d *Dispatcher) Enqueue(event events.Event) error {
+	subs, err := d.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.repo.DeliveredSubscriptionIDs(event.ID)
+	if err != nil {
+		return err
+	}
+	queued := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		queued[id] = true
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, sub := range subs {
+		if !sub.Active || queued[sub.ID] || !subscribed(sub, event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			Event:          event.Type,
+			Payload:        models.JSON(payload),
+			Status:         models.DeliveryStatusPending,
+			NextAttemptAt:  now,
+		})
+	}
+
+	if err := d.repo.CreateDeliveries(deliveries); err != nil {
+		return err
+	}
+	if len(deliveries) > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Redeliver queues a fresh copy of an existing delivery.
+func (d *Dispatcher) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	original, err := d.repo.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         models.DeliveryStatusPending,
+		NextAttemptAt:  time.Now(),
+	}}
+	if err := d.repo.CreateDeliveries(deliveries); err != nil {
+		return nil, err
+	}
+	d.Wake()
+	return &deliveries[0], nil
+}
+
+// InvalidateSubscriptions drops the cached subscription list.
+func (d *Dispatcher) InvalidateSubscriptions() {
+	d.mu.Lock()
+	d.subsLoaded = time.Time{}
+	d.mu.Unlock()
+}
+
+// Wake triggers an immediate poll instead of waiting for the next tick.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due webhooks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for {
+		lease := d.lease()
+		claimed := time.Now()
+		deliveries, err := d.repo.ClaimDueDeliveries(d.options.BatchSize, lease)
+		if err != nil {
+			log.Printf("Webhook: failed to claim deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		// Only start attempts that can finish before the lease runs out and
+		// another replica may claim the delivery; skipped ones are due again
+		// once it has
+		deadline := claimed.Add(lease - d.timeout)
+		workers := make(chan struct{}, d.options.Concurrency)
+		var wg sync.WaitGroup
+		stopped := false
+		for i := range deliveries {
+			workers <- struct{}{}
+			if ctx.Err() != nil || time.Now().After(deadline) {
+				<-workers
+				stopped = true
+				break
+			}
+			wg.Add(1)
+			go func(delivery *models.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				d.attempt(ctx, delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+		if stopped {
+			return
+		}
+	}
+}
+
+// lease is how long claimed deliveries are reserved: long enough to send the
+// whole batch, Concurrency at a time, with every attempt taking the full
+// timeout, plus a margin for the database round trips.
+func (d *Dispatcher) lease() time.Duration {
+	rounds := (d.options.BatchSize + d.options.Concurrency - 1) / d.options.Concurrency
+	return time.Duration(rounds)*d.timeout + time.Minute
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	sub, err := d.repo.GetSubscription(delivery.SubscriptionID)
+	if err != nil {
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.LastError = fmt.Sprintf("subscription unavailable: %v", err)
+		d.save(delivery)
+		return
+	}
+
+	delivery.Attempts++
+	statusCode, err := d.send(ctx, sub, delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		d.save(delivery)
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.options.MaxAttempts {
+		delivery.Status = models.DeliveryStatusFailed
+	} else {
+		delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+	}
+	d.save(delivery)
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.options.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.options.MaxBackoff {
+			return d.options.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) save(delivery *models.WebhookDelivery) {
+	if err := d.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Webhook: failed to update delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// subscriptions returns the subscription list, reloading it at most every 10s
+// so bulk imports do not query it once per row.
+func (d *Dispatcher) subscriptions() ([]models.WebhookSubscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if time.Since(d.subsLoaded) < 10*time.Second {
+		return d.subs, nil
+	}
+
+	subs, err := d.repo.ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	d.subs = subs
+	d.subsLoaded = time.Now()
+	return subs, nil
+}
+
+func subscribed(sub models.WebhookSubscription, eventType string) bool {
+	for _, e := range sub.Events {
+		if e == events.Wildcard || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature header value for a payload: the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign. Receivers can use it directly.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
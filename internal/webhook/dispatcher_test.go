@@ -0,0 +1,361 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go-sample/internal/events"
+	"go-sample/internal/models"
+	"go-sample/internal/repository"
+)
+
+// fakeWebhookRepository keeps subscriptions and deliveries in memory. Methods
+// the dispatcher does not use panic through the nil embedded interface.
+type fakeWebhookRepository struct {
+	repository.WebhookRepository
+
+	mu         sync.Mutex
+	subs       map[uint]*models.WebhookSubscription
+	deliveries map[uint]*models.WebhookDelivery
+	nextID     uint
+}
+
+func newFakeWebhookRepository(subs ...models.WebhookSubscription) *fakeWebhookRepository {
+	repo := &fakeWebhookRepository{
+		subs:       make(map[uint]*models.WebhookSubscription),
+		deliveries: make(map[uint]*models.WebhookDelivery),
+		nextID:     1,
+	}
+	for i := range subs {
+		sub := subs[i]
+		repo.subs[sub.ID] = &sub
+	}
+	return repo
+}
+
+func (r *fakeWebhookRepository) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var subs []models.WebhookSubscription
+	for _, sub := range r.subs {
+		subs = append(subs, *sub)
+	}
+	return subs, nil
+}
+
+func (r *fakeWebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range deliveries {
+		deliveries[i].ID = r.nextID
+		r.nextID++
+		stored := deliveries[i]
+		r.deliveries[stored.ID] = &stored
+	}
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetDelivery(id uint) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) DeliveredSubscriptionIDs(eventID string) ([]uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []uint
+	for _, delivery := range r.deliveries {
+		if delivery.EventID == eventID {
+			ids = append(ids, delivery.SubscriptionID)
+		}
+	}
+	return ids, nil
+}
+
+// ClaimDueDeliveries reserves due pending deliveries for the lease by moving
+// their next attempt past it, like the database implementation.
+func (r *fakeWebhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var claimed []models.WebhookDelivery
+	for id := uint(1); id < r.nextID && len(claimed) < limit; id++ {
+		delivery, ok := r.deliveries[id]
+		if !ok || delivery.Status != models.DeliveryStatusPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		claimed = append(claimed, *delivery)
+		delivery.NextAttemptAt = now.Add(lease)
+	}
+	return claimed, nil
+}
+
+func (r *fakeWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *delivery
+	r.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+// elapse moves every pending delivery d closer to its next attempt, as if
+// that much time had passed.
+func (r *fakeWebhookRepository) elapse(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delivery := range r.deliveries {
+		delivery.NextAttemptAt = delivery.NextAttemptAt.Add(-d)
+	}
+}
+
+// receiver is a webhook endpoint answering with the statuses in order, then
+// with the last one.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+		status := rcv.statuses[0]
+		if len(rcv.statuses) > 1 {
+			rcv.statuses = rcv.statuses[1:]
+		}
+		rcv.mu.Unlock()
+
+		// Zero stalls until the client gives up
+		if status == 0 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) count() int {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return len(rcv.requests)
+}
+
+func testOptions() Options {
+	return Options{
+		MaxAttempts:  3,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   time.Hour,
+		PollInterval: time.Hour,
+		BatchSize:    10,
+		Concurrency:  2,
+	}
+}
+
+// setup subscribes the receiver to user.created and enqueues one such event.
+func setup(t *testing.T, rcv *receiver, client *http.Client) (*Dispatcher, *fakeWebhookRepository, events.Event) {
+	t.Helper()
+	repo := newFakeWebhookRepository(models.WebhookSubscription{
+		ID:     1,
+		URL:    rcv.URL,
+		Secret: "s3cret",
+		Events: []string{events.UserCreated},
+		Active: true,
+	})
+	d := NewDispatcher(repo, client, testOptions())
+	event := events.New(events.UserCreated, map[string]interface{}{"id": 1})
+	if err := d.Enqueue(event); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	return d, repo, event
+}
+
+func delivery(t *testing.T, repo *fakeWebhookRepository) *models.WebhookDelivery {
+	t.Helper()
+	delivery, err := repo.GetDelivery(1)
+	if err != nil {
+		t.Fatalf("delivery: %v", err)
+	}
+	return delivery
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNoContent)
+	d, repo, event := setup(t, rcv, rcv.Client())
+
+	d.deliverDue(context.Background())
+
+	if rcv.count() != 1 {
+		t.Fatalf("%d requests, want 1", rcv.count())
+	}
+	req, body := rcv.requests[0], rcv.bodies[0]
+	timestamp := req.Header.Get(TimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Errorf("invalid timestamp %q", timestamp)
+	}
+	if !Verify("s3cret", timestamp, body, req.Header.Get(SignatureHeader)) {
+		t.Errorf("signature %q does not verify", req.Header.Get(SignatureHeader))
+	}
+	if Verify("other", timestamp, body, req.Header.Get(SignatureHeader)) {
+		t.Error("signature verifies with another secret")
+	}
+	if got := req.Header.Get(EventHeader); got != event.Type {
+		t.Errorf("event header %q, want %q", got, event.Type)
+	}
+	if got := req.Header.Get(DeliveryHeader); got != "1" {
+		t.Errorf("delivery header %q, want 1", got)
+	}
+
+	sent := delivery(t, repo)
+	if sent.Status != models.DeliveryStatusSucceeded || sent.Attempts != 1 || sent.DeliveredAt == nil {
+		t.Errorf("delivery %+v, want succeeded after one attempt", sent)
+	}
+}
+
+func TestDispatcherEnqueueSkipsUnwantedAndQueued(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNoContent)
+	d, repo, event := setup(t, rcv, rcv.Client())
+	repo.subs[2] = &models.WebhookSubscription{ID: 2, URL: rcv.URL, Events: []string{events.Wildcard}, Active: false}
+	repo.subs[3] = &models.WebhookSubscription{ID: 3, URL: rcv.URL, Events: []string{events.TeamCreated}, Active: true}
+	d.InvalidateSubscriptions()
+
+	if err := d.Enqueue(event); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if len(repo.deliveries) != 1 {
+		t.Errorf("%d deliveries, want 1", len(repo.deliveries))
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"server error", http.StatusServiceUnavailable},
+		{"timeout", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := newReceiver(t, tt.status, tt.status, http.StatusOK)
+			d, repo, _ := setup(t, rcv, &http.Client{Timeout: 100 * time.Millisecond})
+
+			for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+				before := time.Now()
+				d.deliverDue(context.Background())
+
+				failed := delivery(t, repo)
+				if failed.Status != models.DeliveryStatusPending || failed.Attempts != attempt+1 {
+					t.Fatalf("delivery %+v, want pending after %d attempts", failed, attempt+1)
+				}
+				if failed.LastStatusCode != tt.status || failed.LastError == "" {
+					t.Errorf("status %d and error %q, want %d and an error", failed.LastStatusCode, failed.LastError, tt.status)
+				}
+				if wait := failed.NextAttemptAt.Sub(before); wait < backoff || wait > backoff+time.Second {
+					t.Errorf("next attempt in %v, want %v", wait, backoff)
+				}
+
+				// Not due before the backoff has passed
+				d.deliverDue(context.Background())
+				if rcv.count() != attempt+1 {
+					t.Fatalf("%d requests, want %d", rcv.count(), attempt+1)
+				}
+				repo.elapse(backoff)
+			}
+
+			d.deliverDue(context.Background())
+			if sent := delivery(t, repo); sent.Status != models.DeliveryStatusSucceeded || sent.Attempts != 3 || sent.LastError != "" {
+				t.Errorf("delivery %+v, want succeeded after three attempts", sent)
+			}
+		})
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	d, repo, _ := setup(t, rcv, rcv.Client())
+
+	for i := 0; i < 5; i++ {
+		d.deliverDue(context.Background())
+		repo.elapse(time.Hour)
+	}
+
+	if rcv.count() != 3 {
+		t.Errorf("%d requests, want 3", rcv.count())
+	}
+	if failed := delivery(t, repo); failed.Status != models.DeliveryStatusFailed || failed.Attempts != 3 || failed.LastStatusCode != 500 {
+		t.Errorf("delivery %+v, want failed after three attempts", failed)
+	}
+}
+
+func TestDispatcherRedeliversAfterLease(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	d, repo, _ := setup(t, rcv, rcv.Client())
+
+	// Another replica claims the delivery and dies before sending it
+	if claimed, _ := repo.ClaimDueDeliveries(10, d.lease()); len(claimed) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(claimed))
+	}
+
+	d.deliverDue(context.Background())
+	if rcv.count() != 0 {
+		t.Fatalf("delivery sent while leased")
+	}
+
+	repo.elapse(d.lease())
+	d.deliverDue(context.Background())
+	if rcv.count() != 1 {
+		t.Fatalf("%d requests after the lease, want 1", rcv.count())
+	}
+	if sent := delivery(t, repo); sent.Status != models.DeliveryStatusSucceeded || sent.Attempts != 1 {
+		t.Errorf("delivery %+v, want succeeded after one attempt", sent)
+	}
+}
+
+func TestDispatcherLeaseCoversBatch(t *testing.T) {
+	d := NewDispatcher(newFakeWebhookRepository(), &http.Client{Timeout: 10 * time.Second}, testOptions())
+	// Five rounds of two deliveries, each allowed the full timeout
+	if lease, min := d.lease(), 50*time.Second; lease <= min {
+		t.Errorf("lease %v, want more than %v", lease, min)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(newFakeWebhookRepository(), nil, Options{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
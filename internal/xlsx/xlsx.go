@@ -0,0 +1,281 @@
+// Package xlsx reads the cell values of Office Open XML workbooks (.xlsx)
+// using only the standard library. Cells are returned as text: shared and
+// inline strings as written, booleans as "true" or "false" and numbers as
+// stored, so dates arrive as serial day numbers. Formatting, formulas and
+// merged cells are ignored.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize bounds the uncompressed size of each part read, so a small
+// upload cannot expand without limit.
+const maxPartSize = 64 << 20
+
+// maxColumns is the width of a sheet, column A to XFD.
+const maxColumns = 16384
+
+// MaxCells bounds the cells of the rows returned for a sheet, counting the
+// empty ones rows are padded with, so a few far-off cells cannot expand into
+// millions of values.
+const MaxCells = 1 << 22
+
+// ErrSheetNotFound is returned when the workbook has no sheet of the
+// requested name.
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// Workbook is an opened workbook.
+type Workbook struct {
+	files  map[string]*zip.File
+	sheets []sheet
+	shared []string
+}
+
+type sheet struct {
+	name string
+	part string
+}
+
+type workbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		// r:id; attributes match by local name
+		RelID string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationshipsXML struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// textXML is a shared or inline string, plain or split into rich text runs.
+type textXML struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t textXML) String() string {
+	var b strings.Builder
+	b.WriteString(t.Text)
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type sharedStringsXML struct {
+	Items []textXML `xml:"si"`
+}
+
+type worksheetXML struct {
+	Rows []struct {
+		Cells []cellXML `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type cellXML struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline *textXML `xml:"is"`
+}
+
+// Open reads the workbook's sheet list and shared strings.
+func Open(data []byte) (*Workbook, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an xlsx file: %w", err)
+	}
+	wb := &Workbook{files: make(map[string]*zip.File)}
+	for _, file := range archive.File {
+		wb.files[file.Name] = file
+	}
+
+	var workbook workbookXML
+	if err := wb.decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels relationshipsXML
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		// Targets are relative to xl/ unless absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	for _, s := range workbook.Sheets {
+		part, ok := targets[s.RelID]
+		if !ok {
+			return nil, fmt.Errorf("sheet %q has no part", s.Name)
+		}
+		wb.sheets = append(wb.sheets, sheet{name: s.Name, part: part})
+	}
+
+	// Workbooks without text cells have no shared strings part
+	if _, ok := wb.files["xl/sharedStrings.xml"]; ok {
+		var shared sharedStringsXML
+		if err := wb.decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+		wb.shared = make([]string, len(shared.Items))
+		for i, item := range shared.Items {
+			wb.shared[i] = item.String()
+		}
+	}
+	return wb, nil
+}
+
+// SheetNames lists the sheets in workbook order.
+func (wb *Workbook) SheetNames() []string {
+	names := make([]string, len(wb.sheets))
+	for i, s := range wb.sheets {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Rows returns the cell values of the named sheet, matched ignoring case.
+// Rows without any value are skipped and rows are as wide as their last
+// value. Sheets whose rows add up to more than MaxCells are rejected.
+func (wb *Workbook) Rows(name string) ([][]string, error) {
+	for _, s := range wb.sheets {
+		if strings.EqualFold(s.name, name) {
+			return wb.rows(s)
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrSheetNotFound, name)
+}
+
+func (wb *Workbook) rows(s sheet) ([][]string, error) {
+	var worksheet worksheetXML
+	if err := wb.decode(s.part, &worksheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	cells := 0
+	for _, row := range worksheet.Rows {
+		var values []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				var err error
+				if col, err = columnIndex(cell.Ref); err != nil {
+					return nil, fmt.Errorf("sheet %q: %w", s.name, err)
+				}
+			}
+			value, err := wb.cellValue(cell)
+			if err != nil {
+				return nil, fmt.Errorf("sheet %q, cell %s: %w", s.name, cell.Ref, err)
+			}
+			if value == "" {
+				continue
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			values[col] = value
+		}
+		if len(values) > 0 {
+			if cells += len(values); cells > MaxCells {
+				return nil, fmt.Errorf("sheet %q has more than %d cells", s.name, MaxCells)
+			}
+			rows = append(rows, values)
+		}
+	}
+	return rows, nil
+}
+
+func (wb *Workbook) cellValue(cell cellXML) (string, error) {
+	switch cell.Type {
+	case "s":
+		if cell.Value == "" {
+			return "", nil
+		}
+		i, err := strconv.Atoi(cell.Value)
+		if err != nil || i < 0 || i >= len(wb.shared) {
+			return "", fmt.Errorf("invalid shared string %q", cell.Value)
+		}
+		return wb.shared[i], nil
+	case "inlineStr":
+		if cell.Inline == nil {
+			return "", nil
+		}
+		return cell.Inline.String(), nil
+	case "b":
+		switch cell.Value {
+		case "1":
+			return "true", nil
+		case "0":
+			return "false", nil
+		}
+		return cell.Value, nil
+	default:
+		// Numbers, formula strings ("str") and errors ("e") are stored as
+		// text already
+		return cell.Value, nil
+	}
+}
+
+// columnIndex returns the 0-based column of a cell reference such as "AB12".
+func columnIndex(ref string) (int, error) {
+	col := 0
+	letters := 0
+	for _, r := range ref {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		letters++
+	}
+	if letters == 0 || letters > 3 || col > maxColumns {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+func (wb *Workbook) decode(name string, v interface{}) error {
+	file, ok := wb.files[name]
+	if !ok {
+		return fmt.Errorf("missing part %s", name)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("part %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartSize+1))
+	if err != nil {
+		return fmt.Errorf("part %s: %w", name, err)
+	}
+	if len(data) > maxPartSize {
+		return fmt.Errorf("part %s exceeds %d bytes", name, maxPartSize)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("part %s: %w", name, err)
+	}
+	return nil
+}
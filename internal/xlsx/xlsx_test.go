@@ -0,0 +1,281 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	workbookPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+	<sheets>
+		<sheet name="Users" sheetId="1" r:id="rId1"/>
+		<sheet name="Teams" sheetId="2" r:id="rId2"/>
+	</sheets>
+</workbook>`
+	relsPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+	<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+	<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+	sharedPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+	<si><t>name</t></si>
+	<si><t>email</t></si>
+	<si><r><t>Ada </t></r><r><rPr><b/></rPr><t>Lovelace</t></r></si>
+</sst>`
+)
+
+func sheetPart(rows string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		rows + `</sheetData></worksheet>`
+}
+
+// build zips the parts into a workbook.
+func build(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// validParts is a workbook with a Users and a Teams sheet; tests replace or
+// drop parts to break it.
+func validParts() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml":            workbookPart,
+		"xl/_rels/workbook.xml.rels": relsPart,
+		"xl/sharedStrings.xml":       sharedPart,
+		"xl/worksheets/sheet1.xml": sheetPart(`
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="inlineStr"><is><t>ada@example.com</t></is></c></row>`),
+		"xl/worksheets/sheet2.xml": sheetPart(`<row r="1"><c r="A1" t="inlineStr"><is><t>title</t></is></c></row>`),
+	}
+}
+
+func TestOpen(t *testing.T) {
+	wb, err := Open(build(t, validParts()))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if names := wb.SheetNames(); !reflect.DeepEqual(names, []string{"Users", "Teams"}) {
+		t.Errorf("sheet names %v", names)
+	}
+
+	rows, err := wb.Rows("users")
+	if err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	want := [][]string{{"name", "email"}, {"Ada Lovelace", "ada@example.com"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Users rows %q, want %q", rows, want)
+	}
+
+	// The Teams part has an absolute target
+	rows, err = wb.Rows("Teams")
+	if err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if !reflect.DeepEqual(rows, [][]string{{"title"}}) {
+		t.Errorf("Teams rows %q", rows)
+	}
+
+	if _, err := wb.Rows("Projects"); !errors.Is(err, ErrSheetNotFound) {
+		t.Errorf("expected ErrSheetNotFound, got %v", err)
+	}
+}
+
+func TestRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		want  [][]string
+	}{
+		{
+			name:  "numbers and booleans",
+			sheet: `<row><c r="A1"><v>42</v></c><c r="B1" t="n"><v>45000.5</v></c><c r="C1" t="b"><v>1</v></c><c r="D1" t="b"><v>0</v></c></row>`,
+			want:  [][]string{{"42", "45000.5", "true", "false"}},
+		},
+		{
+			name:  "formula strings and errors",
+			sheet: `<row><c r="A1" t="str"><f>A2</f><v>x</v></c><c r="B1" t="e"><v>#N/A</v></c></row>`,
+			want:  [][]string{{"x", "#N/A"}},
+		},
+		{
+			name:  "sparse cells keep their columns",
+			sheet: `<row><c r="B1"><v>1</v></c><c r="D1"><v>2</v></c></row>`,
+			want:  [][]string{{"", "1", "", "2"}},
+		},
+		{
+			name:  "cells without references are positional",
+			sheet: `<row><c><v>1</v></c><c><v>2</v></c></row>`,
+			want:  [][]string{{"1", "2"}},
+		},
+		{
+			name:  "lowercase references",
+			sheet: `<row><c r="b1"><v>1</v></c></row>`,
+			want:  [][]string{{"", "1"}},
+		},
+		{
+			name:  "empty rows and cells are skipped",
+			sheet: `<row r="1"><c r="A1"/><c r="B1" t="s"/><c r="C1" t="inlineStr"/></row><row r="2"><c r="A2"><v>1</v></c><c r="B2"/></row><row r="3"/>`,
+			want:  [][]string{{"1"}},
+		},
+		{
+			name:  "no rows",
+			sheet: ``,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := validParts()
+			// Without shared strings, as workbooks without text cells are
+			delete(parts, "xl/sharedStrings.xml")
+			parts["xl/worksheets/sheet1.xml"] = sheetPart(tt.sheet)
+			wb, err := Open(build(t, parts))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			rows, err := wb.Rows("Users")
+			if err != nil {
+				t.Fatalf("rows: %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows %q, want %q", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(parts map[string]string)
+	}{
+		{"missing workbook", func(parts map[string]string) { delete(parts, "xl/workbook.xml") }},
+		{"missing relationships", func(parts map[string]string) { delete(parts, "xl/_rels/workbook.xml.rels") }},
+		{"invalid workbook XML", func(parts map[string]string) { parts["xl/workbook.xml"] = `<workbook><sheets>` }},
+		{"invalid shared strings XML", func(parts map[string]string) { parts["xl/sharedStrings.xml"] = `<sst><si>` }},
+		{"sheet without a relationship", func(parts map[string]string) {
+			parts["xl/_rels/workbook.xml.rels"] = `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := validParts()
+			tt.change(parts)
+			if _, err := Open(build(t, parts)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	for _, data := range [][]byte{nil, []byte("name,email\n"), []byte("PK\x03\x04truncated")} {
+		if _, err := Open(data); err == nil {
+			t.Errorf("expected %q to be rejected", data)
+		}
+	}
+}
+
+func TestRowsMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+	}{
+		{"invalid XML", `<row><c r="A1"><v>1</v></row>`},
+		{"shared string out of range", `<row><c r="A1" t="s"><v>3</v></c></row>`},
+		{"negative shared string", `<row><c r="A1" t="s"><v>-1</v></c></row>`},
+		{"shared string not a number", `<row><c r="A1" t="s"><v>a</v></c></row>`},
+		{"reference without a column", `<row><c r="12"><v>1</v></c></row>`},
+		{"reference past column XFD", `<row><c r="XFE1"><v>1</v></c></row>`},
+		{"reference with four letters", `<row><c r="ABCD1"><v>1</v></c></row>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := validParts()
+			parts["xl/worksheets/sheet1.xml"] = sheetPart(tt.sheet)
+			wb, err := Open(build(t, parts))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if rows, err := wb.Rows("Users"); err == nil {
+				t.Errorf("expected an error, got %q", rows)
+			}
+		})
+	}
+
+	t.Run("missing sheet part", func(t *testing.T) {
+		parts := validParts()
+		delete(parts, "xl/worksheets/sheet2.xml")
+		wb, err := Open(build(t, parts))
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		if _, err := wb.Rows("Teams"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestRowsTooManyCells(t *testing.T) {
+	// One cell at XFD per row pads every row to the full sheet width
+	rows := func(n int) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&b, `<row r="%d"><c r="XFD%d"><v>1</v></c></row>`, i, i)
+		}
+		return b.String()
+	}
+	limit := MaxCells / maxColumns
+	for n, ok := range map[int]bool{limit: true, limit + 1: false} {
+		parts := validParts()
+		parts["xl/worksheets/sheet1.xml"] = sheetPart(rows(n))
+		wb, err := Open(build(t, parts))
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		if _, err := wb.Rows("Users"); (err == nil) != ok {
+			t.Errorf("%d rows: error %v, want one: %v", n, err, !ok)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"Z9", 25},
+		{"AA1", 26},
+		{"AZ1", 51},
+		{"BA1", 52},
+		{"XFD1048576", 16383},
+		{"c3", 2},
+	}
+	for _, tt := range tests {
+		if got, err := columnIndex(tt.ref); err != nil || got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, %v; want %d", tt.ref, got, err, tt.want)
+		}
+	}
+}